@@ -13,6 +13,7 @@ import (
 	"github.com/stkevintan/miko/pkg/cookiecloud"
 	"github.com/stkevintan/miko/pkg/log"
 	"github.com/stkevintan/miko/pkg/provider"
+	"github.com/stkevintan/miko/pkg/types"
 )
 
 var (
@@ -29,6 +30,8 @@ type Config struct {
 	Provider    *provider.Config    `json:"provider" mapstructure:"provider"`
 	Database    *DatabaseConfig     `json:"database" mapstructure:"database"`
 	Subsonic    *SubsonicConfig     `json:"subsonic" mapstructure:"subsonic"`
+	Scrobbler   *ScrobblerConfig    `json:"scrobbler" mapstructure:"scrobbler"`
+	Download    *DownloadConfig     `json:"download" mapstructure:"download"`
 }
 
 func (c *Config) Validate() error {
@@ -59,6 +62,15 @@ func (c *Config) Validate() error {
 	if err := c.Subsonic.Validate(); err != nil {
 		return err
 	}
+	if c.Scrobbler == nil {
+		return errors.New("scrobbler config is required")
+	}
+	if c.Download == nil {
+		return errors.New("download config is required")
+	}
+	if err := c.Download.Validate(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -82,20 +94,61 @@ func (s *ServerConfig) Validate() error {
 }
 
 type SubsonicConfig struct {
-	Folders         []string `json:"folders" mapstructure:"folders"`
-	DataDir         string   `json:"dataDir" mapstructure:"dataDir"`
-	ScanMode        string   `json:"scanMode" mapstructure:"scanMode"`
-	ScrapeMode      string   `json:"scrapeMode" mapstructure:"scrapeMode"`
-	IgnoredArticles string   `json:"ignoredArticles" mapstructure:"ignoredArticles"`
+	Folders          []string `json:"folders" mapstructure:"folders"`
+	AudiobookFolders []string `json:"audiobookFolders" mapstructure:"audiobookFolders"`
+	DataDir          string   `json:"dataDir" mapstructure:"dataDir"`
+	ScanMode         string   `json:"scanMode" mapstructure:"scanMode"`
+	ScrapeMode       string   `json:"scrapeMode" mapstructure:"scrapeMode"`
+	IgnoredArticles  string   `json:"ignoredArticles" mapstructure:"ignoredArticles"`
+	VariousArtists   string   `json:"variousArtists" mapstructure:"variousArtists"`
+	SyncPlaylists    bool     `json:"syncPlaylists" mapstructure:"syncPlaylists"`
 }
 
 func (s *SubsonicConfig) Validate() error {
 	if s.DataDir == "" {
 		return errors.New("subsonic.dataDir is required")
 	}
+	if s.VariousArtists == "" {
+		return errors.New("subsonic.variousArtists is required")
+	}
 	return nil
 }
 
+// ScrobblerConfig holds the endpoints scrobbles are forwarded to. LastFMURL may point to any
+// Last.fm API compatible service, LastFMAPIKey and LastFMSecret are the API account of Miko there.
+type ScrobblerConfig struct {
+	ListenBrainzURL string `json:"listenbrainzUrl" mapstructure:"listenbrainzUrl"`
+	LastFMURL       string `json:"lastfmUrl" mapstructure:"lastfmUrl"`
+	LastFMAPIKey    string `json:"lastfmApiKey" mapstructure:"lastfmApiKey"`
+	LastFMSecret    string `json:"-" mapstructure:"lastfmSecret"`
+	// LastFMURLs are the other Last.fm compatible services users may link accounts of with an
+	// API account of their own, admins may use any
+	LastFMURLs []string `json:"lastfmUrls" mapstructure:"lastfmUrls"`
+}
+
+// DownloadConfig holds where downloaded songs are saved. Folder is the music folder they land in
+// when the request doesn't name an output, the first of subsonic.folders when empty, and
+// Template names their files inside it. Roots are the directories outside of the music folders
+// downloads may be saved to.
+type DownloadConfig struct {
+	Folder   string   `json:"folder" mapstructure:"folder"`
+	Template string   `json:"template" mapstructure:"template"`
+	Roots    []string `json:"roots" mapstructure:"roots"`
+}
+
+func (d *DownloadConfig) Validate() error {
+	if _, err := types.ParsePathTemplate(d.Template); err != nil {
+		return fmt.Errorf("download.template: %w", err)
+	}
+	return nil
+}
+
+// PathTemplate returns the parsed Template, which Validate checked.
+func (d *DownloadConfig) PathTemplate() *types.PathTemplate {
+	t, _ := types.ParsePathTemplate(d.Template)
+	return t
+}
+
 type DatabaseConfig struct {
 	Driver string `json:"driver" mapstructure:"driver"`
 	DSN    string `json:"dsn" mapstructure:"dsn"`
@@ -192,5 +245,17 @@ func (c *Config) expandEnv() {
 		for i, folder := range c.Subsonic.Folders {
 			c.Subsonic.Folders[i] = os.ExpandEnv(folder)
 		}
+		for i, folder := range c.Subsonic.AudiobookFolders {
+			c.Subsonic.AudiobookFolders[i] = os.ExpandEnv(folder)
+		}
+	}
+	if c.Download != nil {
+		c.Download.Folder = os.ExpandEnv(c.Download.Folder)
+		for i, root := range c.Download.Roots {
+			c.Download.Roots[i] = os.ExpandEnv(root)
+		}
+		if c.Download.Folder == "" && c.Subsonic != nil && len(c.Subsonic.Folders) > 0 {
+			c.Download.Folder = c.Subsonic.Folders[0]
+		}
 	}
 }
@@ -13,6 +13,7 @@ require (
 	github.com/spf13/viper v1.21.0
 	go.senan.xyz/taglib v0.11.1
 	golang.org/x/sync v0.19.0
+	golang.org/x/text v0.32.0
 	gorm.io/gorm v1.31.1
 )
 
@@ -46,7 +47,6 @@ require (
 	go.yaml.in/yaml/v3 v3.0.4 // indirect
 	golang.org/x/net v0.48.0 // indirect
 	golang.org/x/sys v0.39.0 // indirect
-	golang.org/x/text v0.32.0 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1 // indirect
 	modernc.org/libc v1.22.5 // indirect
 	modernc.org/mathutil v1.5.0 // indirect
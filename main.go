@@ -10,6 +10,7 @@ import (
 	"os/signal"
 	"path"
 	"path/filepath"
+	"slices"
 	"syscall"
 	"time"
 
@@ -20,6 +21,7 @@ import (
 	"github.com/stkevintan/miko/pkg/crypto"
 	"github.com/stkevintan/miko/pkg/di"
 	"github.com/stkevintan/miko/pkg/log"
+	"github.com/stkevintan/miko/pkg/search"
 	"github.com/stkevintan/miko/server"
 	"gorm.io/gorm"
 )
@@ -63,15 +65,31 @@ func main() {
 		&models.AlbumID3{},
 		&models.Child{},
 		&models.Genre{},
+		&models.SongContributor{},
+		&models.Chapter{},
 		&models.PlaylistRecord{},
 		&models.PlaylistSong{},
+		&models.PlaylistCollaborator{},
+		&models.PlaylistActivity{},
 		&models.BookmarkRecord{},
+		&models.PlayRecord{},
+		&models.ScrobbleAccount{},
+		&models.ScrobbleEvent{},
+		&models.ArtistInfoRecord{},
+		&models.AlbumInfoRecord{},
+		&models.DownloadJob{},
+		&models.DownloadItem{},
+		&models.Subscription{},
+		&models.SubscriptionEvent{},
 		&models.PlayQueueRecord{},
 		&models.PlayQueueSong{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
+	if err := search.EnsureIndex(db); err != nil {
+		log.Fatalf("Failed to prepare search index: %v", err)
+	}
 
 	// Initialize Injector
 	appCtx, cancel := context.WithCancel(context.Background())
@@ -147,8 +165,12 @@ func main() {
 
 func syncMusicFolders(db *gorm.DB, cfg *config.Config) {
 	var currentPaths []string
-	for _, rawPath := range cfg.Subsonic.Folders {
+	// audiobook folders are scanned like any other folder, the scanner tags their files as audiobooks
+	for _, rawPath := range append(slices.Clone(cfg.Subsonic.Folders), cfg.Subsonic.AudiobookFolders...) {
 		path := filepath.ToSlash(filepath.Clean(rawPath))
+		if slices.Contains(currentPaths, path) {
+			continue
+		}
 		var folder models.MusicFolder
 		db.Where(models.MusicFolder{Path: path}).Attrs(models.MusicFolder{Name: filepath.Base(path)}).FirstOrCreate(&folder)
 		currentPaths = append(currentPaths, path)
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// ArtistURL is a link to a page about an artist, such as its homepage or a streaming service.
+type ArtistURL struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// ArtistInfoRecord caches what the artist info sources know about the artist named Name, which
+// is stored lower case. Similar holds the names of similar artists, whether in the library or
+// not. The record is fetched again once ExpiresAt has passed.
+type ArtistInfoRecord struct {
+	Name           string      `gorm:"primaryKey" json:"name"`
+	Biography      string      `json:"biography"`
+	MusicBrainzID  string      `json:"musicBrainzId"`
+	LastFmURL      string      `json:"lastFmUrl"`
+	SmallImageURL  string      `json:"smallImageUrl"`
+	MediumImageURL string      `json:"mediumImageUrl"`
+	LargeImageURL  string      `json:"largeImageUrl"`
+	URLs           []ArtistURL `gorm:"serializer:json" json:"urls"`
+	Similar        []string    `gorm:"serializer:json" json:"similar"`
+	UpdatedAt      time.Time   `json:"updatedAt"`
+	ExpiresAt      time.Time   `gorm:"index" json:"expiresAt"`
+}
+
+// AlbumInfoRecord caches the notes, MusicBrainz release and cover art links of the album with
+// the ID3 id AlbumID. The record is fetched again once ExpiresAt has passed.
+type AlbumInfoRecord struct {
+	AlbumID        string    `gorm:"primaryKey" json:"albumId"`
+	Notes          string    `json:"notes"`
+	MusicBrainzID  string    `json:"musicBrainzId"`
+	SmallImageURL  string    `json:"smallImageUrl"`
+	MediumImageURL string    `json:"mediumImageUrl"`
+	LargeImageURL  string    `json:"largeImageUrl"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	ExpiresAt      time.Time `gorm:"index" json:"expiresAt"`
+}
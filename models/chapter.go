@@ -0,0 +1,10 @@
+package models
+
+// Chapter is a chapter marker of an audiobook track. Start and End are in milliseconds.
+type Chapter struct {
+	ChildID string `gorm:"primaryKey" xml:"-" json:"-"`
+	Number  int    `gorm:"primaryKey" xml:"number,attr" json:"number"`
+	Title   string `xml:"title,attr" json:"title"`
+	Start   int64  `xml:"start,attr" json:"start"`
+	End     int64  `xml:"end,attr" json:"end"`
+}
@@ -0,0 +1,37 @@
+package models
+
+// Contributor roles read from tags. Performers carry their instrument as sub-role.
+const (
+	RoleComposer  = "composer"
+	RoleLyricist  = "lyricist"
+	RoleConductor = "conductor"
+	RoleArranger  = "arranger"
+	RoleProducer  = "producer"
+	RoleRemixer   = "remixer"
+	RoleDJMixer   = "djmixer"
+	RoleMixer     = "mixer"
+	RoleEngineer  = "engineer"
+	RolePerformer = "performer"
+)
+
+// SongContributor links a song to an artist credited with a role other than the main artist.
+type SongContributor struct {
+	ChildID  string `gorm:"primaryKey"`
+	ArtistID string `gorm:"primaryKey;index"`
+	Role     string `gorm:"primaryKey;index"`
+	SubRole  string `gorm:"primaryKey"`
+}
+
+// Contributor is the OpenSubsonic representation of a song contributor.
+type Contributor struct {
+	Role    string    `xml:"role,attr" json:"role"`
+	SubRole string    `xml:"subRole,attr,omitempty" json:"subRole,omitempty"`
+	Artist  ArtistID3 `xml:"artist" json:"artist"`
+}
+
+// ContributorArtist is an artist together with the number of songs credited to it in a role.
+type ContributorArtist struct {
+	ArtistID3
+	Role      string `json:"role"`
+	SongCount int    `json:"songCount"`
+}
@@ -1,13 +1,17 @@
 package models
 
-import "github.com/stkevintan/miko/pkg/types"
+import (
+	"time"
+
+	"github.com/stkevintan/miko/pkg/types"
+)
 
 // DownloadRequest represents the download request
 // @Description Music download request
 type DownloadRequest struct {
 	SongID  string `json:"songId" binding:"required" example:"2161154646" description:"Song ID to download"`
 	Level   string `json:"level,omitempty" example:"lossless" description:"Audio quality: standard/128, higher/192, exhigh/320, lossless/SQ, hires/HR"`
-	Output  string `json:"output,omitempty" example:"./downloads" description:"Output directory path"`
+	Output  string `json:"output,omitempty" example:"./downloads" description:"Output directory, relative to the default download directory, it must be one the user may download to"`
 	Timeout int    `json:"timeout,omitempty" example:"30000" description:"Timeout in milliseconds"`
 }
 
@@ -15,3 +19,58 @@ type DownloadSummary struct {
 	Summary string                  `json:"summary" example:"Downloaded 8 out of 10 songs." description:"Summary of the download operation"`
 	Details []*types.DownloadResult `json:"details" description:"Detailed batch download response"`
 }
+
+// Statuses of download jobs and their items. Jobs wait as queued, items as pending.
+const (
+	DownloadQueued    = "queued"
+	DownloadRunning   = "running"
+	DownloadPaused    = "paused"
+	DownloadCancelled = "cancelled"
+	DownloadCompleted = "completed"
+	DownloadFailed    = "failed"
+	DownloadPending   = "pending"
+)
+
+// DownloadJob is a persisted request of a user to download the songs of URIs, which can be
+// songs, albums or playlists of Platform. The URIs are resolved to items by the queue.
+// Done and Failed count the finished items, Skipped the songs left out as they are in the
+// library already. PathTemplate names the files inside Output.
+type DownloadJob struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	Username       string         `gorm:"index" json:"-"`
+	Platform       string         `json:"platform"`
+	URIs           []string       `gorm:"serializer:json" json:"uris"`
+	Level          string         `json:"level"`
+	Output         string         `json:"output"`
+	ConflictPolicy string         `json:"conflictPolicy"`
+	PathTemplate   string         `json:"pathTemplate,omitempty"`
+	Status         string         `gorm:"index" json:"status"`
+	Resolved       bool           `json:"resolved"`
+	Total          int            `json:"total"`
+	Skipped        int            `json:"skipped"`
+	Done           int            `json:"done"`
+	Failed         int            `json:"failed"`
+	Error          string         `json:"error,omitempty"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+	FinishedAt     *time.Time     `json:"finishedAt,omitempty"`
+	Items          []DownloadItem `gorm:"foreignKey:JobID" json:"items,omitempty"`
+}
+
+// DownloadItem is a song of a download job. A failed attempt is retried at NextAttempt until
+// the item runs out of attempts. SongID is the library ID of the downloaded song once indexed.
+type DownloadItem struct {
+	ID          uint        `gorm:"primaryKey" json:"id"`
+	JobID       uint        `gorm:"index" json:"jobId"`
+	Position    int         `json:"position"`
+	Music       types.Music `gorm:"serializer:json" json:"music"`
+	Status      string      `gorm:"index" json:"status"`
+	Attempts    int         `json:"attempts"`
+	NextAttempt time.Time   `gorm:"index" json:"nextAttempt"`
+	Error       string      `json:"error,omitempty"`
+	FilePath    string      `json:"filePath,omitempty"`
+	SongID      string      `json:"songId,omitempty"`
+	Quality     string      `json:"quality,omitempty"`
+	Size        int64       `json:"size,omitempty"`
+	UpdatedAt   time.Time   `json:"updatedAt"`
+}
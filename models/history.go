@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// PlayRecord is one submission scrobble of a song by a user. Duration is how long the song was
+// listened to in seconds and Full is set when it was played to the end rather than skipped.
+type PlayRecord struct {
+	ID       uint      `gorm:"primaryKey" json:"id"`
+	Username string    `gorm:"index:idx_play_records_user_time,priority:1" json:"username"`
+	SongID   string    `gorm:"index" json:"songId"`
+	PlayedAt time.Time `gorm:"index:idx_play_records_user_time,priority:2" json:"playedAt"`
+	Client   string    `json:"client"`
+	Duration int       `json:"duration"`
+	Full     bool      `json:"full"`
+}
@@ -4,15 +4,34 @@ import (
 	"time"
 )
 
+// PlaylistRecord is a playlist. Collaborators are the users the owner shared it with, Rules make
+// it a smart playlist whose songs are selected when it is read and Path is the playlist file in
+// a music folder it is synced from, Unmatched counting the entries of the file its last sync
+// found no song for.
 type PlaylistRecord struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Name      string         `gorm:"index" json:"name"`
-	Comment   string         `json:"comment"`
-	Owner     string         `gorm:"index" json:"owner"`
-	Public    bool           `json:"public"`
-	Songs     []PlaylistSong `gorm:"foreignKey:PlaylistID;constraint:OnDelete:CASCADE" json:"songs"`
-	CreatedAt time.Time      `json:"createdAt"`
-	UpdatedAt time.Time      `json:"updatedAt"`
+	ID            uint                   `gorm:"primaryKey" json:"id"`
+	Name          string                 `gorm:"index" json:"name"`
+	Comment       string                 `json:"comment"`
+	Owner         string                 `gorm:"index" json:"owner"`
+	Public        bool                   `json:"public"`
+	Songs         []PlaylistSong         `gorm:"foreignKey:PlaylistID;constraint:OnDelete:CASCADE" json:"songs"`
+	Collaborators []PlaylistCollaborator `gorm:"foreignKey:PlaylistID;constraint:OnDelete:CASCADE" json:"collaborators,omitempty"`
+	Rules         *SmartRules            `gorm:"serializer:json" json:"rules,omitempty"`
+	Path          string                 `gorm:"index" json:"path,omitempty"`
+	Unmatched     int                    `json:"unmatched,omitempty"`
+	CreatedAt     time.Time              `json:"createdAt"`
+	UpdatedAt     time.Time              `json:"updatedAt"`
+}
+
+// IsSmart reports whether the playlist is rule based.
+func (p *PlaylistRecord) IsSmart() bool {
+	return p.Rules != nil
+}
+
+// IsReadonly reports whether clients can't edit the songs of the playlist, which is the case
+// for smart playlists and playlists synced from a file.
+func (p *PlaylistRecord) IsReadonly() bool {
+	return p.IsSmart() || p.Path != ""
 }
 
 type PlaylistSong struct {
@@ -21,3 +40,61 @@ type PlaylistSong struct {
 	SongID     string `gorm:"index" json:"songId"`
 	Position   int    `json:"position"`
 }
+
+// Permissions of playlist collaborators
+const (
+	PlaylistRead = "read"
+	PlaylistEdit = "edit"
+)
+
+// PlaylistCollaborator grants a user other than the owner read or edit access to a playlist.
+type PlaylistCollaborator struct {
+	PlaylistID uint   `gorm:"primaryKey" json:"-"`
+	Username   string `gorm:"primaryKey" json:"username"`
+	Permission string `json:"permission"`
+}
+
+// Permission returns PlaylistEdit for the owner and edit collaborators, PlaylistRead for read
+// collaborators and anyone when the playlist is public, and an empty string when username
+// can't see the playlist. Collaborators must be loaded.
+func (p *PlaylistRecord) Permission(username string) string {
+	if p.Owner == username {
+		return PlaylistEdit
+	}
+	for _, c := range p.Collaborators {
+		if c.Username == username {
+			return c.Permission
+		}
+	}
+	if p.Public {
+		return PlaylistRead
+	}
+	return ""
+}
+
+// IsCollaborative reports whether users other than the owner can edit the playlist.
+func (p *PlaylistRecord) IsCollaborative() bool {
+	for _, c := range p.Collaborators {
+		if c.Permission == PlaylistEdit {
+			return true
+		}
+	}
+	return false
+}
+
+// Playlist activity actions
+const (
+	PlaylistActionAdd    = "add"
+	PlaylistActionRemove = "remove"
+)
+
+// PlaylistActivity records a user adding a song to or removing a song from a playlist.
+type PlaylistActivity struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	PlaylistID uint      `gorm:"index" json:"playlistId"`
+	Username   string    `json:"username"`
+	Action     string    `json:"action"`
+	SongID     string    `json:"songId"`
+	Title      string    `gorm:"->;-:migration" json:"title,omitempty"`
+	CreatedAt  time.Time `gorm:"index" json:"createdAt"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Scrobbling services
+const (
+	ScrobbleListenBrainz = "listenbrainz"
+	ScrobbleLastFM       = "lastfm"
+)
+
+// ScrobbleAccount links a user to an account of a scrobbling service. Token is the ListenBrainz
+// user token or the Last.fm session key and is stored encrypted, as is APISecret. BaseURL,
+// APIKey and APISecret override the configured endpoint of a Last.fm compatible service.
+type ScrobbleAccount struct {
+	Username  string    `gorm:"primaryKey" json:"-"`
+	Service   string    `gorm:"primaryKey" json:"service"`
+	Account   string    `json:"account"`
+	Token     string    `json:"-"`
+	BaseURL   string    `json:"baseUrl,omitempty"`
+	APIKey    string    `json:"apiKey,omitempty"`
+	APISecret string    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Kinds of scrobble events
+const (
+	ScrobbleNowPlaying = "nowPlaying"
+	ScrobbleSubmission = "submission"
+)
+
+// ScrobbleEvent is a now playing or submission event in the outbox, waiting to be forwarded to
+// the service of a linked account. Failed events are retried at NextAttempt.
+type ScrobbleEvent struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Username    string    `gorm:"index" json:"username"`
+	Service     string    `json:"service"`
+	Kind        string    `json:"kind"`
+	SongID      string    `json:"songId"`
+	PlayedAt    time.Time `json:"playedAt"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `gorm:"index" json:"nextAttempt"`
+	LastError   string    `json:"lastError,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SmartRules define a playlist whose songs are selected by rules instead of being listed, using
+// the criteria format of Navidrome .nsp files:
+//
+//	{"all": [{"is": {"filetype": "flac"}}, {"notInTheLast": {"lastPlayed": 30}}], "sort": "dateAdded", "order": "desc", "limit": 100}
+type SmartRules struct {
+	All   []SmartRule `json:"all,omitempty"`
+	Any   []SmartRule `json:"any,omitempty"`
+	Sort  string      `json:"sort,omitempty"`
+	Order string      `json:"order,omitempty"`
+	Limit int         `json:"limit,omitempty"`
+}
+
+// SmartRule is a single condition such as {"gt": {"rating": 3}}, or a nested group of rules
+// such as {"any": [...]}.
+type SmartRule struct {
+	Operator string
+	Field    string
+	Value    any
+	All      []SmartRule
+	Any      []SmartRule
+}
+
+// SmartPlaylistFile is the content of a Navidrome .nsp file.
+type SmartPlaylistFile struct {
+	Name    string `json:"name"`
+	Comment string `json:"comment,omitempty"`
+	SmartRules
+}
+
+func (r SmartRule) MarshalJSON() ([]byte, error) {
+	switch {
+	case r.All != nil:
+		return json.Marshal(map[string][]SmartRule{"all": r.All})
+	case r.Any != nil:
+		return json.Marshal(map[string][]SmartRule{"any": r.Any})
+	}
+	return json.Marshal(map[string]map[string]any{r.Operator: {r.Field: r.Value}})
+}
+
+func (r *SmartRule) UnmarshalJSON(data []byte) error {
+	var rule map[string]json.RawMessage
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return err
+	}
+	if len(rule) != 1 {
+		return errors.New("a smart playlist rule must have exactly one operator")
+	}
+	for op, body := range rule {
+		switch op {
+		case "all":
+			r.All = []SmartRule{}
+			return json.Unmarshal(body, &r.All)
+		case "any":
+			r.Any = []SmartRule{}
+			return json.Unmarshal(body, &r.Any)
+		}
+
+		var cond map[string]any
+		if err := json.Unmarshal(body, &cond); err != nil {
+			return fmt.Errorf("rule %q: %w", op, err)
+		}
+		if len(cond) != 1 {
+			return fmt.Errorf("rule %q must have exactly one field", op)
+		}
+		r.Operator = op
+		for field, value := range cond {
+			r.Field, r.Value = field, value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Subscription is a playlist or artist URI of Platform a user follows. Every Interval minutes
+// its songs are resolved again and the new ones downloaded. With MirrorPlaylist, the local
+// playlist PlaylistID follows the songs of the remote one once they are downloaded.
+// Tracks are the remote song IDs of the last sync, Library the library songs of the ones it
+// found in the library already, JobID its download job, and MirroredJobID the last job the
+// playlist was mirrored after.
+type Subscription struct {
+	ID             uint             `gorm:"primaryKey" json:"id"`
+	Username       string           `gorm:"index" json:"-"`
+	Platform       string           `json:"platform"`
+	URI            string           `json:"uri"`
+	Name           string           `json:"name"`
+	Interval       int              `json:"interval"`
+	Level          string           `json:"level"`
+	Output         string           `json:"output"`
+	Enabled        bool             `json:"enabled"`
+	MirrorPlaylist bool             `json:"mirrorPlaylist"`
+	PlaylistID     uint             `json:"playlistId,omitempty"`
+	Tracks         []int64          `gorm:"serializer:json" json:"-"`
+	Library        map[int64]string `gorm:"serializer:json" json:"-"`
+	JobID          uint             `json:"jobId,omitempty"`
+	MirroredJobID  uint             `json:"-"`
+	LastSyncAt     *time.Time       `json:"lastSyncAt,omitempty"`
+	NextSyncAt     time.Time        `gorm:"index" json:"nextSyncAt"`
+	CreatedAt      time.Time        `json:"createdAt"`
+	UpdatedAt      time.Time        `json:"updatedAt"`
+}
+
+// Actions of the history of subscriptions
+const (
+	SubscriptionSynced   = "synced"
+	SubscriptionMirrored = "mirrored"
+	SubscriptionFailed   = "failed"
+)
+
+// SubscriptionEvent is an entry of the history of a subscription: a sync queuing the new songs
+// in JobID, or the local playlist gaining Added songs and losing Removed ones.
+type SubscriptionEvent struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint      `gorm:"index" json:"subscriptionId"`
+	Action         string    `json:"action"`
+	JobID          uint      `json:"jobId,omitempty"`
+	Queued         int       `json:"queued"`
+	Skipped        int       `json:"skipped"`
+	Added          int       `json:"added"`
+	Removed        int       `json:"removed"`
+	Error          string    `json:"error,omitempty"`
+	CreatedAt      time.Time `gorm:"index" json:"createdAt"`
+}
@@ -142,6 +142,8 @@ type IndexID3 struct {
 type ArtistID3 struct {
 	ID             string     `gorm:"primaryKey" xml:"id,attr" json:"id"`
 	Name           string     `gorm:"index" xml:"name,attr" json:"name"`
+	SortName       string     `xml:"sortName,attr,omitempty" json:"sortName,omitempty"`
+	OrderName      string     `gorm:"index" xml:"-" json:"-"`
 	CoverArt       string     `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
 	ArtistImageUrl string     `xml:"artistImageUrl,attr,omitempty" json:"artistImageUrl,omitempty"`
 	AlbumCount     int        `gorm:"->;-:migration" xml:"albumCount,attr" json:"albumCount"`
@@ -160,6 +162,8 @@ type ArtistWithAlbumsID3 struct {
 type AlbumID3 struct {
 	ID            string      `gorm:"primaryKey" xml:"id,attr" json:"id"`
 	Name          string      `gorm:"index" xml:"name,attr" json:"name"`
+	SortName      string      `xml:"sortName,attr,omitempty" json:"sortName,omitempty"`
+	OrderName     string      `gorm:"index" xml:"-" json:"-"`
 	Artist        string      `gorm:"index" xml:"artist,attr,omitempty" json:"artist,omitempty"`
 	ArtistID      string      `gorm:"index" xml:"artistId,attr,omitempty" json:"artistId,omitempty"`
 	CoverArt      string      `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
@@ -171,11 +175,31 @@ type AlbumID3 struct {
 	Starred       *time.Time  `xml:"starred,attr,omitempty" json:"starred,omitempty"`
 	UserRating    int         `xml:"userRating,attr,omitempty" json:"userRating,omitempty"`
 	AverageRating float64     `xml:"averageRating,attr,omitempty" json:"averageRating,omitempty"`
-	Year          int         `xml:"year,attr,omitempty" json:"year,omitempty"`
+	Year          int         `gorm:"index" xml:"year,attr,omitempty" json:"year,omitempty"`
+	OriginalYear  int         `gorm:"index" xml:"-" json:"-"`
 	Genre         string      `xml:"genre,attr,omitempty" json:"genre,omitempty"`
+	MusicBrainzID string      `gorm:"index" xml:"musicBrainzId,attr,omitempty" json:"musicBrainzId,omitempty"`
+	IsCompilation bool        `xml:"isCompilation,attr,omitempty" json:"isCompilation,omitempty"`
+	DiscCount     int         `xml:"-" json:"-"`
+	ReleaseTypes  []string    `gorm:"serializer:json" xml:"releaseTypes,omitempty" json:"releaseTypes,omitempty"`
+	DiscTitles    []DiscTitle `gorm:"serializer:json" xml:"discTitles,omitempty" json:"discTitles,omitempty"`
+	ReleaseDate   *ItemDate   `gorm:"serializer:json" xml:"releaseDate,omitempty" json:"releaseDate,omitempty"`
+	OriginalDate  *ItemDate   `gorm:"serializer:json" xml:"originalReleaseDate,omitempty" json:"originalReleaseDate,omitempty"`
 	Artists       []ArtistID3 `gorm:"many2many:album_artists;" xml:"-" json:"-"`
 }
 
+// ItemDate is an OpenSubsonic date whose month and day may be unknown.
+type ItemDate struct {
+	Year  int `xml:"year,attr,omitempty" json:"year,omitempty"`
+	Month int `xml:"month,attr,omitempty" json:"month,omitempty"`
+	Day   int `xml:"day,attr,omitempty" json:"day,omitempty"`
+}
+
+type DiscTitle struct {
+	Disc  int    `xml:"disc,attr" json:"disc"`
+	Title string `xml:"title,attr" json:"title"`
+}
+
 type AlbumWithSongsID3 struct {
 	AlbumID3
 	Song []Child `xml:"song" json:"song"`
@@ -222,43 +246,61 @@ type Directory struct {
 	Parents       []Child    `xml:"-" json:"parents,omitempty"`
 }
 
+// Media types of a Child
+const (
+	MediaTypeMusic     = "music"
+	MediaTypeAudiobook = "audiobook"
+)
+
 type Child struct {
-	ID                    string      `gorm:"primaryKey" xml:"id,attr" json:"id"`
-	Parent                string      `gorm:"index" xml:"parent,attr,omitempty" json:"parent,omitempty"`
-	IsDir                 bool        `xml:"isDir,attr" json:"isDir"`
-	Title                 string      `gorm:"index" xml:"title,attr" json:"title"`
-	Album                 string      `gorm:"index" xml:"album,attr,omitempty" json:"album,omitempty"`
-	Artist                string      `gorm:"index" xml:"artist,attr,omitempty" json:"artist,omitempty"`
-	Track                 int         `xml:"track,attr,omitempty" json:"track,omitempty"`
-	Year                  int         `xml:"year,attr,omitempty" json:"year,omitempty"`
-	Genre                 string      `gorm:"index" xml:"genre,attr,omitempty" json:"genre,omitempty"`
-	CoverArt              string      `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
-	Size                  int64       `xml:"size,attr,omitempty" json:"size,omitempty"`
-	ContentType           string      `xml:"contentType,attr,omitempty" json:"contentType,omitempty"`
-	Suffix                string      `xml:"suffix,attr,omitempty" json:"suffix,omitempty"`
-	TranscodedContentType string      `xml:"transcodedContentType,attr,omitempty" json:"transcodedContentType,omitempty"`
-	TranscodedSuffix      string      `xml:"transcodedSuffix,attr,omitempty" json:"transcodedSuffix,omitempty"`
-	Duration              int         `xml:"duration,attr,omitempty" json:"duration,omitempty"`
-	BitRate               int         `xml:"bitRate,attr,omitempty" json:"bitRate,omitempty"`
-	Path                  string      `gorm:"uniqueIndex" xml:"path,attr,omitempty" json:"path,omitempty"`
-	IsVideo               bool        `xml:"isVideo,attr,omitempty" json:"isVideo,omitempty"`
-	UserRating            int         `xml:"userRating,attr,omitempty" json:"userRating,omitempty"`
-	AverageRating         float64     `xml:"averageRating,attr,omitempty" json:"averageRating,omitempty"`
-	PlayCount             int64       `xml:"playCount,attr,omitempty" json:"playCount,omitempty"`
-	LastPlayed            *time.Time  `xml:"lastPlayed,attr,omitempty" json:"lastPlayed,omitempty"`
-	DiscNumber            int         `xml:"discNumber,attr,omitempty" json:"discNumber,omitempty"`
-	Created               *time.Time  `xml:"created,attr,omitempty" json:"created,omitempty"`
-	Starred               *time.Time  `xml:"starred,attr,omitempty" json:"starred,omitempty"`
-	AlbumID               string      `gorm:"index" xml:"albumId,attr,omitempty" json:"albumId,omitempty"`
-	ArtistID              string      `gorm:"index" xml:"artistId,attr,omitempty" json:"artistId,omitempty"`
-	MusicFolderID         uint        `gorm:"index" xml:"-" json:"musicFolderId,omitempty"`
-	Type                  string      `xml:"type,attr,omitempty" json:"type,omitempty"`
-	BookmarkPosition      int64       `gorm:"-" xml:"bookmarkPosition,attr,omitempty" json:"bookmarkPosition,omitempty"`
-	OriginalWidth         int         `xml:"originalWidth,attr,omitempty" json:"originalWidth,omitempty"`
-	OriginalHeight        int         `xml:"originalHeight,attr,omitempty" json:"originalHeight,omitempty"`
-	Artists               []ArtistID3 `gorm:"many2many:song_artists;" xml:"-" json:"-"`
-	Genres                []Genre     `gorm:"many2many:song_genres;" xml:"-" json:"-"`
-	Lyrics                string      `xml:"-" json:"-"`
+	ID                    string        `gorm:"primaryKey" xml:"id,attr" json:"id"`
+	Parent                string        `gorm:"index" xml:"parent,attr,omitempty" json:"parent,omitempty"`
+	IsDir                 bool          `xml:"isDir,attr" json:"isDir"`
+	Title                 string        `gorm:"index" xml:"title,attr" json:"title"`
+	SortName              string        `xml:"sortName,attr,omitempty" json:"sortName,omitempty"`
+	OrderName             string        `gorm:"index" xml:"-" json:"-"`
+	Album                 string        `gorm:"index" xml:"album,attr,omitempty" json:"album,omitempty"`
+	Artist                string        `gorm:"index" xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	Track                 int           `xml:"track,attr,omitempty" json:"track,omitempty"`
+	Year                  int           `gorm:"index" xml:"year,attr,omitempty" json:"year,omitempty"`
+	OriginalYear          int           `gorm:"index" xml:"-" json:"-"`
+	ReleaseDate           *ItemDate     `gorm:"serializer:json" xml:"releaseDate,omitempty" json:"releaseDate,omitempty"`
+	OriginalDate          *ItemDate     `gorm:"serializer:json" xml:"originalReleaseDate,omitempty" json:"originalReleaseDate,omitempty"`
+	Genre                 string        `gorm:"index" xml:"genre,attr,omitempty" json:"genre,omitempty"`
+	DisplayComposer       string        `xml:"displayComposer,attr,omitempty" json:"displayComposer,omitempty"`
+	ISRC                  []string      `gorm:"serializer:json" xml:"isrc,omitempty" json:"isrc,omitempty"`
+	NeteaseID             int64         `gorm:"index" xml:"-" json:"-"`
+	Label                 string        `xml:"-" json:"-"`
+	Comment               string        `xml:"-" json:"-"`
+	CoverArt              string        `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	Size                  int64         `xml:"size,attr,omitempty" json:"size,omitempty"`
+	ContentType           string        `xml:"contentType,attr,omitempty" json:"contentType,omitempty"`
+	Suffix                string        `xml:"suffix,attr,omitempty" json:"suffix,omitempty"`
+	TranscodedContentType string        `xml:"transcodedContentType,attr,omitempty" json:"transcodedContentType,omitempty"`
+	TranscodedSuffix      string        `xml:"transcodedSuffix,attr,omitempty" json:"transcodedSuffix,omitempty"`
+	Duration              int           `xml:"duration,attr,omitempty" json:"duration,omitempty"`
+	BitRate               int           `xml:"bitRate,attr,omitempty" json:"bitRate,omitempty"`
+	Path                  string        `gorm:"uniqueIndex" xml:"path,attr,omitempty" json:"path,omitempty"`
+	IsVideo               bool          `xml:"isVideo,attr,omitempty" json:"isVideo,omitempty"`
+	UserRating            int           `xml:"userRating,attr,omitempty" json:"userRating,omitempty"`
+	AverageRating         float64       `xml:"averageRating,attr,omitempty" json:"averageRating,omitempty"`
+	PlayCount             int64         `xml:"playCount,attr,omitempty" json:"playCount,omitempty"`
+	LastPlayed            *time.Time    `xml:"lastPlayed,attr,omitempty" json:"lastPlayed,omitempty"`
+	DiscNumber            int           `xml:"discNumber,attr,omitempty" json:"discNumber,omitempty"`
+	Created               *time.Time    `xml:"created,attr,omitempty" json:"created,omitempty"`
+	Starred               *time.Time    `xml:"starred,attr,omitempty" json:"starred,omitempty"`
+	AlbumID               string        `gorm:"index" xml:"albumId,attr,omitempty" json:"albumId,omitempty"`
+	ArtistID              string        `gorm:"index" xml:"artistId,attr,omitempty" json:"artistId,omitempty"`
+	MusicFolderID         uint          `gorm:"index" xml:"-" json:"musicFolderId,omitempty"`
+	Type                  string        `gorm:"index" xml:"type,attr,omitempty" json:"type,omitempty"`
+	BookmarkPosition      int64         `gorm:"-" xml:"bookmarkPosition,attr,omitempty" json:"bookmarkPosition,omitempty"`
+	OriginalWidth         int           `xml:"originalWidth,attr,omitempty" json:"originalWidth,omitempty"`
+	OriginalHeight        int           `xml:"originalHeight,attr,omitempty" json:"originalHeight,omitempty"`
+	Artists               []ArtistID3   `gorm:"many2many:song_artists;" xml:"-" json:"-"`
+	Genres                []Genre       `gorm:"many2many:song_genres;" xml:"-" json:"-"`
+	Contributors          []Contributor `gorm:"-" xml:"contributors,omitempty" json:"contributors,omitempty"`
+	Chapters              []Chapter     `gorm:"-" xml:"chapter,omitempty" json:"chapters,omitempty"`
+	Lyrics                string        `xml:"-" json:"-"`
 }
 
 type NowPlaying struct {
@@ -304,17 +346,19 @@ type Playlists struct {
 }
 
 type Playlist struct {
-	ID          string    `xml:"id,attr" json:"id"`
-	Name        string    `xml:"name,attr" json:"name"`
-	Comment     string    `xml:"comment,attr,omitempty" json:"comment,omitempty"`
-	Owner       string    `xml:"owner,attr,omitempty" json:"owner,omitempty"`
-	Public      bool      `xml:"public,attr,omitempty" json:"public,omitempty"`
-	SongCount   int       `xml:"songCount,attr" json:"songCount"`
-	Duration    int       `xml:"duration,attr" json:"duration"`
-	Created     time.Time `xml:"created,attr" json:"created"`
-	Changed     time.Time `xml:"changed,attr" json:"changed"`
-	CoverArt    string    `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
-	AllowedUser []string  `xml:"allowedUser,omitempty" json:"allowedUser,omitempty"`
+	ID            string    `xml:"id,attr" json:"id"`
+	Name          string    `xml:"name,attr" json:"name"`
+	Comment       string    `xml:"comment,attr,omitempty" json:"comment,omitempty"`
+	Owner         string    `xml:"owner,attr,omitempty" json:"owner,omitempty"`
+	Public        bool      `xml:"public,attr,omitempty" json:"public,omitempty"`
+	SongCount     int       `xml:"songCount,attr" json:"songCount"`
+	Duration      int       `xml:"duration,attr" json:"duration"`
+	Created       time.Time `xml:"created,attr" json:"created"`
+	Changed       time.Time `xml:"changed,attr" json:"changed"`
+	CoverArt      string    `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	AllowedUser   []string  `xml:"allowedUser,omitempty" json:"allowedUser,omitempty"`
+	Readonly      bool      `xml:"readonly,attr,omitempty" json:"readonly,omitempty"`
+	Collaborative bool      `xml:"collaborative,attr,omitempty" json:"collaborative,omitempty"`
 }
 
 type PlaylistWithSongs struct {
@@ -567,3 +611,10 @@ func ArtistWithStats(db *gorm.DB) *gorm.DB {
 	return db.Select("artist_id3.*, COALESCE(stats.album_count, 0) AS album_count").
 		Joins("LEFT JOIN (SELECT artist_id3_id, COUNT(*) as album_count FROM album_artists GROUP BY artist_id3_id) stats ON stats.artist_id3_id = artist_id3.id")
 }
+
+// CreditedArtists leaves out the artists that are only credited as contributors of songs, such
+// as composers, which are browsed separately.
+func CreditedArtists(db *gorm.DB) *gorm.DB {
+	return db.Where("EXISTS (SELECT 1 FROM song_artists WHERE song_artists.artist_id3_id = artist_id3.id) OR " +
+		"EXISTS (SELECT 1 FROM album_artists WHERE album_artists.artist_id3_id = artist_id3.id)")
+}
@@ -0,0 +1,85 @@
+package albuminfo
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/infocache"
+	"github.com/stkevintan/miko/pkg/log"
+	"github.com/stkevintan/miko/pkg/musicbrainz"
+	"gorm.io/gorm"
+)
+
+const coverArtArchiveURL = "https://coverartarchive.org/release/"
+
+// releases looks releases up, it is the MusicBrainz client outside of tests.
+type releases interface {
+	SearchRelease(ctx context.Context, artist, album string) (*musicbrainz.Release, error)
+	GetRelease(ctx context.Context, id string) (*musicbrainz.Release, error)
+}
+
+// Manager fetches album info from MusicBrainz and caches it in the database.
+type Manager struct {
+	db *gorm.DB
+	mb releases
+}
+
+func New(db *gorm.DB) *Manager {
+	return &Manager{db: db, mb: musicbrainz.NewClient()}
+}
+
+// Get returns the info of album, from the cache when it hasn't expired. The release is the
+// one of the MUSICBRAINZ_ALBUMID tag of the album when it has one, otherwise it is searched by
+// artist and album name. When MusicBrainz can't be reached, a stale cached record is returned
+// rather than nothing.
+func (m *Manager) Get(ctx context.Context, album *models.AlbumID3) (*models.AlbumInfoRecord, error) {
+	var cached models.AlbumInfoRecord
+	if err := m.db.WithContext(ctx).Where("album_id = ?", album.ID).Limit(1).Find(&cached).Error; err != nil {
+		return nil, err
+	}
+	// a record of another release is outdated by a retag of the album
+	var stale *models.AlbumInfoRecord
+	if cached.AlbumID != "" && (album.MusicBrainzID == "" || album.MusicBrainzID == cached.MusicBrainzID) {
+		stale = &cached
+	}
+
+	return infocache.Get(ctx, m.db, stale, expiresAt, func(ctx context.Context) (*models.AlbumInfoRecord, bool, error) {
+		record, err := m.fetch(ctx, album)
+		if err != nil {
+			log.Debug("Failed to get info of album %s from MusicBrainz: %v", album.Name, err)
+		}
+		record.AlbumID = album.ID
+		return record, record.MusicBrainzID != "", err
+	})
+}
+
+func expiresAt(record *models.AlbumInfoRecord) *time.Time {
+	return &record.ExpiresAt
+}
+
+// fetch looks the album up on MusicBrainz. The record holds what is known even when it fails,
+// such as the release of a tagged album.
+func (m *Manager) fetch(ctx context.Context, album *models.AlbumID3) (*models.AlbumInfoRecord, error) {
+	record := &models.AlbumInfoRecord{MusicBrainzID: album.MusicBrainzID}
+	if record.MusicBrainzID == "" {
+		release, err := m.mb.SearchRelease(ctx, album.Artist, album.Name)
+		if err != nil {
+			return record, err
+		}
+		record.MusicBrainzID = release.ID
+	}
+
+	release, err := m.mb.GetRelease(ctx, record.MusicBrainzID)
+	if err != nil {
+		return record, err
+	}
+	record.Notes = strings.TrimSpace(release.Annotation)
+	if release.CoverArt.Front {
+		record.SmallImageURL = coverArtArchiveURL + release.ID + "/front-250"
+		record.MediumImageURL = coverArtArchiveURL + release.ID + "/front-500"
+		record.LargeImageURL = coverArtArchiveURL + release.ID + "/front-1200"
+	}
+	return record, nil
+}
@@ -0,0 +1,135 @@
+package albuminfo
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/musicbrainz"
+	"gorm.io/gorm"
+)
+
+// fakeReleases knows the releases by ID, searches find the release titled after the album.
+type fakeReleases struct {
+	releases map[string]*musicbrainz.Release
+	err      error
+	searches int
+	gets     int
+}
+
+func (f *fakeReleases) SearchRelease(_ context.Context, _, album string) (*musicbrainz.Release, error) {
+	f.searches++
+	if f.err != nil {
+		return nil, f.err
+	}
+	for _, r := range f.releases {
+		if r.Title == album {
+			return r, nil
+		}
+	}
+	return nil, errors.New("no results found")
+}
+
+func (f *fakeReleases) GetRelease(_ context.Context, id string) (*musicbrainz.Release, error) {
+	f.gets++
+	if f.err != nil {
+		return nil, f.err
+	}
+	if r, ok := f.releases[id]; ok {
+		return r, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func newTestManager(t *testing.T) (*Manager, *fakeReleases) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.AlbumInfoRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	mb := &fakeReleases{releases: map[string]*musicbrainz.Release{
+		"tagged":   {ID: "tagged", Title: "Other Title", Annotation: " Notes ", CoverArt: musicbrainz.CoverArt{Front: true}},
+		"searched": {ID: "searched", Title: "Album"},
+	}}
+	return &Manager{db: db, mb: mb}, mb
+}
+
+func TestGet_Tagged(t *testing.T) {
+	m, mb := newTestManager(t)
+	album := &models.AlbumID3{ID: "al", Name: "Album", MusicBrainzID: "tagged"}
+
+	info, err := m.Get(context.Background(), album)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MusicBrainzID != "tagged" || info.Notes != "Notes" || info.LargeImageURL != coverArtArchiveURL+"tagged/front-1200" {
+		t.Errorf("Get() = %+v, want the tagged release", info)
+	}
+	if mb.searches != 0 {
+		t.Errorf("Get() searched a tagged album %d times", mb.searches)
+	}
+	if time.Until(info.ExpiresAt) < 24*time.Hour {
+		t.Errorf("Get() = expires at %v, want cached for long", info.ExpiresAt)
+	}
+
+	// cached until a retag
+	if _, err := m.Get(context.Background(), album); err != nil || mb.gets != 1 {
+		t.Errorf("Get() again = %v, after %d requests, want the cached record", err, mb.gets)
+	}
+	album.MusicBrainzID = "searched"
+	if info, err := m.Get(context.Background(), album); err != nil || info.MusicBrainzID != "searched" {
+		t.Errorf("Get() after a retag = %+v, %v, want the new release", info, err)
+	}
+}
+
+func TestGet_Search(t *testing.T) {
+	m, mb := newTestManager(t)
+
+	info, err := m.Get(context.Background(), &models.AlbumID3{ID: "al", Name: "Album", Artist: "X"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MusicBrainzID != "searched" || mb.searches != 1 {
+		t.Errorf("Get() = %+v after %d searches, want the searched release", info, mb.searches)
+	}
+
+	// an album MusicBrainz doesn't know is asked about again sooner
+	info, err = m.Get(context.Background(), &models.AlbumID3{ID: "unknown", Name: "Unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MusicBrainzID != "" || time.Until(info.ExpiresAt) > 24*time.Hour {
+		t.Errorf("Get() of an unknown album = %+v, want an empty record retried soon", info)
+	}
+}
+
+func TestGet_StaleOnError(t *testing.T) {
+	m, mb := newTestManager(t)
+	stale := models.AlbumInfoRecord{AlbumID: "al", MusicBrainzID: "searched", Notes: "old", ExpiresAt: time.Now().Add(-time.Hour)}
+	m.db.Create(&stale)
+	mb.err = errors.New("unreachable")
+
+	info, err := m.Get(context.Background(), &models.AlbumID3{ID: "al", Name: "Album"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Notes != "old" || mb.searches != 1 {
+		t.Errorf("Get() = %+v after %d searches, want the stale record", info, mb.searches)
+	}
+
+	// without a stale record, the failure is cached briefly
+	info, err = m.Get(context.Background(), &models.AlbumID3{ID: "new", Name: "New", MusicBrainzID: "tagged"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MusicBrainzID != "tagged" || time.Until(info.ExpiresAt) > 24*time.Hour {
+		t.Errorf("Get() = %+v, want the tagged release retried soon", info)
+	}
+}
@@ -0,0 +1,110 @@
+package artistinfo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/infocache"
+	"github.com/stkevintan/miko/pkg/log"
+	"gorm.io/gorm"
+)
+
+// Source is a service that knows about artists. Fields of the returned record that the source
+// doesn't know are left empty.
+type Source interface {
+	Name() string
+	ArtistInfo(ctx context.Context, name string) (*models.ArtistInfoRecord, error)
+}
+
+// Manager fetches artist info from its sources and caches it in the database.
+type Manager struct {
+	db      *gorm.DB
+	sources []Source
+}
+
+// New creates a manager asking the sources in order, the first source that knows a field
+// wins it.
+func New(db *gorm.DB, sources ...Source) *Manager {
+	return &Manager{db: db, sources: sources}
+}
+
+// Get returns the info of the artist named name, from the cache when it hasn't expired.
+// When every source fails, a stale cached record is returned rather than nothing.
+func (m *Manager) Get(ctx context.Context, name string) (*models.ArtistInfoRecord, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	var cached models.ArtistInfoRecord
+	if err := m.db.WithContext(ctx).Where("name = ?", key).Limit(1).Find(&cached).Error; err != nil {
+		return nil, err
+	}
+	var stale *models.ArtistInfoRecord
+	if cached.Name != "" {
+		stale = &cached
+	}
+
+	return infocache.Get(ctx, m.db, stale, expiresAt, func(ctx context.Context) (*models.ArtistInfoRecord, bool, error) {
+		infos := make([]*models.ArtistInfoRecord, 0, len(m.sources))
+		var errs []error
+		for _, source := range m.sources {
+			info, err := source.ArtistInfo(ctx, name)
+			if err != nil {
+				log.Debug("Failed to get info of artist %s from %s: %v", name, source.Name(), err)
+				errs = append(errs, err)
+				continue
+			}
+			infos = append(infos, info)
+		}
+		record := merge(infos)
+		record.Name = key
+		var err error
+		if len(infos) == 0 {
+			err = errors.Join(errs...)
+		}
+		return record, len(errs) == 0 && !isEmpty(record), err
+	})
+}
+
+func expiresAt(record *models.ArtistInfoRecord) *time.Time {
+	return &record.ExpiresAt
+}
+
+// merge combines the records of the sources, the first non-empty value of a field wins while
+// links and similar artists are collected from all of them.
+func merge(infos []*models.ArtistInfoRecord) *models.ArtistInfoRecord {
+	merged := &models.ArtistInfoRecord{URLs: []models.ArtistURL{}, Similar: []string{}}
+	seenURLs := make(map[string]bool)
+	seenSimilar := make(map[string]bool)
+	first := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = strings.TrimSpace(src)
+		}
+	}
+	for _, info := range infos {
+		first(&merged.Biography, info.Biography)
+		first(&merged.MusicBrainzID, info.MusicBrainzID)
+		first(&merged.LastFmURL, info.LastFmURL)
+		first(&merged.SmallImageURL, info.SmallImageURL)
+		first(&merged.MediumImageURL, info.MediumImageURL)
+		first(&merged.LargeImageURL, info.LargeImageURL)
+		for _, u := range info.URLs {
+			if !seenURLs[u.URL] {
+				seenURLs[u.URL] = true
+				merged.URLs = append(merged.URLs, u)
+			}
+		}
+		for _, name := range info.Similar {
+			if key := strings.ToLower(name); !seenSimilar[key] {
+				seenSimilar[key] = true
+				merged.Similar = append(merged.Similar, name)
+			}
+		}
+	}
+	return merged
+}
+
+func isEmpty(record *models.ArtistInfoRecord) bool {
+	return record.Biography == "" && record.MusicBrainzID == "" && record.LargeImageURL == "" &&
+		len(record.URLs) == 0 && len(record.Similar) == 0
+}
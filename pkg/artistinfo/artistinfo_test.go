@@ -0,0 +1,46 @@
+package artistinfo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stkevintan/miko/models"
+)
+
+func TestMerge(t *testing.T) {
+	mb := &models.ArtistInfoRecord{
+		MusicBrainzID: "mbid",
+		URLs:          []models.ArtistURL{{Type: "official homepage", URL: "https://example.com"}},
+	}
+	provider := &models.ArtistInfoRecord{
+		MusicBrainzID: "ignored",
+		Biography:     " Biography ",
+		LargeImageURL: "https://img/large",
+		URLs:          []models.ArtistURL{{Type: "netease", URL: "https://example.com"}, {Type: "netease", URL: "https://music"}},
+		Similar:       []string{"A", "B", "a"},
+	}
+
+	got := merge([]*models.ArtistInfoRecord{mb, provider})
+	if got.MusicBrainzID != "mbid" || got.Biography != "Biography" || got.LargeImageURL != "https://img/large" {
+		t.Errorf("merge = %+v", got)
+	}
+	wantURLs := []models.ArtistURL{{Type: "official homepage", URL: "https://example.com"}, {Type: "netease", URL: "https://music"}}
+	if !reflect.DeepEqual(got.URLs, wantURLs) {
+		t.Errorf("URLs = %v, want %v", got.URLs, wantURLs)
+	}
+	if want := []string{"A", "B"}; !reflect.DeepEqual(got.Similar, want) {
+		t.Errorf("Similar = %v, want %v", got.Similar, want)
+	}
+	if !isEmpty(merge(nil)) {
+		t.Error("merge of no sources isn't empty")
+	}
+}
+
+func TestImageURL(t *testing.T) {
+	if got := imageURL("https://p1.music.126.net/a.jpg", 64); got != "https://p1.music.126.net/a.jpg?param=64y64" {
+		t.Errorf("imageURL = %q", got)
+	}
+	if got := imageURL("https://img?x=1", 300); got != "https://img?x=1&param=300y300" {
+		t.Errorf("imageURL = %q", got)
+	}
+}
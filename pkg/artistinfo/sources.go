@@ -0,0 +1,92 @@
+package artistinfo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/musicbrainz"
+	"github.com/stkevintan/miko/pkg/provider"
+)
+
+// MusicBrainz is a source of MusicBrainz IDs and links of artists.
+type MusicBrainz struct {
+	mb *musicbrainz.Client
+}
+
+func NewMusicBrainz() *MusicBrainz {
+	return &MusicBrainz{mb: musicbrainz.NewClient()}
+}
+
+func (s *MusicBrainz) Name() string {
+	return "musicbrainz"
+}
+
+func (s *MusicBrainz) ArtistInfo(ctx context.Context, name string) (*models.ArtistInfoRecord, error) {
+	found, err := s.mb.SearchArtist(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	artist, err := s.mb.GetArtist(ctx, found.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &models.ArtistInfoRecord{MusicBrainzID: artist.ID}
+	info.URLs = append(info.URLs, models.ArtistURL{Type: "musicbrainz", URL: "https://musicbrainz.org/artist/" + artist.ID})
+	for _, rel := range artist.Relations {
+		if rel.URL.Resource == "" {
+			continue
+		}
+		info.URLs = append(info.URLs, models.ArtistURL{Type: rel.Type, URL: rel.URL.Resource})
+		if info.LastFmURL == "" && strings.Contains(rel.URL.Resource, "last.fm/") {
+			info.LastFmURL = rel.URL.Resource
+		}
+	}
+	return info, nil
+}
+
+// Provider is a source of biographies, pictures and similar artists from a music provider.
+type Provider struct {
+	name string
+	p    provider.ArtistProvider
+}
+
+func NewProvider(name string, p provider.ArtistProvider) *Provider {
+	return &Provider{name: name, p: p}
+}
+
+func (s *Provider) Name() string {
+	return s.name
+}
+
+func (s *Provider) ArtistInfo(ctx context.Context, name string) (*models.ArtistInfoRecord, error) {
+	artist, err := s.p.ArtistInfo(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &models.ArtistInfoRecord{Biography: artist.Description}
+	if artist.PicUrl != "" {
+		info.SmallImageURL = imageURL(artist.PicUrl, 64)
+		info.MediumImageURL = imageURL(artist.PicUrl, 174)
+		info.LargeImageURL = imageURL(artist.PicUrl, 300)
+	}
+	if artist.URL != "" {
+		info.URLs = append(info.URLs, models.ArtistURL{Type: s.name, URL: artist.URL})
+	}
+	for _, similar := range artist.Similar {
+		info.Similar = append(info.Similar, similar.Name)
+	}
+	return info, nil
+}
+
+// imageURL asks the image server of the provider for a square picture of the given size.
+func imageURL(picURL string, size int) string {
+	sep := "?"
+	if strings.Contains(picURL, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%sparam=%dy%d", picURL, sep, size, size)
+}
@@ -59,6 +59,30 @@ func (m *Manager) CreateBookmark(username, songID string, position int64, commen
 	return m.db.Save(&record).Error
 }
 
+// SaveProgress bookmarks the playback position of an audiobook so it can be resumed from any
+// client. An existing bookmark keeps its comment, positions of other media are ignored.
+func (m *Manager) SaveProgress(username, songID string, position int64) error {
+	if songID == "" || position <= 0 {
+		return nil
+	}
+	var song models.Child
+	if err := m.db.Select("id, type").Where("id = ?", songID).First(&song).Error; err != nil {
+		return err
+	}
+	if song.Type != models.MediaTypeAudiobook {
+		return nil
+	}
+
+	var record models.BookmarkRecord
+	if err := m.db.Where(models.BookmarkRecord{Username: username, SongID: songID}).
+		Attrs(models.BookmarkRecord{Comment: "Auto bookmark"}).
+		FirstOrInit(&record).Error; err != nil {
+		return err
+	}
+	record.Position = position
+	return m.db.Save(&record).Error
+}
+
 func (m *Manager) DeleteBookmark(username, songID string) error {
 	return m.db.Where("username = ? AND song_id = ?", username, songID).Delete(&models.BookmarkRecord{}).Error
 }
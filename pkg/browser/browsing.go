@@ -5,13 +5,15 @@ import (
 	"strings"
 
 	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/collation"
 	"github.com/stkevintan/miko/pkg/log"
 )
 
 func (b *Browser) GetIndexes(folderID uint, hasFolderId bool, ignoredArticles string) ([]models.Index, error) {
 	indexMap := make(map[string][]models.Artist)
+	sortKeys := make(map[string]string)
 	var children []models.Child
-	query := b.db.Model(&models.Child{}).Select("id, title, is_dir, parent, music_folder_id").Where("is_dir = ?", true).Where("parent = ?", "")
+	query := b.db.Model(&models.Child{}).Select("id, title, sort_name, is_dir, parent, music_folder_id").Where("is_dir = ?", true).Where("parent = ?", "")
 	if hasFolderId {
 		query = query.Where("music_folder_id = ?", folderID)
 	}
@@ -28,23 +30,27 @@ func (b *Browser) GetIndexes(folderID uint, hasFolderId bool, ignoredArticles st
 			continue
 		}
 
-		sortName := stripArticles(name, articles)
+		if child.SortName != "" {
+			name = child.SortName
+		}
+		sortKey := collation.SortKey(collation.StripArticles(name, articles))
+		sortKeys[child.ID] = sortKey
 
-		firstChar := strings.ToUpper(string([]rune(sortName)[0]))
-		indexMap[firstChar] = append(indexMap[firstChar], models.Artist{
+		index := collation.IndexName(sortKey)
+		indexMap[index] = append(indexMap[index], models.Artist{
 			ID:   child.ID,
 			Name: child.Title,
 		})
 	}
 
-	return b.mapToIndexes(indexMap), nil
+	return b.mapToIndexes(indexMap, sortKeys), nil
 }
 
-func (b *Browser) mapToIndexes(indexMap map[string][]models.Artist) []models.Index {
+func (b *Browser) mapToIndexes(indexMap map[string][]models.Artist, sortKeys map[string]string) []models.Index {
 	var indexes []models.Index
 	for char, artists := range indexMap {
 		sort.Slice(artists, func(i, j int) bool {
-			return artists[i].Name < artists[j].Name
+			return sortKeys[artists[i].ID] < sortKeys[artists[j].ID]
 		})
 		indexes = append(indexes, models.Index{
 			Name:   char,
@@ -76,11 +82,14 @@ func (b *Browser) GetDirectory(id string, offset, limit int) (*models.Directory,
 		query = query.Limit(limit).Offset(offset)
 	}
 
-	if err := query.Order("is_dir DESC, title ASC").Find(&children).Error; err != nil {
+	if err := query.Order("is_dir DESC, order_name ASC, title ASC").Find(&children).Error; err != nil {
 		log.Error("GetDirectory find error: %v", err)
 		return nil, err
 	}
 	log.Debug("GetDirectory found %d children", len(children))
+	if err := b.LoadChapters(children); err != nil {
+		log.Warn("GetDirectory chapters error: %v", err)
+	}
 
 	var parents []models.Child
 	if dir.Parent != "" {
@@ -134,7 +143,8 @@ func (b *Browser) GetGenres() ([]models.Genre, error) {
 
 func (b *Browser) GetArtists(ignoredArticles string) ([]models.IndexID3, error) {
 	var artists []models.ArtistID3
-	if err := b.db.Scopes(models.ArtistWithStats).Find(&artists).Error; err != nil {
+	err := b.db.Scopes(models.ArtistWithStats, models.CreditedArtists).Find(&artists).Error
+	if err != nil {
 		return nil, err
 	}
 
@@ -145,16 +155,26 @@ func (b *Browser) GetArtists(ignoredArticles string) ([]models.IndexID3, error)
 			continue
 		}
 
-		name := stripArticles(artist.Name, articles)
+		// Artists indexed before sort keys were introduced get one computed on the fly
+		if artist.OrderName == "" {
+			name := artist.Name
+			if artist.SortName != "" {
+				name = artist.SortName
+			}
+			artist.OrderName = collation.SortKey(collation.StripArticles(name, articles))
+		}
 
-		firstChar := strings.ToUpper(string([]rune(name)[0]))
-		indexMap[firstChar] = append(indexMap[firstChar], artist)
+		index := collation.IndexName(artist.OrderName)
+		indexMap[index] = append(indexMap[index], artist)
 	}
 
 	var indexes []models.IndexID3
 	for char, artists := range indexMap {
 		sort.Slice(artists, func(i, j int) bool {
-			return artists[i].Name < artists[j].Name
+			if artists[i].OrderName == artists[j].OrderName {
+				return artists[i].Name < artists[j].Name
+			}
+			return artists[i].OrderName < artists[j].OrderName
 		})
 		indexes = append(indexes, models.IndexID3{
 			Name:   char,
@@ -196,6 +216,12 @@ func (b *Browser) GetAlbum(id string) (*models.AlbumWithSongsID3, error) {
 	b.db.Where("album_id = ?", id).
 		Order("disc_number, track").
 		Find(&songs)
+	if err := b.LoadContributors(songs); err != nil {
+		log.Warn("Failed to load contributors for album %s: %v", id, err)
+	}
+	if err := b.LoadChapters(songs); err != nil {
+		log.Warn("Failed to load chapters for album %s: %v", id, err)
+	}
 
 	return &models.AlbumWithSongsID3{
 		AlbumID3: album,
@@ -208,16 +234,12 @@ func (b *Browser) GetSong(id string) (*models.Child, error) {
 	if err := b.db.Where("id = ? AND is_dir = ?", id, false).First(&song).Error; err != nil {
 		return nil, err
 	}
-	return &song, nil
-}
-
-func stripArticles(name string, articles []string) string {
-	upperName := strings.ToUpper(name)
-	for _, article := range articles {
-		prefix := strings.ToUpper(article) + " "
-		if strings.HasPrefix(upperName, prefix) {
-			return name[len(prefix):]
-		}
+	songs := []models.Child{song}
+	if err := b.LoadContributors(songs); err != nil {
+		return nil, err
+	}
+	if err := b.LoadChapters(songs); err != nil {
+		return nil, err
 	}
-	return name
+	return &songs[0], nil
 }
@@ -0,0 +1,112 @@
+package browser
+
+import (
+	"github.com/stkevintan/miko/models"
+)
+
+// LoadContributors fills the contributor credits of the given songs.
+func (b *Browser) LoadContributors(songs []models.Child) error {
+	if len(songs) == 0 {
+		return nil
+	}
+	ids := make([]string, len(songs))
+	for i, s := range songs {
+		ids[i] = s.ID
+	}
+
+	var links []models.SongContributor
+	if err := b.db.Where("child_id IN ?", ids).Order("role, sub_role").Find(&links).Error; err != nil {
+		return err
+	}
+	if len(links) == 0 {
+		return nil
+	}
+
+	artistIDs := make([]string, 0, len(links))
+	for _, l := range links {
+		artistIDs = append(artistIDs, l.ArtistID)
+	}
+	var artists []models.ArtistID3
+	if err := b.db.Where("id IN ?", artistIDs).Find(&artists).Error; err != nil {
+		return err
+	}
+	artistMap := make(map[string]models.ArtistID3, len(artists))
+	for _, a := range artists {
+		artistMap[a.ID] = a
+	}
+
+	contributors := make(map[string][]models.Contributor)
+	for _, l := range links {
+		artist, ok := artistMap[l.ArtistID]
+		if !ok {
+			continue
+		}
+		contributors[l.ChildID] = append(contributors[l.ChildID], models.Contributor{
+			Role:    l.Role,
+			SubRole: l.SubRole,
+			Artist:  artist,
+		})
+	}
+	for i := range songs {
+		songs[i].Contributors = contributors[songs[i].ID]
+	}
+	return nil
+}
+
+// LoadChapters fills the chapter markers of the given audiobook songs.
+func (b *Browser) LoadChapters(songs []models.Child) error {
+	var ids []string
+	for _, s := range songs {
+		if s.Type == models.MediaTypeAudiobook {
+			ids = append(ids, s.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var chapters []models.Chapter
+	if err := b.db.Where("child_id IN ?", ids).Order("child_id, number").Find(&chapters).Error; err != nil {
+		return err
+	}
+	byChild := make(map[string][]models.Chapter)
+	for _, c := range chapters {
+		byChild[c.ChildID] = append(byChild[c.ChildID], c)
+	}
+	for i := range songs {
+		songs[i].Chapters = byChild[songs[i].ID]
+	}
+	return nil
+}
+
+// GetContributors lists artists credited in role, optionally filtered by name.
+func (b *Browser) GetContributors(role, query string, count, offset int) ([]models.ContributorArtist, error) {
+	var artists []models.ContributorArtist
+	dbQuery := b.db.Table("artist_id3").
+		Select("artist_id3.*, song_contributors.role, COUNT(DISTINCT song_contributors.child_id) AS song_count").
+		Joins("JOIN song_contributors ON song_contributors.artist_id = artist_id3.id").
+		Where("song_contributors.role = ?", role).
+		Group("artist_id3.id, song_contributors.role").
+		Order("artist_id3.order_name ASC, artist_id3.name ASC").
+		Limit(count).Offset(offset)
+	if query != "" {
+		dbQuery = dbQuery.Where("artist_id3.name LIKE ?", "%"+query+"%")
+	}
+	err := dbQuery.Scan(&artists).Error
+	return artists, err
+}
+
+// GetContributorSongs lists the songs credited to an artist in role.
+func (b *Browser) GetContributorSongs(artistID, role string, count, offset int) ([]models.Child, error) {
+	var songs []models.Child
+	err := b.db.Joins("JOIN song_contributors ON song_contributors.child_id = children.id").
+		Where("song_contributors.artist_id = ? AND song_contributors.role = ?", artistID, role).
+		Group("children.id").
+		Order("children.album, children.disc_number, children.track").
+		Limit(count).Offset(offset).
+		Find(&songs).Error
+	if err != nil {
+		return nil, err
+	}
+	return songs, b.LoadContributors(songs)
+}
@@ -0,0 +1,85 @@
+package browser
+
+import (
+	"strings"
+
+	"github.com/stkevintan/miko/models"
+	"gorm.io/gorm"
+)
+
+// ArtistName returns the name of the artist with the given ID3 id, or of the artist directory
+// with the given id. gorm.ErrRecordNotFound is returned when the id is unknown.
+func (b *Browser) ArtistName(id string) (string, error) {
+	var artist models.ArtistID3
+	if err := b.db.Select("name").Where("id = ?", id).Limit(1).Find(&artist).Error; err != nil {
+		return "", err
+	}
+	if artist.Name != "" {
+		return artist.Name, nil
+	}
+
+	var dir models.Child
+	if err := b.db.Select("title").Where("id = ? AND is_dir = ?", id, true).Limit(1).Find(&dir).Error; err != nil {
+		return "", err
+	}
+	if dir.Title == "" {
+		return "", gorm.ErrRecordNotFound
+	}
+	return dir.Title, nil
+}
+
+// LibraryArtists returns up to count artists of the library named like one of names, in the
+// order of names. Names are compared ignoring case.
+func (b *Browser) LibraryArtists(names []string, count int) ([]models.ArtistID3, error) {
+	if len(names) == 0 || count <= 0 {
+		return []models.ArtistID3{}, nil
+	}
+	lower := make([]string, len(names))
+	for i, name := range names {
+		lower[i] = strings.ToLower(name)
+	}
+
+	var found []models.ArtistID3
+	if err := b.db.Scopes(models.ArtistWithStats, models.CreditedArtists).Where("LOWER(artist_id3.name) IN ?", lower).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	byName := make(map[string]models.ArtistID3, len(found))
+	for _, artist := range found {
+		if _, ok := byName[strings.ToLower(artist.Name)]; !ok {
+			byName[strings.ToLower(artist.Name)] = artist
+		}
+	}
+
+	artists := make([]models.ArtistID3, 0, min(len(found), count))
+	for _, name := range lower {
+		if artist, ok := byName[name]; ok && len(artists) < count {
+			artists = append(artists, artist)
+			delete(byName, name)
+		}
+	}
+	return artists, nil
+}
+
+// InfoAlbum returns the album with the given ID3 id, or the album of the songs in the
+// directory with the given id. gorm.ErrRecordNotFound is returned when the id is unknown.
+func (b *Browser) InfoAlbum(id string) (*models.AlbumID3, error) {
+	var album models.AlbumID3
+	if err := b.db.Where("id = ?", id).Limit(1).Find(&album).Error; err != nil {
+		return nil, err
+	}
+	if album.ID != "" {
+		return &album, nil
+	}
+
+	var song models.Child
+	err := b.db.Select("album_id").
+		Where("parent = ? AND is_dir = ? AND album_id != ''", id, false).
+		Limit(1).Find(&song).Error
+	if err != nil {
+		return nil, err
+	}
+	if song.AlbumID == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &album, b.db.Where("id = ?", song.AlbumID).First(&album).Error
+}
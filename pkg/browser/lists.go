@@ -25,6 +25,11 @@ func (b *Browser) GetAlbums(opts AlbumListOptions) ([]models.AlbumID3, error) {
 			Group("album_id3.id")
 	}
 
+	// Audiobooks are kept out of music lists, starring one still lists it
+	if opts.Type != "starred" {
+		dbQuery = dbQuery.Where("NOT EXISTS (SELECT 1 FROM children AS books WHERE books.album_id = album_id3.id AND books.type = ?)", models.MediaTypeAudiobook)
+	}
+
 	switch opts.Type {
 	case "random":
 		dbQuery = dbQuery.Order("RANDOM()")
@@ -38,11 +43,20 @@ func (b *Browser) GetAlbums(opts AlbumListOptions) ([]models.AlbumID3, error) {
 	case "starred":
 		dbQuery = dbQuery.Where("album_id3.starred IS NOT NULL").Order("album_id3.starred DESC")
 	case "alphabeticalByName":
-		dbQuery = dbQuery.Order("album_id3.name ASC")
+		dbQuery = dbQuery.Order("album_id3.order_name ASC, album_id3.name ASC")
 	case "alphabeticalByArtist":
-		dbQuery = dbQuery.Order("album_id3.artist ASC")
-	case "byYear":
-		dbQuery = dbQuery.Where("album_id3.year >= ? AND album_id3.year <= ?", opts.FromYear, opts.ToYear).Order("album_id3.year DESC")
+		dbQuery = dbQuery.Joins("LEFT JOIN artist_id3 AS album_artist ON album_artist.id = album_id3.artist_id").
+			Order("album_artist.order_name ASC, album_id3.artist ASC, album_id3.order_name ASC")
+	case "byYear", "byDecade":
+		// Reissues are listed under the year they were originally released in
+		year := "COALESCE(NULLIF(album_id3.original_year, 0), album_id3.year)"
+		fromYear, toYear, dir := opts.FromYear, opts.ToYear, "ASC"
+		if fromYear > toYear {
+			fromYear, toYear, dir = toYear, fromYear, "DESC"
+		}
+		dbQuery = dbQuery.Where(year+" BETWEEN ? AND ?", fromYear, toYear).
+			Order(year + " " + dir).
+			Order("album_id3.order_name ASC")
 	case "byGenre":
 		if opts.Genre != "" {
 			dbQuery = dbQuery.Joins("JOIN album_genres ON album_genres.album_id3_id = album_id3.id").
@@ -58,7 +72,7 @@ func (b *Browser) GetAlbums(opts AlbumListOptions) ([]models.AlbumID3, error) {
 
 func (b *Browser) GetRandomSongs(opts AlbumListOptions) ([]models.Child, error) {
 	var songs []models.Child
-	dbQuery := b.db.Where("is_dir = ?", false).Limit(opts.Size).Order("RANDOM()")
+	dbQuery := b.db.Where("is_dir = ? AND type <> ?", false, models.MediaTypeAudiobook).Limit(opts.Size).Order("RANDOM()")
 
 	if opts.HasFolderID {
 		dbQuery = dbQuery.Where("music_folder_id = ?", opts.MusicFolderID)
@@ -82,7 +96,7 @@ func (b *Browser) GetRandomSongs(opts AlbumListOptions) ([]models.Child, error)
 func (b *Browser) GetSongsByGenre(genre string, count, offset int, folderID uint, hasFolderID bool) ([]models.Child, error) {
 	var songs []models.Child
 	dbQuery := b.db.Joins("JOIN song_genres ON song_genres.child_id = children.id").
-		Where("song_genres.genre_name = ?", genre)
+		Where("song_genres.genre_name = ? AND children.type <> ?", genre, models.MediaTypeAudiobook)
 
 	if hasFolderID {
 		dbQuery = dbQuery.Where("children.music_folder_id = ?", folderID)
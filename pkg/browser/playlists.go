@@ -2,16 +2,24 @@ package browser
 
 import (
 	"strconv"
+	"strings"
 
 	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/log"
+	"github.com/stkevintan/miko/pkg/playlists"
+	"gorm.io/gorm"
 )
 
+// GetPlaylists lists the playlists of targetUsername visible to username. Users listing their
+// own playlists also get the playlists shared with them.
 func (b *Browser) GetPlaylists(username, targetUsername string) ([]models.Playlist, error) {
 	var playlists []models.PlaylistRecord
-	dbQuery := b.db.Model(&models.PlaylistRecord{})
-	dbQuery = dbQuery.Where("owner = ?", targetUsername)
-	if targetUsername != username {
-		dbQuery = dbQuery.Where("public = ?", true)
+	dbQuery := b.db.Model(&models.PlaylistRecord{}).Preload("Collaborators")
+	sharedWithUser := b.db.Model(&models.PlaylistCollaborator{}).Select("playlist_id").Where("username = ?", username)
+	if targetUsername == username {
+		dbQuery = dbQuery.Where("owner = ? OR id IN (?)", username, sharedWithUser)
+	} else {
+		dbQuery = dbQuery.Where("owner = ? AND (public = ? OR id IN (?))", targetUsername, true, sharedWithUser)
 	}
 	if err := dbQuery.Find(&playlists).Error; err != nil {
 		return nil, err
@@ -47,59 +55,162 @@ func (b *Browser) GetPlaylists(username, targetUsername string) ([]models.Playli
 
 		for _, p := range playlists {
 			s := statsMap[p.ID]
-			subsonicPlaylists = append(subsonicPlaylists, models.Playlist{
-				ID:        strconv.FormatUint(uint64(p.ID), 10),
-				Name:      p.Name,
-				Comment:   p.Comment,
-				Owner:     p.Owner,
-				Public:    p.Public,
-				SongCount: s.SongCount,
-				Duration:  s.Duration,
-				Created:   p.CreatedAt,
-				Changed:   p.UpdatedAt,
-			})
+			if p.IsSmart() {
+				count, duration, err := b.smartStats(p.Rules)
+				if err != nil {
+					log.Warn("Failed to evaluate smart playlist %d: %v", p.ID, err)
+				}
+				s.SongCount, s.Duration = count, duration
+			}
+			subsonicPlaylists = append(subsonicPlaylists, toPlaylist(&p, username, s.SongCount, s.Duration))
 		}
 	}
 	return subsonicPlaylists, nil
 }
 
-func (b *Browser) GetPlaylist(id uint) (*models.PlaylistWithSongs, error) {
+// GetPlaylist returns a playlist with its songs, gorm.ErrRecordNotFound is returned when
+// username can't see the playlist.
+func (b *Browser) GetPlaylist(id uint, username string) (*models.PlaylistWithSongs, error) {
 	var p models.PlaylistRecord
-	if err := b.db.First(&p, id).Error; err != nil {
+	if err := b.db.Preload("Collaborators").First(&p, id).Error; err != nil {
 		return nil, err
 	}
-
-	var songs []models.Child
-	err := b.db.Table("children").
-		Joins("JOIN playlist_songs ON playlist_songs.song_id = children.id").
-		Where("playlist_songs.playlist_id = ?", id).
-		Order("playlist_songs.position ASC").
-		Find(&songs).Error
-	if err != nil {
-		return nil, err
+	if p.Permission(username) == "" {
+		return nil, gorm.ErrRecordNotFound
 	}
 
+	var songs []models.Child
 	var duration int
-	if err := b.db.Table("children").
-		Joins("JOIN playlist_songs ON playlist_songs.song_id = children.id").
-		Where("playlist_songs.playlist_id = ?", id).
-		Select("COALESCE(SUM(children.duration), 0)").
-		Scan(&duration).Error; err != nil {
-		return nil, err
+	if p.IsSmart() {
+		q, err := b.smartSongs(p.Rules)
+		if err != nil {
+			return nil, err
+		}
+		if err := q.Find(&songs).Error; err != nil {
+			return nil, err
+		}
+		for _, song := range songs {
+			duration += song.Duration
+		}
+	} else {
+		err := b.db.Table("children").
+			Joins("JOIN playlist_songs ON playlist_songs.song_id = children.id").
+			Where("playlist_songs.playlist_id = ?", id).
+			Order("playlist_songs.position ASC").
+			Find(&songs).Error
+		if err != nil {
+			return nil, err
+		}
+
+		if err := b.db.Table("children").
+			Joins("JOIN playlist_songs ON playlist_songs.song_id = children.id").
+			Where("playlist_songs.playlist_id = ?", id).
+			Select("COALESCE(SUM(children.duration), 0)").
+			Scan(&duration).Error; err != nil {
+			return nil, err
+		}
 	}
 
 	return &models.PlaylistWithSongs{
-		Playlist: models.Playlist{
-			ID:        strconv.FormatUint(uint64(p.ID), 10),
-			Name:      p.Name,
-			Comment:   p.Comment,
-			Owner:     p.Owner,
-			Public:    p.Public,
-			SongCount: len(songs),
-			Duration:  duration,
-			Created:   p.CreatedAt,
-			Changed:   p.UpdatedAt,
-		},
-		Entry: songs,
+		Playlist: toPlaylist(&p, username, len(songs), duration),
+		Entry:    songs,
 	}, nil
 }
+
+// toPlaylist converts a playlist record for username, the playlist is read-only for users
+// who can't edit it.
+func toPlaylist(p *models.PlaylistRecord, username string, songCount, duration int) models.Playlist {
+	playlist := models.Playlist{
+		ID:            strconv.FormatUint(uint64(p.ID), 10),
+		Name:          p.Name,
+		Comment:       p.Comment,
+		Owner:         p.Owner,
+		Public:        p.Public,
+		SongCount:     songCount,
+		Duration:      duration,
+		Created:       p.CreatedAt,
+		Changed:       p.UpdatedAt,
+		CoverArt:      playlists.CoverArtID(p.ID),
+		Readonly:      p.IsReadonly() || p.Permission(username) != models.PlaylistEdit,
+		Collaborative: p.IsCollaborative(),
+	}
+	for _, c := range p.Collaborators {
+		playlist.AllowedUser = append(playlist.AllowedUser, c.Username)
+	}
+	return playlist
+}
+
+// smartStats counts the songs currently selected by a smart playlist and their total duration.
+func (b *Browser) smartStats(rules *models.SmartRules) (int, int, error) {
+	q, err := b.smartSongs(rules)
+	if err != nil {
+		return 0, 0, err
+	}
+	var stats struct {
+		SongCount int
+		Duration  int
+	}
+	err = b.db.Table("(?) AS smart", q.Select("children.duration")).
+		Select("COUNT(*) AS song_count, COALESCE(SUM(duration), 0) AS duration").
+		Scan(&stats).Error
+	return stats.SongCount, stats.Duration, err
+}
+
+// GetPlaylistCoverArt returns the path of the cover of a playlist in cacheDir, generating a
+// collage when needed. An empty path is returned when the playlist has no cover.
+func (b *Browser) GetPlaylistCoverArt(cacheDir string, id uint, username string) (string, error) {
+	var p models.PlaylistRecord
+	if err := b.db.Preload("Collaborators").First(&p, id).Error; err != nil {
+		return "", err
+	}
+	if p.Permission(username) == "" {
+		return "", gorm.ErrRecordNotFound
+	}
+	covers, err := b.playlistCovers(&p)
+	if err != nil {
+		return "", err
+	}
+	return playlists.CoverArt(cacheDir, id, covers)
+}
+
+// playlistCovers returns the distinct covers of the first songs of a playlist. A randomly sorted
+// smart playlist is taken in the default order so that its cover stays the same.
+func (b *Browser) playlistCovers(p *models.PlaylistRecord) ([]string, error) {
+	var covers []string
+	if !p.IsSmart() {
+		err := b.db.Table("children").
+			Joins("JOIN playlist_songs ON playlist_songs.song_id = children.id").
+			Where("playlist_songs.playlist_id = ? AND children.cover_art <> ?", p.ID, "").
+			Group("children.cover_art").
+			Order("MIN(playlist_songs.position)").
+			Limit(playlists.CollageCovers).
+			Pluck("children.cover_art", &covers).Error
+		return covers, err
+	}
+
+	rules := *p.Rules
+	if strings.EqualFold(rules.Sort, "random") {
+		rules.Sort = ""
+	}
+	q, err := b.smartSongs(&rules)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.Select("children.cover_art").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	seen := make(map[string]bool)
+	for len(covers) < playlists.CollageCovers && rows.Next() {
+		var cover string
+		if err := rows.Scan(&cover); err != nil {
+			return nil, err
+		}
+		if cover != "" && !seen[cover] {
+			seen[cover] = true
+			covers = append(covers, cover)
+		}
+	}
+	return covers, rows.Err()
+}
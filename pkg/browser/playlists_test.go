@@ -0,0 +1,44 @@
+package browser
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stkevintan/miko/models"
+	"gorm.io/gorm"
+)
+
+func TestPlaylistCovers(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Child{}, &models.PlaylistRecord{}, &models.PlaylistSong{}); err != nil {
+		t.Fatal(err)
+	}
+	// songs s0..s5 of the albums al-0..al-5 sort by title in reverse
+	for i := range 6 {
+		db.Create(&models.Child{ID: fmt.Sprintf("s%d", i), Path: fmt.Sprintf("/s%d", i), Title: fmt.Sprintf("%c", 'f'-i), CoverArt: fmt.Sprintf("al-%d", i)})
+	}
+	db.Create(&models.Child{ID: "s6", Path: "/s6", Title: "z", CoverArt: "al-1"})
+	b := New(db)
+
+	playlist := models.PlaylistRecord{Owner: "alice"}
+	db.Create(&playlist)
+	for pos, id := range []string{"s6", "s2", "s1", "s0", "s3", "s4"} {
+		db.Create(&models.PlaylistSong{PlaylistID: playlist.ID, SongID: id, Position: pos})
+	}
+	covers, err := b.playlistCovers(&playlist)
+	if want := []string{"al-1", "al-2", "al-0", "al-3"}; err != nil || !slices.Equal(covers, want) {
+		t.Errorf("playlistCovers() = %v, %v, want %v", covers, err, want)
+	}
+
+	smart := models.PlaylistRecord{Owner: "alice", Rules: &models.SmartRules{Sort: "random"}}
+	covers, err = b.playlistCovers(&smart)
+	if want := []string{"al-5", "al-4", "al-3", "al-2"}; err != nil || !slices.Equal(covers, want) {
+		t.Errorf("playlistCovers() of a random smart playlist = %v, %v, want %v", covers, err, want)
+	}
+}
@@ -0,0 +1,467 @@
+package browser
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/stkevintan/miko/pkg/search"
+)
+
+// QueryError reports a malformed search query.
+type QueryError struct {
+	Msg string
+}
+
+func (e *QueryError) Error() string {
+	return "invalid query: " + e.Msg
+}
+
+type fieldType int
+
+const (
+	textField fieldType = iota
+	numberField
+	presenceField
+)
+
+type queryField struct {
+	typ fieldType
+	// columns maps the search kinds to the SQL expression of the field, kinds without a
+	// column never match the field.
+	columns map[string]string
+}
+
+// queryFields are the fields usable as "field:value" in a search query.
+var queryFields = map[string]queryField{
+	"title": {textField, map[string]string{
+		search.KindSong: "children.title", search.KindAlbum: "album_id3.name", search.KindArtist: "artist_id3.name",
+	}},
+	"artist": {textField, map[string]string{
+		search.KindSong: "children.artist", search.KindAlbum: "album_id3.artist", search.KindArtist: "artist_id3.name",
+	}},
+	"album": {textField, map[string]string{
+		search.KindSong: "children.album", search.KindAlbum: "album_id3.name",
+	}},
+	"genre": {textField, map[string]string{
+		search.KindSong:  "children.genre",
+		search.KindAlbum: "(SELECT GROUP_CONCAT(songs.genre, '; ') FROM children AS songs WHERE songs.album_id = album_id3.id)",
+	}},
+	"composer": {textField, map[string]string{search.KindSong: "children.display_composer"}},
+	"path":     {textField, map[string]string{search.KindSong: "children.path"}},
+	"lyrics":   {textField, map[string]string{search.KindSong: "children.lyrics"}},
+	"label":    {textField, map[string]string{search.KindSong: "children.label"}},
+	"isrc":     {textField, map[string]string{search.KindSong: "children.isrc"}},
+	"suffix":   {textField, map[string]string{search.KindSong: "children.suffix"}},
+	"type":     {textField, map[string]string{search.KindSong: "children.type"}},
+	"year": {numberField, map[string]string{
+		search.KindSong: "children.year", search.KindAlbum: "album_id3.year",
+	}},
+	"rating": {numberField, map[string]string{
+		search.KindSong: "children.user_rating", search.KindAlbum: "album_id3.user_rating", search.KindArtist: "artist_id3.user_rating",
+	}},
+	"plays":    {numberField, map[string]string{search.KindSong: "children.play_count"}},
+	"bitrate":  {numberField, map[string]string{search.KindSong: "children.bit_rate"}},
+	"duration": {numberField, map[string]string{search.KindSong: "children.duration"}},
+	"track":    {numberField, map[string]string{search.KindSong: "children.track"}},
+	"disc":     {numberField, map[string]string{search.KindSong: "children.disc_number"}},
+	"starred": {presenceField, map[string]string{
+		search.KindSong: "children.starred", search.KindAlbum: "album_id3.starred", search.KindArtist: "artist_id3.starred",
+	}},
+}
+
+var kindTables = map[string]string{
+	search.KindSong:   "children",
+	search.KindAlbum:  "album_id3",
+	search.KindArtist: "artist_id3",
+}
+
+// Query is a parsed search query. Text holds the free text that has to match, Filter the
+// field conditions, negations and alternatives.
+//
+// Supported syntax:
+//
+//	artist:"Jay Chou"    field contains value, artist:="Jay Chou" for an exact match
+//	year:2000..2005      numeric ranges, also year:>=2000, year:<2005, year:..2005
+//	-genre:pop           negation, also NOT genre:pop
+//	suffix:flac OR suffix:ape
+//	(genre:pop OR genre:rock) year:1990..
+type Query struct {
+	Text   string
+	Filter queryNode
+}
+
+// Where returns the SQL condition of the filter for kind, or an empty string when the
+// query has no filter.
+func (q *Query) Where(kind string) (string, []any) {
+	if q.Filter == nil {
+		return "", nil
+	}
+	return q.Filter.sql(kind)
+}
+
+type queryNode interface {
+	sql(kind string) (string, []any)
+}
+
+type andNode []queryNode
+type orNode []queryNode
+type notNode struct{ node queryNode }
+type textNode struct{ text string }
+type fieldNode struct {
+	name  string
+	field queryField
+	value string
+}
+
+func joinNodes(nodes []queryNode, kind, sep string) (string, []any) {
+	parts := make([]string, len(nodes))
+	var args []any
+	for i, n := range nodes {
+		sql, a := n.sql(kind)
+		parts[i] = "(" + sql + ")"
+		args = append(args, a...)
+	}
+	return strings.Join(parts, sep), args
+}
+
+func (n andNode) sql(kind string) (string, []any) { return joinNodes(n, kind, " AND ") }
+func (n orNode) sql(kind string) (string, []any)  { return joinNodes(n, kind, " OR ") }
+
+func (n notNode) sql(kind string) (string, []any) {
+	sql, args := n.node.sql(kind)
+	return "NOT (" + sql + ")", args
+}
+
+func (n textNode) sql(kind string) (string, []any) {
+	match := search.MatchQuery(n.text)
+	if match == "" {
+		return "1 = 1", nil
+	}
+	return kindTables[kind] + ".id IN (SELECT ref_id FROM " + search.Table + " WHERE " + search.Table + " MATCH ? AND kind = ?)",
+		[]any{match, kind}
+}
+
+func (n fieldNode) sql(kind string) (string, []any) {
+	column, ok := n.field.columns[kind]
+	if !ok {
+		return "1 = 0", nil
+	}
+
+	switch n.field.typ {
+	case presenceField:
+		if parseBool(n.value) {
+			return column + " IS NOT NULL", nil
+		}
+		return column + " IS NULL", nil
+	case numberField:
+		// values were validated while parsing
+		op, a, b, _ := parseNumberRange(n.value)
+		if op == ".." {
+			return column + " BETWEEN ? AND ?", []any{a, b}
+		}
+		return column + " " + op + " ?", []any{a}
+	default:
+		if exact, found := strings.CutPrefix(n.value, "="); found {
+			return "LOWER(" + column + ") = LOWER(?)", []any{exact}
+		}
+		return column + ` LIKE ? ESCAPE '\'`, []any{"%" + escapeLike(n.value) + "%"}
+	}
+}
+
+func parseBool(v string) bool {
+	switch strings.ToLower(v) {
+	case "true", "yes", "1":
+		return true
+	}
+	return false
+}
+
+func escapeLike(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(v)
+}
+
+// parseNumberRange parses "5", ">=5", "<5", "1..5", "1.." and "..5". Open ranges become
+// comparisons, closed ones use the ".." operator.
+func parseNumberRange(v string) (string, float64, float64, error) {
+	if lo, hi, found := strings.Cut(v, ".."); found {
+		switch {
+		case lo == "" && hi == "":
+			return "", 0, 0, fmt.Errorf("empty range")
+		case lo == "":
+			n, err := strconv.ParseFloat(hi, 64)
+			return "<=", n, 0, err
+		case hi == "":
+			n, err := strconv.ParseFloat(lo, 64)
+			return ">=", n, 0, err
+		}
+		a, err := strconv.ParseFloat(lo, 64)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		b, err := strconv.ParseFloat(hi, 64)
+		return "..", a, b, err
+	}
+
+	op := "="
+	for _, prefix := range []string{">=", "<=", ">", "<", "="} {
+		if rest, found := strings.CutPrefix(v, prefix); found {
+			op, v = prefix, rest
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	return op, n, 0, err
+}
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokField
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	field string
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	i := 0
+
+	readQuoted := func() (string, error) {
+		// runes[i] is the opening quote
+		end := i + 1
+		for end < len(runes) && runes[end] != '"' {
+			end++
+		}
+		if end >= len(runes) {
+			return "", &QueryError{Msg: "unterminated quote"}
+		}
+		s := string(runes[i+1 : end])
+		i = end + 1
+		return s, nil
+	}
+
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokLParen})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokRParen})
+			i++
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) && (i == 0 || unicode.IsSpace(runes[i-1]) || runes[i-1] == '('):
+			tokens = append(tokens, token{kind: tokNot})
+			i++
+		case r == '"':
+			s, err := readQuoted()
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokWord, text: s})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' && runes[i] != '"' {
+				i++
+			}
+			word := string(runes[start:i])
+
+			name, value, isField := strings.Cut(word, ":")
+			if isField {
+				if _, known := queryFields[strings.ToLower(name)]; !known {
+					isField = false
+				}
+			}
+			if isField {
+				if (value == "" || value == "=") && i < len(runes) && runes[i] == '"' {
+					quoted, err := readQuoted()
+					if err != nil {
+						return nil, err
+					}
+					value += quoted
+				}
+				tokens = append(tokens, token{kind: tokField, field: strings.ToLower(name), text: value})
+				continue
+			}
+
+			switch word {
+			case "OR":
+				tokens = append(tokens, token{kind: tokOr})
+			case "NOT":
+				tokens = append(tokens, token{kind: tokNot})
+			case "AND":
+				// terms are combined with AND by default
+			default:
+				tokens = append(tokens, token{kind: tokWord, text: word})
+			}
+		}
+	}
+	return tokens, nil
+}
+
+type queryParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *queryParser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// expr := and ("OR" and)*
+func (p *queryParser) parseOr() (queryNode, error) {
+	var nodes []queryNode
+	for {
+		n, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+		if t, ok := p.peek(); !ok || t.kind != tokOr {
+			break
+		}
+		p.pos++
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return orNode(nodes), nil
+}
+
+// and := unary+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	var nodes []queryNode
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind == tokOr || t.kind == tokRParen {
+			break
+		}
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	switch len(nodes) {
+	case 0:
+		return nil, &QueryError{Msg: "missing search term"}
+	case 1:
+		return nodes[0], nil
+	}
+	return andNode(nodes), nil
+}
+
+// unary := ("-" | "NOT") unary | "(" expr ")" | field | word
+func (p *queryParser) parseUnary() (queryNode, error) {
+	t, _ := p.peek()
+	p.pos++
+	switch t.kind {
+	case tokNot:
+		if _, ok := p.peek(); !ok {
+			return nil, &QueryError{Msg: "missing term after NOT"}
+		}
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	case tokLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := p.peek(); !ok || t.kind != tokRParen {
+			return nil, &QueryError{Msg: "missing closing parenthesis"}
+		}
+		p.pos++
+		return n, nil
+	case tokField:
+		field := queryFields[t.field]
+		if t.text == "" {
+			return nil, &QueryError{Msg: fmt.Sprintf("missing value for %s", t.field)}
+		}
+		if field.typ == numberField {
+			if _, _, _, err := parseNumberRange(t.text); err != nil {
+				return nil, &QueryError{Msg: fmt.Sprintf("invalid number %q for %s", t.text, t.field)}
+			}
+		}
+		return fieldNode{name: t.field, field: field, value: t.text}, nil
+	case tokWord:
+		return textNode{t.text}, nil
+	default:
+		return nil, &QueryError{Msg: "unexpected )"}
+	}
+}
+
+// ParseQuery parses a search query. Free text that is not part of a negation or an
+// alternative is returned as Query.Text so it can be ranked by the full-text index.
+func ParseQuery(input string) (*Query, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return &Query{}, nil
+	}
+
+	p := &queryParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, &QueryError{Msg: "unexpected )"}
+	}
+
+	terms := []queryNode{root}
+	if and, ok := root.(andNode); ok {
+		terms = and
+	}
+	var text []string
+	var filters andNode
+	for _, n := range terms {
+		if t, ok := n.(textNode); ok {
+			text = append(text, t.text)
+			continue
+		}
+		filters = append(filters, n)
+	}
+
+	q := &Query{Text: strings.Join(text, " ")}
+	switch len(filters) {
+	case 0:
+	case 1:
+		q.Filter = filters[0]
+	default:
+		q.Filter = filters
+	}
+	return q, nil
+}
+
+// parseLenientQuery parses input as a query when it has a field filter, and takes it as free
+// text otherwise or when it doesn't parse, so that titles such as `12" Mix` or `-ism` are found.
+func parseLenientQuery(input string) *Query {
+	tokens, err := tokenize(input)
+	if err == nil && slices.ContainsFunc(tokens, func(t token) bool { return t.kind == tokField }) {
+		if q, err := ParseQuery(input); err == nil {
+			return q
+		}
+	}
+	return &Query{Text: input}
+}
@@ -0,0 +1,79 @@
+package browser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stkevintan/miko/pkg/search"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		in   string
+		text string
+		sql  string
+		args []any
+	}{
+		{"beatles help", "beatles help", "", nil},
+		{
+			`artist:"Jay Chou" year:2000..2005`, "",
+			`(children.artist LIKE ? ESCAPE '\') AND (children.year BETWEEN ? AND ?)`,
+			[]any{"%Jay Chou%", 2000.0, 2005.0},
+		},
+		{
+			"love rating:>=4 -suffix:mp3", "love",
+			`(children.user_rating >= ?) AND (NOT (children.suffix LIKE ? ESCAPE '\'))`,
+			[]any{4.0, "%mp3%"},
+		},
+		{
+			"suffix:flac OR suffix:ape", "",
+			`(children.suffix LIKE ? ESCAPE '\') OR (children.suffix LIKE ? ESCAPE '\')`,
+			[]any{"%flac%", "%ape%"},
+		},
+		{
+			`artist:="Jay Chou" (genre:pop OR year:..1999)`, "",
+			`(LOWER(children.artist) = LOWER(?)) AND ((children.genre LIKE ? ESCAPE '\') OR (children.year <= ?))`,
+			[]any{"Jay Chou", "%pop%", 1999.0},
+		},
+		{"Re: Stacks", "Re: Stacks", "", nil},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.in)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) error: %v", tt.in, err)
+			continue
+		}
+		sql, args := q.Where(search.KindSong)
+		if q.Text != tt.text || sql != tt.sql || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("ParseQuery(%q) = %q, %q, %v; want %q, %q, %v", tt.in, q.Text, sql, args, tt.text, tt.sql, tt.args)
+		}
+	}
+}
+
+func TestParseQuery_Errors(t *testing.T) {
+	for _, in := range []string{`artist:"Jay`, "year:abc", "(genre:pop", "genre:pop )", "NOT"} {
+		var qe *QueryError
+		if _, err := ParseQuery(in); !errors.As(err, &qe) {
+			t.Errorf("ParseQuery(%q) error = %v, want QueryError", in, err)
+		}
+	}
+}
+
+func TestParseLenientQuery(t *testing.T) {
+	for _, in := range []string{`12" Mix`, "foo)", "-ism", "love NOT war", "year:abc"} {
+		if q := parseLenientQuery(in); q.Text != in || q.Filter != nil {
+			t.Errorf("parseLenientQuery(%q) = %+v, want free text", in, q)
+		}
+	}
+	if q := parseLenientQuery("love -suffix:mp3"); q.Text != "love" || q.Filter == nil {
+		t.Errorf("parseLenientQuery() = %+v, want text and filter", q)
+	}
+}
+
+func TestQuery_UnsupportedField(t *testing.T) {
+	q, _ := ParseQuery("suffix:flac")
+	if sql, _ := q.Where(search.KindArtist); sql != "1 = 0" {
+		t.Errorf("Where(artist) = %q, want no match", sql)
+	}
+}
@@ -2,6 +2,8 @@ package browser
 
 import (
 	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/search"
+	"gorm.io/gorm"
 )
 
 type SearchOptions struct {
@@ -14,24 +16,54 @@ type SearchOptions struct {
 	SongOffset    int
 	MusicFolderID uint
 	HasFolderID   bool
+	// Lenient searches for the query as plain free text unless it uses a field filter and
+	// parses, for clients that pass on whatever their users typed.
+	Lenient bool
 }
 
+// Search looks up artists, albums and songs in the full-text index, best matches first.
+// The query may use the syntax described by Query. A query without free text or filters lists
+// everything, which clients use to sync the library.
 func (b *Browser) Search(opts SearchOptions) ([]models.ArtistID3, []models.AlbumID3, []models.Child, error) {
 	var artists []models.ArtistID3
 	var albums []models.AlbumID3
 	var songs []models.Child
 
-	searchQuery := "%" + opts.Query + "%"
-	if opts.Query == "" {
-		searchQuery = "%"
+	var q *Query
+	if opts.Lenient {
+		q = parseLenientQuery(opts.Query)
+	} else {
+		var err error
+		if q, err = ParseQuery(opts.Query); err != nil {
+			return nil, nil, nil, err
+		}
 	}
 
-	artistQuery := b.db.Scopes(models.ArtistWithStats).
-		Where("name LIKE ?", searchQuery).Limit(opts.ArtistCount).Offset(opts.ArtistOffset)
-	albumQuery := b.db.Scopes(models.AlbumWithStats(false)).
-		Where("name LIKE ?", searchQuery).Limit(opts.AlbumCount).Offset(opts.AlbumOffset)
-	songQuery := b.db.Where("is_dir = false AND (title LIKE ? OR album LIKE ? OR artist LIKE ?)", searchQuery, searchQuery, searchQuery).
-		Limit(opts.SongCount).Offset(opts.SongOffset)
+	artistQuery := b.db.Scopes(models.ArtistWithStats, models.CreditedArtists).Limit(opts.ArtistCount).Offset(opts.ArtistOffset)
+	albumQuery := b.db.Scopes(models.AlbumWithStats(false)).Limit(opts.AlbumCount).Offset(opts.AlbumOffset)
+	songQuery := b.db.Where("children.is_dir = ?", false).Limit(opts.SongCount).Offset(opts.SongOffset)
+
+	if match := search.MatchQuery(q.Text); match != "" {
+		artistQuery = artistQuery.Joins("JOIN (?) AS hits ON hits.ref_id = artist_id3.id", search.Hits(b.db, search.KindArtist, match)).
+			Order("hits.score")
+		albumQuery = albumQuery.Joins("JOIN (?) AS hits ON hits.ref_id = album_id3.id", search.Hits(b.db, search.KindAlbum, match)).
+			Order("hits.score")
+		songQuery = songQuery.Joins("JOIN (?) AS hits ON hits.ref_id = children.id", search.Hits(b.db, search.KindSong, match)).
+			Order("hits.score")
+	} else {
+		artistQuery = artistQuery.Order("artist_id3.order_name, artist_id3.name")
+		albumQuery = albumQuery.Order("album_id3.order_name, album_id3.name")
+		songQuery = songQuery.Order("children.album, children.disc_number, children.track")
+	}
+
+	if q.Filter != nil {
+		sql, args := q.Where(search.KindArtist)
+		artistQuery = artistQuery.Where(sql, args...)
+		sql, args = q.Where(search.KindAlbum)
+		albumQuery = albumQuery.Where(sql, args...)
+		sql, args = q.Where(search.KindSong)
+		songQuery = songQuery.Where(sql, args...)
+	}
 
 	if opts.HasFolderID {
 		artistQuery = artistQuery.Joins("JOIN song_artists ON song_artists.artist_id3_id = artist_id3.id").
@@ -43,25 +75,52 @@ func (b *Browser) Search(opts SearchOptions) ([]models.ArtistID3, []models.Album
 			Where("children.music_folder_id = ?", opts.MusicFolderID).
 			Group("album_id3.id")
 
-		songQuery = songQuery.Where("music_folder_id = ?", opts.MusicFolderID)
+		songQuery = songQuery.Where("children.music_folder_id = ?", opts.MusicFolderID)
 	}
 
-	artistQuery.Find(&artists)
-	albumQuery.Find(&albums)
-	songQuery.Find(&songs)
+	if err := artistQuery.Find(&artists).Error; err != nil {
+		return nil, nil, nil, err
+	}
+	if err := albumQuery.Find(&albums).Error; err != nil {
+		return nil, nil, nil, err
+	}
+	if err := songQuery.Find(&songs).Error; err != nil {
+		return nil, nil, nil, err
+	}
 
 	return artists, albums, songs, nil
 }
 
+// SearchMatches returns the highlighted columns that made each of the songs match query.
+func (b *Browser) SearchMatches(query string, songs []models.Child) (map[string][]search.Match, error) {
+	q, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(songs))
+	for i, s := range songs {
+		ids[i] = s.ID
+	}
+	return search.Snippets(b.db, search.KindSong, search.MatchQuery(q.Text), ids)
+}
+
+// SearchSongs returns a page of the songs matching query, best matches first, and how many
+// songs match. A query without free text lists every song, which clients use to page through
+// the library.
 func (b *Browser) SearchSongs(query string, count, offset int) ([]models.Child, int64, error) {
 	var songs []models.Child
-	searchQuery := "%" + query + "%"
+	songQuery := b.db.Model(&models.Child{}).Where("children.is_dir = ?", false)
+	order := "children.album, children.disc_number, children.track"
+	if match := search.MatchQuery(query); match != "" {
+		songQuery = songQuery.Joins("JOIN (?) AS hits ON hits.ref_id = children.id", search.Hits(b.db, search.KindSong, match))
+		order = "hits.score"
+	}
+	songQuery = songQuery.Session(&gorm.Session{})
 
 	var totalHits int64
-	b.db.Model(&models.Child{}).Where("title LIKE ? OR album LIKE ? OR artist LIKE ?", searchQuery, searchQuery, searchQuery).Count(&totalHits)
-
-	err := b.db.Where("title LIKE ? OR album LIKE ? OR artist LIKE ?", searchQuery, searchQuery, searchQuery).
-		Limit(count).Offset(offset).Find(&songs).Error
-
+	if err := songQuery.Count(&totalHits).Error; err != nil {
+		return nil, 0, err
+	}
+	err := songQuery.Order(order).Limit(count).Offset(offset).Find(&songs).Error
 	return songs, totalHits, err
 }
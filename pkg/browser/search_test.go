@@ -0,0 +1,75 @@
+package browser
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/search"
+	"gorm.io/gorm"
+)
+
+func TestSearchSongs(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Child{}, &models.ArtistID3{}, &models.AlbumID3{}); err != nil {
+		t.Fatal(err)
+	}
+	songs := []models.Child{
+		{ID: "a", Path: "/a", Title: "Blue Moon", Album: "A", Track: 2},
+		{ID: "b", Path: "/b", Title: "Red Sun", Album: "A", Track: 1},
+		{ID: "c", Path: "/c", Title: "Blue Sky", Album: "B", Track: 1},
+	}
+	db.Create(&songs)
+	db.Create(&models.Child{ID: "dir", Path: "/dir", Title: "Blue", IsDir: true})
+	if err := search.EnsureIndex(db); err != nil {
+		t.Fatal(err)
+	}
+	b := New(db)
+
+	got, total, err := b.SearchSongs("blue", 10, 0)
+	if err != nil || total != 2 || len(got) != 2 {
+		t.Errorf("SearchSongs(blue) = %v, %d, %v, want the 2 blue songs", songIDs(got), total, err)
+	}
+
+	// clients page through the library with an empty query
+	got, total, err = b.SearchSongs("", 2, 1)
+	if err != nil || total != 3 || !slices.Equal(songIDs(got), []string{"a", "c"}) {
+		t.Errorf("SearchSongs() = %v, %d, %v, want the second page of every song", songIDs(got), total, err)
+	}
+}
+
+func TestSearch_ContributorArtists(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Child{}, &models.ArtistID3{}, &models.AlbumID3{}, &models.SongContributor{}); err != nil {
+		t.Fatal(err)
+	}
+	singer := models.ArtistID3{ID: "singer", Name: "Ann Singer"}
+	writer := models.ArtistID3{ID: "writer", Name: "Ann Writer"}
+	db.Create(&[]models.ArtistID3{singer, writer})
+	db.Create(&models.Child{ID: "s", Path: "/s", Title: "Song", Artists: []models.ArtistID3{singer}})
+	db.Create(&models.SongContributor{ChildID: "s", ArtistID: "writer", Role: models.RoleComposer})
+	if err := search.EnsureIndex(db); err != nil {
+		t.Fatal(err)
+	}
+	b := New(db)
+
+	for _, query := range []string{"", "ann", "rating:0"} {
+		artists, _, _, err := b.Search(SearchOptions{Query: query, ArtistCount: 10})
+		if err != nil || len(artists) != 1 || artists[0].ID != "singer" {
+			t.Errorf("Search(%q) = %v, %v, want the singer only", query, artists, err)
+		}
+	}
+
+	indexes, err := b.GetArtists("")
+	if err != nil || len(indexes) != 1 || len(indexes[0].Artist) != 1 {
+		t.Errorf("GetArtists() = %v, %v, want the singer only", indexes, err)
+	}
+}
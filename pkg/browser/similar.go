@@ -0,0 +1,305 @@
+package browser
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strings"
+
+	"github.com/stkevintan/miko/models"
+	"gorm.io/gorm"
+)
+
+// Weights of the signals songs are compared with, the year only adds to songs that are similar
+// in another way.
+const (
+	similarGenreWeight    = 0.3
+	similarArtistWeight   = 0.3
+	similarPlaylistWeight = 0.2
+	similarHistoryWeight  = 0.2
+	similarYearWeight     = 0.1
+)
+
+const (
+	// similarMaxSeeds is the number of songs of an album or artist the mix is based on
+	similarMaxSeeds = 100
+	// similarSession is the time in days within which plays by the same user count as
+	// listened together
+	similarSession = 30.0 / (24 * 60)
+	// similarYearSpan is the distance in years at which releases stop being close
+	similarYearSpan = 10.0
+	// similarMaxCount caps the size of a mix
+	similarMaxCount = 500
+)
+
+// similarCandidate is a song scored by its similarity to the seed songs.
+type similarCandidate struct {
+	song  models.Child
+	score float64
+}
+
+// SimilarSongs returns a mix of up to count songs similar to a song, album, artist or
+// directory, by the genres, artists and years they share and how often they are found together
+// in playlists and the play history. The mix is randomized, weighted by similarity, and holds a
+// song only once. count is clamped to 1-500. gorm.ErrRecordNotFound is returned when the id
+// is unknown.
+func (b *Browser) SimilarSongs(id string, count int) ([]models.Child, error) {
+	count = min(max(count, 1), similarMaxCount)
+	seeds, err := b.similarSeeds(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(seeds) == 0 {
+		return []models.Child{}, nil
+	}
+	seedIDs := make([]string, len(seeds))
+	for i, seed := range seeds {
+		seedIDs[i] = seed.ID
+	}
+
+	scores := make(map[string]float64)
+	signals := []struct {
+		weight float64
+		score  func([]string) (map[string]float64, error)
+	}{
+		{similarGenreWeight, b.similarByGenre},
+		{similarArtistWeight, b.similarByArtist},
+		{similarPlaylistWeight, b.similarByPlaylist},
+		{similarHistoryWeight, b.similarByHistory},
+	}
+	for _, signal := range signals {
+		signalScores, err := signal.score(seedIDs)
+		if err != nil {
+			return nil, err
+		}
+		for songID, score := range normalizeScores(signalScores) {
+			scores[songID] += signal.weight * score
+		}
+	}
+	// a single song is the start of its mix rather than a part of it
+	if len(seeds) == 1 {
+		delete(scores, seeds[0].ID)
+	}
+	if len(scores) == 0 {
+		return []models.Child{}, nil
+	}
+
+	// score the best candidates only, a mix draws from a pool a few times its size
+	ids := make([]string, 0, len(scores))
+	for songID := range scores {
+		ids = append(ids, songID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return scores[ids[i]] > scores[ids[j]] })
+	ids = ids[:min(len(ids), max(count*5, 100))]
+
+	var songs []models.Child
+	if err := b.db.Where("id IN ? AND is_dir = ? AND type <> ?", ids, false, models.MediaTypeAudiobook).Find(&songs).Error; err != nil {
+		return nil, err
+	}
+	seedYear := averageYear(seeds)
+	candidates := make([]similarCandidate, 0, len(songs))
+	for _, song := range songs {
+		if len(seeds) == 1 && recordingKey(song) == recordingKey(seeds[0]) {
+			continue
+		}
+		score := scores[song.ID]
+		if seedYear > 0 && song.Year > 0 {
+			score += similarYearWeight * max(0, 1-math.Abs(float64(song.Year)-seedYear)/similarYearSpan)
+		}
+		candidates = append(candidates, similarCandidate{song: song, score: score})
+	}
+	return similarMix(candidates, count, rand.Float64), nil
+}
+
+// similarSeeds returns the songs the mix of id is based on.
+func (b *Browser) similarSeeds(id string) ([]models.Child, error) {
+	var seeds []models.Child
+	base := b.db.Model(&models.Child{}).
+		Where("children.is_dir = ? AND children.type <> ?", false, models.MediaTypeAudiobook).
+		Order("children.play_count DESC").
+		Limit(similarMaxSeeds)
+
+	var child models.Child
+	if err := b.db.Select("id, is_dir").Where("id = ?", id).Limit(1).Find(&child).Error; err != nil {
+		return nil, err
+	}
+	if child.ID != "" {
+		if !child.IsDir {
+			return seeds, b.db.Where("id = ?", id).Find(&seeds).Error
+		}
+		return seeds, base.Where("children.parent = ?", id).Find(&seeds).Error
+	}
+
+	var n int64
+	if err := b.db.Model(&models.AlbumID3{}).Where("id = ?", id).Count(&n).Error; err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		return seeds, base.Where("children.album_id = ?", id).Find(&seeds).Error
+	}
+
+	if err := b.db.Model(&models.ArtistID3{}).Where("id = ?", id).Count(&n).Error; err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		return seeds, base.Where(`children.artist_id = ?
+			OR children.id IN (SELECT child_id FROM song_artists WHERE artist_id3_id = ?)
+			OR children.album_id IN (SELECT album_id3_id FROM album_artists WHERE artist_id3_id = ?)`, id, id, id).
+			Find(&seeds).Error
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+type similarScore struct {
+	SongID string
+	Score  float64
+}
+
+// scanScores runs a query returning song_id and score columns, keeping the highest score of
+// every song.
+func scanScores(db *gorm.DB) (map[string]float64, error) {
+	var rows []similarScore
+	if err := db.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	scores := make(map[string]float64, len(rows))
+	for _, row := range rows {
+		scores[row.SongID] = max(scores[row.SongID], row.Score)
+	}
+	return scores, nil
+}
+
+// similarByGenre scores songs by the share of the seed genres they have, every genre weighted
+// by how many seeds have it.
+func (b *Browser) similarByGenre(seedIDs []string) (map[string]float64, error) {
+	return scanScores(b.db.Raw(`
+		WITH seed_genres AS (
+			SELECT genre_name, COUNT(*) AS weight FROM song_genres WHERE child_id IN @seeds GROUP BY genre_name
+		)
+		SELECT song_genres.child_id AS song_id, SUM(seed_genres.weight) * 1.0 / (SELECT SUM(weight) FROM seed_genres) AS score
+		FROM song_genres JOIN seed_genres ON seed_genres.genre_name = song_genres.genre_name
+		GROUP BY song_genres.child_id`,
+		map[string]any{"seeds": seedIDs}))
+}
+
+// similarByArtist scores songs by the artists of the seeds, songs an artist only appears on as
+// album artist score half.
+func (b *Browser) similarByArtist(seedIDs []string) (map[string]float64, error) {
+	return scanScores(b.db.Raw(`
+		WITH seed_artists AS (
+			SELECT artist_id AS id FROM children WHERE id IN @seeds AND artist_id != ''
+			UNION SELECT artist_id3_id FROM song_artists WHERE child_id IN @seeds
+			UNION SELECT album_artists.artist_id3_id FROM album_artists
+				JOIN children ON children.album_id = album_artists.album_id3_id WHERE children.id IN @seeds
+		)
+		SELECT id AS song_id, 1.0 AS score FROM children WHERE artist_id IN (SELECT id FROM seed_artists)
+		UNION ALL SELECT child_id, 1.0 FROM song_artists WHERE artist_id3_id IN (SELECT id FROM seed_artists)
+		UNION ALL SELECT children.id, 0.5 FROM children
+			JOIN album_artists ON album_artists.album_id3_id = children.album_id
+			WHERE album_artists.artist_id3_id IN (SELECT id FROM seed_artists)`,
+		map[string]any{"seeds": seedIDs}))
+}
+
+// similarByPlaylist scores songs by the number of playlists they share with the seeds.
+func (b *Browser) similarByPlaylist(seedIDs []string) (map[string]float64, error) {
+	return scanScores(b.db.Raw(`
+		SELECT other.song_id AS song_id, COUNT(DISTINCT other.playlist_id) AS score
+		FROM playlist_songs AS seed
+		JOIN playlist_songs AS other ON other.playlist_id = seed.playlist_id AND other.song_id != seed.song_id
+		WHERE seed.song_id IN @seeds
+		GROUP BY other.song_id`,
+		map[string]any{"seeds": seedIDs}))
+}
+
+// similarByHistory scores songs by how often a user played them close to a seed.
+func (b *Browser) similarByHistory(seedIDs []string) (map[string]float64, error) {
+	return scanScores(b.db.Raw(`
+		SELECT other.song_id AS song_id, COUNT(*) AS score
+		FROM play_records AS seed
+		JOIN play_records AS other ON other.username = seed.username
+			AND other.id != seed.id AND other.song_id != seed.song_id
+			AND ABS(julianday(other.played_at) - julianday(seed.played_at)) < @session
+		WHERE seed.song_id IN @seeds
+		GROUP BY other.song_id`,
+		map[string]any{"seeds": seedIDs, "session": similarSession}))
+}
+
+// normalizeScores scales scores to the range 0 to 1.
+func normalizeScores(scores map[string]float64) map[string]float64 {
+	var top float64
+	for _, score := range scores {
+		top = max(top, score)
+	}
+	if top > 0 {
+		for songID, score := range scores {
+			scores[songID] = score / top
+		}
+	}
+	return scores
+}
+
+func averageYear(songs []models.Child) float64 {
+	var sum, n int
+	for _, song := range songs {
+		if song.Year > 0 {
+			sum += song.Year
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return float64(sum) / float64(n)
+}
+
+// recordingKey identifies a recording across the releases it appears on.
+func recordingKey(song models.Child) string {
+	return strings.ToLower(strings.TrimSpace(song.Title)) + "\x00" + strings.ToLower(strings.TrimSpace(song.Artist))
+}
+
+// similarMix draws count songs from candidates, the more similar a song the more likely it is
+// drawn. Songs with the same title and artist are the same recording on another release and
+// are drawn once, and an artist takes at most a quarter of the mix unless there aren't enough
+// other songs. random returns numbers in [0, 1).
+func similarMix(candidates []similarCandidate, count int, random func() float64) []models.Child {
+	// weighted sampling without replacement: sort by random^(1/weight)
+	keys := make([]float64, len(candidates))
+	for i, c := range candidates {
+		keys[i] = math.Pow(random(), 1/max(c.score, 1e-6))
+	}
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return keys[order[i]] > keys[order[j]] })
+
+	perArtist := max(count/4, 2)
+	seen := make(map[string]bool)
+	artists := make(map[string]int)
+	mix := make([]models.Child, 0, count)
+	var skipped []models.Child
+	for _, i := range order {
+		if len(mix) == count {
+			break
+		}
+		song := candidates[i].song
+		key := recordingKey(song)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if artists[song.Artist] >= perArtist {
+			skipped = append(skipped, song)
+			continue
+		}
+		artists[song.Artist]++
+		mix = append(mix, song)
+	}
+	for _, song := range skipped {
+		if len(mix) == count {
+			break
+		}
+		mix = append(mix, song)
+	}
+	return mix
+}
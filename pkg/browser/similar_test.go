@@ -0,0 +1,54 @@
+package browser
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stkevintan/miko/models"
+)
+
+func TestSimilarMix(t *testing.T) {
+	var candidates []similarCandidate
+	for i := range 10 {
+		candidates = append(candidates, similarCandidate{
+			song:  models.Child{ID: fmt.Sprintf("a%d", i), Title: fmt.Sprintf("Song %d", i), Artist: "A"},
+			score: 1,
+		})
+	}
+	for i := range 3 {
+		candidates = append(candidates, similarCandidate{
+			song:  models.Child{ID: fmt.Sprintf("b%d", i), Title: fmt.Sprintf("Song %d", i), Artist: "B"},
+			score: 0.1,
+		})
+	}
+	// the same recording on a compilation
+	candidates = append(candidates, similarCandidate{
+		song:  models.Child{ID: "b0-compilation", Title: "song 0 ", Artist: "b"},
+		score: 0.1,
+	})
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	mix := similarMix(candidates, 8, rng.Float64)
+	if len(mix) != 8 {
+		t.Fatalf("mix has %d songs, want 8", len(mix))
+	}
+	seen := make(map[string]bool)
+	artists := make(map[string]int)
+	for _, song := range mix {
+		key := recordingKey(song)
+		if seen[key] {
+			t.Errorf("recording %q is in the mix twice", key)
+		}
+		seen[key] = true
+		artists[song.Artist]++
+	}
+	// artist A is capped at a quarter of the mix, padded with A only once B runs out
+	if artists["B"]+artists["b"] != 3 || artists["A"] != 5 {
+		t.Errorf("artists in mix = %v, want all 3 songs of B and 5 of A", artists)
+	}
+
+	if mix := similarMix(candidates, 50, rng.Float64); len(mix) != 13 {
+		t.Errorf("mix of all candidates has %d songs, want the 13 distinct recordings", len(mix))
+	}
+}
@@ -0,0 +1,297 @@
+package browser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stkevintan/miko/models"
+	"gorm.io/gorm"
+)
+
+type smartFieldType int
+
+const (
+	smartText smartFieldType = iota
+	smartNumber
+	smartDate
+)
+
+type smartField struct {
+	typ    smartFieldType
+	column string
+}
+
+// smartFields are the song fields usable in smart playlist rules and as their sort, keyed by
+// their lower-cased .nsp name.
+var smartFields = map[string]smartField{
+	"title":       {smartText, "children.title"},
+	"album":       {smartText, "children.album"},
+	"artist":      {smartText, "children.artist"},
+	"composer":    {smartText, "children.display_composer"},
+	"genre":       {smartText, "children.genre"},
+	"comment":     {smartText, "children.comment"},
+	"filepath":    {smartText, "children.path"},
+	"filetype":    {smartText, "children.suffix"},
+	"year":        {smartNumber, "children.year"},
+	"rating":      {smartNumber, "children.user_rating"},
+	"playcount":   {smartNumber, "children.play_count"},
+	"bitrate":     {smartNumber, "children.bit_rate"},
+	"duration":    {smartNumber, "children.duration"},
+	"tracknumber": {smartNumber, "children.track"},
+	"discnumber":  {smartNumber, "children.disc_number"},
+	"lastplayed":  {smartDate, "children.last_played"},
+	"dateadded":   {smartDate, "children.created"},
+	"starred":     {smartDate, "children.starred"},
+	"loved":       {smartDate, "children.starred"},
+}
+
+// ValidateSmartRules checks that rules only use known operators, fields and values.
+func ValidateSmartRules(rules *models.SmartRules) error {
+	_, _, err := smartWhere(rules)
+	if err != nil {
+		return err
+	}
+	if rules.Sort != "" && !strings.EqualFold(rules.Sort, "random") {
+		for _, key := range strings.Split(rules.Sort, ",") {
+			if _, ok := smartFields[strings.ToLower(strings.TrimLeft(strings.TrimSpace(key), "+-"))]; !ok {
+				return fmt.Errorf("unknown sort field %q", key)
+			}
+		}
+	}
+	switch strings.ToLower(rules.Order) {
+	case "", "asc", "desc":
+	default:
+		return fmt.Errorf("unknown sort order %q", rules.Order)
+	}
+	if rules.Limit < 0 {
+		return fmt.Errorf("invalid limit %d", rules.Limit)
+	}
+	return nil
+}
+
+// smartSongs returns a query selecting the songs of a smart playlist in playlist order.
+func (b *Browser) smartSongs(rules *models.SmartRules) (*gorm.DB, error) {
+	where, args, err := smartWhere(rules)
+	if err != nil {
+		return nil, err
+	}
+	q := b.db.Model(&models.Child{}).Where("children.is_dir = ?", false).Where(where, args...)
+
+	desc := strings.EqualFold(rules.Order, "desc")
+	switch {
+	case strings.EqualFold(rules.Sort, "random"):
+		q = q.Order("RANDOM()")
+	case rules.Sort == "":
+		q = q.Order(smartOrder("children.title", desc))
+	default:
+		for _, key := range strings.Split(rules.Sort, ",") {
+			key = strings.TrimSpace(key)
+			field := smartFields[strings.ToLower(strings.TrimLeft(key, "+-"))]
+			// a leading "-" reverses the direction of a single key
+			q = q.Order(smartOrder(field.column, desc != strings.HasPrefix(key, "-")))
+		}
+	}
+	if rules.Limit > 0 {
+		q = q.Limit(rules.Limit)
+	}
+	return q, nil
+}
+
+func smartOrder(column string, desc bool) string {
+	if desc {
+		return column + " DESC"
+	}
+	return column + " ASC"
+}
+
+func smartWhere(rules *models.SmartRules) (string, []any, error) {
+	if len(rules.All) > 0 && len(rules.Any) > 0 {
+		return "", nil, fmt.Errorf("a smart playlist can't have both all and any rules")
+	}
+	if len(rules.Any) > 0 {
+		return smartGroup(rules.Any, " OR ")
+	}
+	return smartGroup(rules.All, " AND ")
+}
+
+func smartGroup(rules []models.SmartRule, sep string) (string, []any, error) {
+	if len(rules) == 0 {
+		return "1 = 1", nil, nil
+	}
+	parts := make([]string, len(rules))
+	var args []any
+	for i, rule := range rules {
+		sql, ruleArgs, err := smartCondition(rule)
+		if err != nil {
+			return "", nil, err
+		}
+		parts[i] = "(" + sql + ")"
+		args = append(args, ruleArgs...)
+	}
+	return strings.Join(parts, sep), args, nil
+}
+
+func smartCondition(rule models.SmartRule) (string, []any, error) {
+	switch {
+	case rule.All != nil:
+		return smartGroup(rule.All, " AND ")
+	case rule.Any != nil:
+		return smartGroup(rule.Any, " OR ")
+	}
+
+	field, ok := smartFields[strings.ToLower(rule.Field)]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown field %q", rule.Field)
+	}
+	col := field.column
+	op := strings.ToLower(rule.Operator)
+
+	if field.typ == smartDate {
+		return smartDateCondition(col, op, rule)
+	}
+
+	switch op {
+	case "is", "isnot":
+		var sql string
+		var args []any
+		if field.typ == smartNumber {
+			n, err := smartNumberValue(rule.Value)
+			if err != nil {
+				return "", nil, fmt.Errorf("%s: %w", rule.Field, err)
+			}
+			sql, args = col+" = ?", []any{n}
+		} else if col == "children.genre" {
+			sql, args = "EXISTS (SELECT 1 FROM song_genres WHERE song_genres.child_id = children.id AND LOWER(song_genres.genre_name) = LOWER(?))", []any{fmt.Sprint(rule.Value)}
+		} else {
+			sql, args = "LOWER("+col+") = LOWER(?)", []any{fmt.Sprint(rule.Value)}
+		}
+		if op == "isnot" {
+			sql = "NOT (" + sql + ")"
+		}
+		return sql, args, nil
+	case "contains", "notcontains", "startswith", "endswith":
+		if field.typ != smartText {
+			return "", nil, fmt.Errorf("%s can't be used with %s", rule.Operator, rule.Field)
+		}
+		pattern := escapeLike(fmt.Sprint(rule.Value))
+		switch op {
+		case "startswith":
+			pattern += "%"
+		case "endswith":
+			pattern = "%" + pattern
+		default:
+			pattern = "%" + pattern + "%"
+		}
+		if op == "notcontains" {
+			return "COALESCE(" + col + ", '') NOT LIKE ? ESCAPE '\\'", []any{pattern}, nil
+		}
+		return col + " LIKE ? ESCAPE '\\'", []any{pattern}, nil
+	case "gt", "lt":
+		if field.typ != smartNumber {
+			return "", nil, fmt.Errorf("%s can't be used with %s", rule.Operator, rule.Field)
+		}
+		n, err := smartNumberValue(rule.Value)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", rule.Field, err)
+		}
+		if op == "gt" {
+			return col + " > ?", []any{n}, nil
+		}
+		return col + " < ?", []any{n}, nil
+	case "intherange":
+		if field.typ != smartNumber {
+			return "", nil, fmt.Errorf("%s can't be used with %s", rule.Operator, rule.Field)
+		}
+		bounds, ok := rule.Value.([]any)
+		if !ok || len(bounds) != 2 {
+			return "", nil, fmt.Errorf("%s: inTheRange expects [from, to]", rule.Field)
+		}
+		from, err := smartNumberValue(bounds[0])
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", rule.Field, err)
+		}
+		to, err := smartNumberValue(bounds[1])
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", rule.Field, err)
+		}
+		return col + " BETWEEN ? AND ?", []any{min(from, to), max(from, to)}, nil
+	}
+	return "", nil, fmt.Errorf("unknown operator %q for %s", rule.Operator, rule.Field)
+}
+
+// smartDateCondition handles rules on timestamps. Songs never played or starred have no
+// timestamp, they match "is false" and "notInTheLast" but none of the comparisons.
+func smartDateCondition(col, op string, rule models.SmartRule) (string, []any, error) {
+	switch op {
+	case "is", "isnot":
+		set, ok := rule.Value.(bool)
+		if !ok {
+			return "", nil, fmt.Errorf("%s: %s expects true or false", rule.Field, rule.Operator)
+		}
+		if set == (op == "is") {
+			return col + " IS NOT NULL", nil, nil
+		}
+		return col + " IS NULL", nil, nil
+	case "inthelast", "notinthelast":
+		days, err := smartNumberValue(rule.Value)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", rule.Field, err)
+		}
+		since := time.Now().Add(-time.Duration(days * float64(24*time.Hour)))
+		if op == "inthelast" {
+			return col + " >= ?", []any{since}, nil
+		}
+		return col + " IS NULL OR " + col + " < ?", []any{since}, nil
+	case "before", "after", "lt", "gt":
+		date, err := smartDateValue(rule.Value)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", rule.Field, err)
+		}
+		if op == "before" || op == "lt" {
+			return col + " < ?", []any{date}, nil
+		}
+		return col + " >= ?", []any{date.AddDate(0, 0, 1)}, nil
+	case "intherange":
+		bounds, ok := rule.Value.([]any)
+		if !ok || len(bounds) != 2 {
+			return "", nil, fmt.Errorf("%s: inTheRange expects [from, to]", rule.Field)
+		}
+		from, err := smartDateValue(bounds[0])
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", rule.Field, err)
+		}
+		to, err := smartDateValue(bounds[1])
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", rule.Field, err)
+		}
+		if to.Before(from) {
+			from, to = to, from
+		}
+		return col + " >= ? AND " + col + " < ?", []any{from, to.AddDate(0, 0, 1)}, nil
+	}
+	return "", nil, fmt.Errorf("unknown operator %q for %s", rule.Operator, rule.Field)
+}
+
+func smartNumberValue(v any) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case string:
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("%v is not a number", v)
+}
+
+// smartDateValue parses a "2006-01-02" date, the day starts at local midnight.
+func smartDateValue(v any) (time.Time, error) {
+	s, _ := v.(string)
+	date, err := time.ParseInLocation(time.DateOnly, s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%v is not a date (YYYY-MM-DD)", v)
+	}
+	return date, nil
+}
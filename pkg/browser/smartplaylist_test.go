@@ -0,0 +1,63 @@
+package browser
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stkevintan/miko/models"
+)
+
+func TestSmartWhere(t *testing.T) {
+	tests := []struct {
+		in   string
+		sql  string
+		args []any
+	}{
+		{
+			`{"all": [{"is": {"filetype": "flac"}}, {"gt": {"rating": 3}}]}`,
+			`(LOWER(children.suffix) = LOWER(?)) AND (children.user_rating > ?)`,
+			[]any{"flac", 3.0},
+		},
+		{
+			`{"any": [{"inTheRange": {"year": [1989, 1980]}}, {"all": [{"contains": {"title": "love"}}, {"is": {"loved": true}}]}]}`,
+			`(children.year BETWEEN ? AND ?) OR ((children.title LIKE ? ESCAPE '\') AND (children.starred IS NOT NULL))`,
+			[]any{1980.0, 1989.0, "%love%"},
+		},
+		{
+			`{"all": [{"isNot": {"genre": "Rock"}}]}`,
+			`(NOT (EXISTS (SELECT 1 FROM song_genres WHERE song_genres.child_id = children.id AND LOWER(song_genres.genre_name) = LOWER(?))))`,
+			[]any{"Rock"},
+		},
+	}
+	for _, tt := range tests {
+		var rules models.SmartRules
+		if err := json.Unmarshal([]byte(tt.in), &rules); err != nil {
+			t.Fatalf("unmarshal %s: %v", tt.in, err)
+		}
+		sql, args, err := smartWhere(&rules)
+		if err != nil {
+			t.Fatalf("smartWhere(%s): %v", tt.in, err)
+		}
+		if sql != tt.sql || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("smartWhere(%s) = %q %v, want %q %v", tt.in, sql, args, tt.sql, tt.args)
+		}
+	}
+}
+
+func TestValidateSmartRules(t *testing.T) {
+	for _, in := range []string{
+		`{"all": [{"is": {"mood": "happy"}}]}`,
+		`{"all": [{"contains": {"year": 1999}}]}`,
+		`{"all": [{"inTheLast": {"lastPlayed": "soon"}}]}`,
+		`{"all": [{"is": {"title": "a"}}], "sort": "mood"}`,
+	} {
+		var rules models.SmartRules
+		if err := json.Unmarshal([]byte(in), &rules); err != nil {
+			t.Fatalf("unmarshal %s: %v", in, err)
+		}
+		if err := ValidateSmartRules(&rules); err == nil {
+			t.Errorf("ValidateSmartRules(%s) succeeded, want error", in)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package browser
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/types"
+)
+
+// TopSong is an entry of the top songs of an artist. Song is set for songs in the library,
+// Remote for the songs of a provider, so both are set for provider songs in the library.
+type TopSong struct {
+	Song      *models.Child `json:"song,omitempty"`
+	Remote    *types.Music  `json:"remote,omitempty"`
+	InLibrary bool          `json:"inLibrary"`
+}
+
+// TopSongs returns the songs of the artist named artist, the most played first. Plays of the
+// same recording on several releases add up, and ties are broken by rating, then by the most
+// recently played.
+func (b *Browser) TopSongs(artist string, count int) ([]models.Child, error) {
+	if count <= 0 {
+		return []models.Child{}, nil
+	}
+	var songs []models.Child
+	err := b.db.Where("children.is_dir = ? AND children.type <> ?", false, models.MediaTypeAudiobook).
+		Where(`children.artist = ? OR children.id IN (
+			SELECT song_artists.child_id FROM song_artists
+			JOIN artist_id3 ON artist_id3.id = song_artists.artist_id3_id
+			WHERE artist_id3.name = ?)`, artist, artist).
+		Find(&songs).Error
+	if err != nil {
+		return nil, err
+	}
+	songs = rankTopSongs(songs)
+	return songs[:min(len(songs), count)], nil
+}
+
+// rankTopSongs merges the releases of a recording into its most played one and orders the
+// recordings by plays, rating and last played time. The songs keep their own play count.
+func rankTopSongs(songs []models.Child) []models.Child {
+	type recording struct {
+		song       models.Child
+		plays      int64
+		rating     int
+		lastPlayed time.Time
+	}
+	var recordings []*recording
+	byKey := make(map[string]*recording)
+	for _, song := range songs {
+		key := recordingKey(song)
+		rec, ok := byKey[key]
+		if !ok {
+			rec = &recording{song: song}
+			byKey[key] = rec
+			recordings = append(recordings, rec)
+		} else if song.PlayCount > rec.song.PlayCount {
+			rec.song = song
+		}
+		rec.plays += song.PlayCount
+		rec.rating = max(rec.rating, song.UserRating)
+		if song.LastPlayed != nil && song.LastPlayed.After(rec.lastPlayed) {
+			rec.lastPlayed = *song.LastPlayed
+		}
+	}
+
+	sort.SliceStable(recordings, func(i, j int) bool {
+		a, b := recordings[i], recordings[j]
+		if a.plays != b.plays {
+			return a.plays > b.plays
+		}
+		if a.rating != b.rating {
+			return a.rating > b.rating
+		}
+		return a.lastPlayed.After(b.lastPlayed)
+	})
+
+	ranked := make([]models.Child, len(recordings))
+	for i, rec := range recordings {
+		ranked[i] = rec.song
+	}
+	return ranked
+}
+
+// MergeTopSongs completes the ranked local top songs of an artist with the top songs of a
+// provider. Songs that were played or rated come first, then the provider songs in their
+// order, matched to library songs by title, and finally the other library songs.
+func MergeTopSongs(local []models.Child, remote []*types.Music, count int) []TopSong {
+	merged := make([]TopSong, 0, count)
+	used := make(map[string]bool)
+	byTitle := make(map[string]int)
+	for i := len(local) - 1; i >= 0; i-- {
+		byTitle[normalizeTitle(local[i].Title)] = i
+	}
+
+	for i := range local {
+		if len(merged) == count || (local[i].PlayCount == 0 && local[i].UserRating == 0) {
+			break
+		}
+		used[local[i].ID] = true
+		merged = append(merged, TopSong{Song: &local[i], InLibrary: true})
+	}
+	for _, music := range remote {
+		if len(merged) == count {
+			break
+		}
+		entry := TopSong{Remote: music}
+		if i, ok := byTitle[normalizeTitle(music.Name)]; ok {
+			if used[local[i].ID] {
+				continue
+			}
+			used[local[i].ID] = true
+			entry.Song, entry.InLibrary = &local[i], true
+		}
+		merged = append(merged, entry)
+	}
+	for i := range local {
+		if len(merged) == count {
+			break
+		}
+		if !used[local[i].ID] {
+			used[local[i].ID] = true
+			merged = append(merged, TopSong{Song: &local[i], InLibrary: true})
+		}
+	}
+	return merged
+}
+
+// normalizeTitle drops case and the version of a title, such as "(Live)" or " - Remastered",
+// which providers and tags often disagree on.
+func normalizeTitle(title string) string {
+	title = strings.ToLower(title)
+	for _, sep := range []string{" (", " [", " - ", "（"} {
+		if i := strings.Index(title, sep); i > 0 {
+			title = title[:i]
+		}
+	}
+	return strings.TrimSpace(title)
+}
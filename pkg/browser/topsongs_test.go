@@ -0,0 +1,89 @@
+package browser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/types"
+)
+
+func songIDs(songs []models.Child) []string {
+	ids := make([]string, len(songs))
+	for i, song := range songs {
+		ids[i] = song.ID
+	}
+	return ids
+}
+
+func TestRankTopSongs(t *testing.T) {
+	earlier := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := earlier.Add(24 * time.Hour)
+	songs := []models.Child{
+		{ID: "a", Title: "A", Artist: "X", PlayCount: 3},
+		{ID: "b", Title: "B", Artist: "X", PlayCount: 2},
+		// the single and the album release of B add up to more plays than A
+		{ID: "b-single", Title: "b", Artist: "x", PlayCount: 4},
+		{ID: "c", Title: "C", Artist: "X", UserRating: 5},
+		{ID: "d", Title: "D", Artist: "X", LastPlayed: &earlier},
+		{ID: "e", Title: "E", Artist: "X", LastPlayed: &later},
+	}
+
+	got := rankTopSongs(songs)
+	want := []string{"b-single", "a", "c", "e", "d"}
+	if ids := songIDs(got); len(ids) != len(want) {
+		t.Fatalf("ranked = %v, want %v", ids, want)
+	}
+	for i, id := range songIDs(got) {
+		if id != want[i] {
+			t.Fatalf("ranked = %v, want %v", songIDs(got), want)
+		}
+	}
+	if got[0].PlayCount != 4 {
+		t.Errorf("plays of the B single = %d, want its own 4 plays", got[0].PlayCount)
+	}
+}
+
+func TestMergeTopSongs(t *testing.T) {
+	local := []models.Child{
+		{ID: "a", Title: "A", PlayCount: 3},
+		{ID: "b", Title: "B (Remastered)"},
+		{ID: "c", Title: "C"},
+	}
+	remote := []*types.Music{
+		{Id: 1, Name: "A"},
+		{Id: 2, Name: "Z"},
+		{Id: 3, Name: "B"},
+	}
+
+	got := MergeTopSongs(local, remote, 4)
+	type entry struct {
+		song      string
+		remote    int64
+		inLibrary bool
+	}
+	want := []entry{{"a", 0, true}, {"", 2, false}, {"b", 3, true}, {"c", 0, true}}
+	if len(got) != len(want) {
+		t.Fatalf("merged %d songs, want %d", len(got), len(want))
+	}
+	for i, top := range got {
+		var e entry
+		if top.Song != nil {
+			e.song = top.Song.ID
+		}
+		if top.Remote != nil {
+			e.remote = top.Remote.Id
+		}
+		e.inLibrary = top.InLibrary
+		if e != want[i] {
+			t.Errorf("merged[%d] = %+v, want %+v", i, e, want[i])
+		}
+	}
+}
+
+func TestTopSongs_NegativeCount(t *testing.T) {
+	songs, err := (&Browser{}).TopSongs("X", -1)
+	if err != nil || len(songs) != 0 {
+		t.Errorf("TopSongs(-1) = %v, %v, want no songs", songs, err)
+	}
+}
@@ -0,0 +1,124 @@
+package collation
+
+import (
+	_ "embed"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+//go:generate go run gen_pinyin.go
+
+// OtherIndex is the index bucket used for names that do not start with a latin letter
+// after transliteration (digits, symbols, unmapped scripts).
+const OtherIndex = "#"
+
+// pinyinTable lists the hanzi of the CJK Unified Ideographs block, simplified and traditional, in
+// pinyin order.
+//
+//go:embed pinyin.txt
+var pinyinTable string
+
+// pinyinKey maps a hanzi to its pinyin initial followed by its rank in pinyinTable.
+type pinyinKey struct {
+	initial byte
+	rank    uint16
+}
+
+var pinyinKeys = sync.OnceValue(func() map[rune]pinyinKey {
+	keys := make(map[rune]pinyinKey, 21000)
+	var initial byte
+	var rank uint16
+	for line := range strings.Lines(pinyinTable) {
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, ":"):
+			initial = line[1]
+		default:
+			for _, r := range line {
+				if _, ok := keys[r]; !ok {
+					keys[r] = pinyinKey{initial, rank}
+				}
+				rank++
+			}
+		}
+	}
+	return keys
+})
+
+// hiragana romanization for U+3041..U+3096. Katakana U+30A1..U+30F6 maps onto the same table.
+var kana = [...]string{
+	"a", "a", "i", "i", "u", "u", "e", "e", "o", "o",
+	"ka", "ga", "ki", "gi", "ku", "gu", "ke", "ge", "ko", "go",
+	"sa", "za", "shi", "ji", "su", "zu", "se", "ze", "so", "zo",
+	"ta", "da", "chi", "ji", "", "tsu", "zu", "te", "de", "to", "do",
+	"na", "ni", "nu", "ne", "no",
+	"ha", "ba", "pa", "hi", "bi", "pi", "fu", "bu", "pu", "he", "be", "pe", "ho", "bo", "po",
+	"ma", "mi", "mu", "me", "mo",
+	"ya", "ya", "yu", "yu", "yo", "yo",
+	"ra", "ri", "ru", "re", "ro",
+	"wa", "wa", "i", "e", "wo", "n", "vu", "ka", "ke",
+}
+
+// StripArticles removes a leading article (e.g. "The") from name.
+func StripArticles(name string, articles []string) string {
+	upperName := strings.ToUpper(name)
+	for _, article := range articles {
+		prefix := strings.ToUpper(article) + " "
+		if strings.HasPrefix(upperName, prefix) {
+			return name[len(prefix):]
+		}
+	}
+	return name
+}
+
+// SortKey returns a lower-cased, romanized key for name that orders names across scripts.
+// Latin text is folded to ASCII, kana is romanized and hanzi are keyed by their pinyin initial
+// followed by their rank in pinyin order.
+func SortKey(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(strings.TrimSpace(name)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// drop combining marks left over from NFD decomposition
+		case r < unicode.MaxASCII:
+			b.WriteRune(unicode.ToLower(r))
+		case r >= 0x3041 && r <= 0x3096:
+			b.WriteString(kana[r-0x3041])
+		case r >= 0x30A1 && r <= 0x30F6:
+			b.WriteString(kana[r-0x30A1])
+		case unicode.Is(unicode.Han, r):
+			b.WriteString(hanKey(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// IndexName returns the index bucket ("A".."Z" or OtherIndex) for a sort key produced by SortKey.
+func IndexName(sortKey string) string {
+	for _, r := range sortKey {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		if r >= 'a' && r <= 'z' {
+			return string(unicode.ToUpper(r))
+		}
+		return OtherIndex
+	}
+	return OtherIndex
+}
+
+func hanKey(r rune) string {
+	key, ok := pinyinKeys()[r]
+	if !ok {
+		// pinyin order is unknown
+		return string(r)
+	}
+	const hex = "0123456789abcdef"
+	return string([]byte{key.initial, hex[key.rank>>12], hex[key.rank>>8&0xF], hex[key.rank>>4&0xF], hex[key.rank&0xF]})
+}
@@ -0,0 +1,60 @@
+package collation
+
+import "testing"
+
+func TestIndexName(t *testing.T) {
+	cases := map[string]string{
+		"Beatles":   "B",
+		"Beyoncé":   "B",
+		"周杰伦":       "Z",
+		"陈奕迅":       "C",
+		"陳奕迅":       "C",
+		"張學友":       "Z",
+		"劉德華":       "L",
+		"鄧紫棋":       "D",
+		"昊":         "H",
+		"囧":         "J",
+		"あいみょん":     "A",
+		"ヨルシカ":      "Y",
+		"2Pac":      OtherIndex,
+		"!!!":       OtherIndex,
+		"":          OtherIndex,
+		"Élodie":    "E",
+		"  spacing": "S",
+	}
+	for name, want := range cases {
+		if got := IndexName(SortKey(name)); got != want {
+			t.Errorf("IndexName(SortKey(%q)) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSortKey_OrdersHanziByPinyin(t *testing.T) {
+	// 阿 (a) < 陈 (chen) < 林 (lin) < 周 (zhou)
+	names := []string{"阿杜", "陈奕迅", "林俊杰", "周杰伦"}
+	for i := 1; i < len(names); i++ {
+		if SortKey(names[i-1]) >= SortKey(names[i]) {
+			t.Errorf("expected %q to sort before %q", names[i-1], names[i])
+		}
+	}
+}
+
+func TestSortKey_TraditionalHanzi(t *testing.T) {
+	// traditional characters sort with their simplified forms, 鄧 (deng) < 劉 (liu) < 張 (zhang)
+	names := []string{"鄧紫棋", "劉德華", "張學友"}
+	for i := 1; i < len(names); i++ {
+		if SortKey(names[i-1]) >= SortKey(names[i]) {
+			t.Errorf("expected %q to sort before %q", names[i-1], names[i])
+		}
+	}
+}
+
+func TestStripArticles(t *testing.T) {
+	articles := []string{"The", "El"}
+	if got := StripArticles("The Beatles", articles); got != "Beatles" {
+		t.Errorf("StripArticles = %q, want %q", got, "Beatles")
+	}
+	if got := StripArticles("Theatre", articles); got != "Theatre" {
+		t.Errorf("StripArticles = %q, want %q", got, "Theatre")
+	}
+}
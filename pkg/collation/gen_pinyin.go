@@ -0,0 +1,85 @@
+//go:build ignore
+
+// gen_pinyin writes pinyin.txt from the CLDR pinyin collation shipped with Perl's
+// Unicode::Collate::CJK::Pinyin module, the installed one unless its path is given.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const header = `# Hanzi in pinyin order, generated by gen_pinyin.go from the CLDR pinyin collation.
+# A line starting with ":" opens the hanzi of a pinyin initial, the other lines hold the hanzi
+# of a reading.
+`
+
+func main() {
+	var path string
+	switch len(os.Args) {
+	case 1:
+		out, err := exec.Command("perl", "-MUnicode::Collate::CJK::Pinyin", "-e", `print $INC{"Unicode/Collate/CJK/Pinyin.pm"}`).Output()
+		if err != nil {
+			log.Fatalf("locate Unicode::Collate::CJK::Pinyin: %v", err)
+		}
+		path = strings.TrimSpace(string(out))
+	case 2:
+		path = os.Args[1]
+	default:
+		log.Fatal("usage: go run gen_pinyin.go [Pinyin.pm]")
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer in.Close()
+
+	var out strings.Builder
+	out.WriteString(header)
+	data := false
+	sc := bufio.NewScanner(in)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "__DATA__":
+			data = true
+			continue
+		case line == "__END__":
+			data = false
+		}
+		if !data || line == "" {
+			continue
+		}
+		var hanzi strings.Builder
+		for _, field := range strings.Fields(line) {
+			// FDD0-0041 and on mark where the hanzi of the initial A, B... start
+			if marker, ok := strings.CutPrefix(field, "FDD0-"); ok {
+				letter, err := strconv.ParseUint(marker, 16, 8)
+				if err != nil {
+					log.Fatalf("invalid marker %q", field)
+				}
+				fmt.Fprintf(&out, ":%c\n", letter|0x20)
+				continue
+			}
+			code, err := strconv.ParseUint(field, 16, 32)
+			if err != nil {
+				log.Fatalf("invalid code point %q", field)
+			}
+			hanzi.WriteRune(rune(code))
+		}
+		if hanzi.Len() > 0 {
+			out.WriteString(hanzi.String() + "\n")
+		}
+	}
+	if err := sc.Err(); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("pinyin.txt", []byte(out.String()), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,677 @@
+package downloads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/events"
+	"github.com/stkevintan/miko/pkg/log"
+	"github.com/stkevintan/miko/pkg/provider"
+	"github.com/stkevintan/miko/pkg/scanner"
+	"github.com/stkevintan/miko/pkg/types"
+	"gorm.io/gorm"
+)
+
+const (
+	// workers is the number of songs downloaded at the same time
+	workers = 3
+	// maxAttempts is the number of times a song is tried before its item fails
+	maxAttempts = 5
+	// pollInterval is how often the queue looks for items whose retry is due
+	pollInterval = 15 * time.Second
+)
+
+// ErrInvalidState is returned when a job can't be paused, resumed, cancelled or retried in its
+// current status.
+var ErrInvalidState = errors.New("invalid job state")
+
+// runnable are the statuses of jobs whose items are downloaded
+var runnable = []string{models.DownloadQueued, models.DownloadRunning}
+
+// Types of the events published by the queue
+const (
+	EventProgress = "progress"
+	EventJob      = "job"
+)
+
+// Progress is the progress of the download of an item of a job. JobID and ItemID are zero for
+// downloads run outside of the queue.
+type Progress struct {
+	JobID  uint `json:"jobId"`
+	ItemID uint `json:"itemId"`
+	types.DownloadProgress
+}
+
+// Providers creates the provider of platform signed in as the user.
+type Providers func(ctx context.Context, username, platform string) (provider.Provider, error)
+
+// Queue downloads the songs of persisted download jobs with a pool of workers. Jobs survive
+// restarts: items that were being downloaded are queued again when the queue starts.
+type Queue struct {
+	db        *gorm.DB
+	providers Providers
+	bus       *events.Bus
+	scanner   *scanner.Scanner
+	wake      chan struct{}
+
+	mu       sync.Mutex
+	ctx      context.Context
+	sessions map[uint]provider.Provider
+	running  map[uint]map[uint]context.CancelFunc
+}
+
+// New creates a queue publishing the progress of its jobs on bus. The downloaded songs are
+// indexed by scanner when they land in a music folder.
+func New(db *gorm.DB, providers Providers, bus *events.Bus, scanner *scanner.Scanner) *Queue {
+	return &Queue{
+		db:        db,
+		providers: providers,
+		bus:       bus,
+		scanner:   scanner,
+		wake:      make(chan struct{}, 1),
+		ctx:       context.Background(),
+		sessions:  make(map[uint]provider.Provider),
+		running:   make(map[uint]map[uint]context.CancelFunc),
+	}
+}
+
+// Enqueue creates a job downloading the songs of uris and returns it without waiting for it.
+func (q *Queue) Enqueue(username, platform string, uris []string, config types.DownloadConfig) (*models.DownloadJob, error) {
+	job, err := newJob(username, platform, uris, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.db.Create(job).Error; err != nil {
+		return nil, err
+	}
+	q.publish(*job)
+	q.notify()
+	return job, nil
+}
+
+// EnqueueMusic creates a job downloading music, the songs uris were resolved to already, and
+// returns it without waiting for it.
+func (q *Queue) EnqueueMusic(username, platform string, uris []string, music []*types.Music, config types.DownloadConfig) (*models.DownloadJob, error) {
+	job, err := newJob(username, platform, uris, config)
+	if err != nil {
+		return nil, err
+	}
+	music, skipped := q.skipInLibrary(job, music)
+	err = q.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(job).Error; err != nil {
+			return err
+		}
+		return addItems(tx, job.ID, music, skipped)
+	})
+	if err != nil {
+		return nil, err
+	}
+	q.updateJob(job.ID)
+	if err := q.db.First(job, job.ID).Error; err != nil {
+		return nil, err
+	}
+	q.notify()
+	return job, nil
+}
+
+func newJob(username, platform string, uris []string, config types.DownloadConfig) (*models.DownloadJob, error) {
+	if config.Output != "" && !filepath.IsAbs(config.Output) {
+		abs, err := filepath.Abs(config.Output)
+		if err != nil {
+			return nil, fmt.Errorf("resolve output path: %w", err)
+		}
+		config.Output = abs
+	}
+	var template string
+	if config.PathTemplate != nil {
+		template = config.PathTemplate.String()
+	}
+	job := &models.DownloadJob{
+		Username:       username,
+		Platform:       platform,
+		URIs:           uris,
+		Level:          config.Level,
+		Output:         config.Output,
+		ConflictPolicy: config.ConflictPolicy,
+		PathTemplate:   template,
+		Status:         models.DownloadQueued,
+	}
+	return job, nil
+}
+
+// Jobs returns the download jobs of the user, the most recent first.
+func (q *Queue) Jobs(username string, limit, offset int) ([]models.DownloadJob, error) {
+	var jobs []models.DownloadJob
+	err := q.db.Where("username = ?", username).Order("id DESC").Limit(limit).Offset(offset).Find(&jobs).Error
+	return jobs, err
+}
+
+// Job returns the download job of the user with its items.
+func (q *Queue) Job(username string, id uint) (*models.DownloadJob, error) {
+	var job models.DownloadJob
+	err := q.db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Where("id = ? AND username = ?", id, username).First(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Pause stops downloading the songs of a queued or running job, songs being downloaded are
+// interrupted and downloaded again once the job is resumed.
+func (q *Queue) Pause(username string, id uint) (*models.DownloadJob, error) {
+	job, err := q.transition(username, id, runnable, models.DownloadPaused, nil)
+	if err != nil {
+		return nil, err
+	}
+	q.interrupt(id)
+	return job, nil
+}
+
+// Resume queues a paused job again.
+func (q *Queue) Resume(username string, id uint) (*models.DownloadJob, error) {
+	return q.transition(username, id, []string{models.DownloadPaused}, models.DownloadQueued, nil)
+}
+
+// Cancel stops a job for good, songs already downloaded are kept.
+func (q *Queue) Cancel(username string, id uint) (*models.DownloadJob, error) {
+	_, err := q.transition(username, id, []string{models.DownloadQueued, models.DownloadRunning, models.DownloadPaused}, models.DownloadCancelled, func(tx *gorm.DB) error {
+		return tx.Model(&models.DownloadItem{}).
+			Where("job_id = ? AND status = ?", id, models.DownloadPending).
+			Update("status", models.DownloadCancelled).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	q.interrupt(id)
+	q.updateJob(id)
+	return q.Job(username, id)
+}
+
+// Retry queues the failed and cancelled songs of a finished job again, or the whole job when
+// its URIs couldn't be resolved.
+func (q *Queue) Retry(username string, id uint) (*models.DownloadJob, error) {
+	finished := []string{models.DownloadCompleted, models.DownloadFailed, models.DownloadCancelled}
+	_, err := q.transition(username, id, finished, models.DownloadQueued, func(tx *gorm.DB) error {
+		if err := tx.Model(&models.DownloadJob{}).Where("id = ?", id).
+			Updates(map[string]any{"finished_at": nil, "error": ""}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.DownloadItem{}).
+			Where("job_id = ? AND status IN ?", id, []string{models.DownloadFailed, models.DownloadCancelled}).
+			Updates(map[string]any{
+				"status":       models.DownloadPending,
+				"attempts":     0,
+				"next_attempt": time.Now(),
+				"error":        "",
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	// a job without anything to retry is finished again right away
+	q.updateJob(id)
+	return q.Job(username, id)
+}
+
+// transition moves the job of the user from one of the statuses from to status to, running
+// then in the same transaction.
+func (q *Queue) transition(username string, id uint, from []string, to string, then func(tx *gorm.DB) error) (*models.DownloadJob, error) {
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		var job models.DownloadJob
+		if err := tx.Select("id, status").Where("id = ? AND username = ?", id, username).First(&job).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&models.DownloadJob{}).Where("id = ? AND status IN ?", id, from).Update("status", to)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("%w: job is %s", ErrInvalidState, job.Status)
+		}
+		if then != nil {
+			return then(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if to != models.DownloadQueued {
+		q.closeSession(id)
+	}
+	q.notify()
+	job, err := q.Job(username, id)
+	if err != nil {
+		return nil, err
+	}
+	q.publish(*job)
+	return job, nil
+}
+
+// publish announces the new state of a job to its user.
+func (q *Queue) publish(job models.DownloadJob) {
+	job.Items = nil
+	q.bus.Publish(events.Event{Username: job.Username, Type: EventJob, Data: job})
+}
+
+func (q *Queue) publishByID(id uint) {
+	var job models.DownloadJob
+	if err := q.db.First(&job, id).Error; err != nil {
+		log.Error("Failed to load download job %d: %v", id, err)
+		return
+	}
+	q.publish(job)
+}
+
+// Run downloads queued songs until ctx is done.
+func (q *Queue) Run(ctx context.Context) {
+	q.mu.Lock()
+	q.ctx = ctx
+	q.mu.Unlock()
+
+	q.requeue()
+
+	items := make(chan models.DownloadItem)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				q.download(ctx, item)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		q.dispatch(ctx, items)
+		select {
+		case <-ctx.Done():
+			close(items)
+			wg.Wait()
+			q.mu.Lock()
+			for id := range q.sessions {
+				q.closeSessionLocked(id)
+			}
+			q.mu.Unlock()
+			return
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// requeue puts back the items interrupted by a shutdown, they are downloaded again.
+func (q *Queue) requeue() {
+	if err := q.db.Model(&models.DownloadItem{}).Where("status = ?", models.DownloadRunning).
+		Update("status", models.DownloadPending).Error; err != nil {
+		log.Error("Failed to requeue interrupted downloads: %v", err)
+	}
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch resolves the queued jobs and hands the due items to the workers.
+func (q *Queue) dispatch(ctx context.Context, items chan<- models.DownloadItem) {
+	var jobs []models.DownloadJob
+	if err := q.db.Where("status IN ? AND resolved = ?", runnable, false).Order("id").Find(&jobs).Error; err != nil {
+		log.Error("Failed to load download jobs: %v", err)
+		return
+	}
+	for i := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		q.resolve(ctx, &jobs[i])
+	}
+
+	for ctx.Err() == nil {
+		item, ok := q.claim()
+		if !ok {
+			return
+		}
+		select {
+		case items <- item:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// resolve creates the items of a job from its URIs.
+func (q *Queue) resolve(ctx context.Context, job *models.DownloadJob) {
+	p, err := q.session(job)
+	var music []*types.Music
+	if err == nil {
+		music, err = p.GetMusic(ctx, job.URIs)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn("Failed to resolve download job %d: %v", job.ID, err)
+		q.finish(job.ID, models.DownloadFailed, err.Error())
+		return
+	}
+
+	music, skipped := q.skipInLibrary(job, music)
+	err = q.db.Transaction(func(tx *gorm.DB) error {
+		return addItems(tx, job.ID, music, skipped)
+	})
+	if err != nil {
+		log.Error("Failed to save the items of download job %d: %v", job.ID, err)
+		return
+	}
+	q.updateJob(job.ID)
+}
+
+// skipInLibrary leaves out the songs of music in the library already when job skips existing
+// files, so re-running a job, such as the discography of an artist, only fetches what is new.
+func (q *Queue) skipInLibrary(job *models.DownloadJob, music []*types.Music) ([]*types.Music, int) {
+	if types.ConflictPolicy(job.ConflictPolicy) != types.ConflictPolicySkip {
+		return music, 0
+	}
+	kept, skipped, err := skipInLibrary(q.db, job.Platform, music)
+	if err != nil {
+		log.Warn("Failed to look up the songs to download for %s in the library: %v", job.Username, err)
+	}
+	return kept, skipped
+}
+
+// addItems saves music as the items of a job, which is resolved then. A job paused or cancelled
+// while it was resolved keeps its status, the items of a cancelled one are cancelled.
+func addItems(tx *gorm.DB, jobID uint, music []*types.Music, skipped int) error {
+	var job models.DownloadJob
+	if err := tx.Select("id, status").First(&job, jobID).Error; err != nil {
+		return err
+	}
+	status := models.DownloadPending
+	if job.Status == models.DownloadCancelled {
+		status = models.DownloadCancelled
+	}
+	now := time.Now()
+	for i, m := range music {
+		item := models.DownloadItem{JobID: jobID, Position: i, Music: *m, Status: status, NextAttempt: now}
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Model(&models.DownloadJob{}).Where("id = ?", jobID).
+		Updates(map[string]any{"resolved": true, "total": len(music), "skipped": skipped}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.DownloadJob{}).Where("id = ? AND status IN ?", jobID, runnable).
+		Update("status", models.DownloadRunning).Error
+}
+
+// claim marks the next due item of a runnable job as running.
+func (q *Queue) claim() (models.DownloadItem, bool) {
+	var item models.DownloadItem
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Joins("JOIN download_jobs ON download_jobs.id = download_items.job_id").
+			Where("download_items.status = ? AND download_items.next_attempt <= ?", models.DownloadPending, time.Now()).
+			Where("download_jobs.status IN ?", runnable).
+			Order("download_items.job_id, download_items.position").
+			Limit(1).Find(&item).Error
+		if err != nil || item.ID == 0 {
+			return err
+		}
+		if err := tx.Model(&item).Update("status", models.DownloadRunning).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.DownloadJob{}).Where("id = ? AND status = ?", item.JobID, models.DownloadQueued).
+			Update("status", models.DownloadRunning).Error
+	})
+	if err != nil {
+		log.Error("Failed to claim a download: %v", err)
+		return item, false
+	}
+	return item, item.ID != 0
+}
+
+// download downloads the song of an item.
+func (q *Queue) download(ctx context.Context, item models.DownloadItem) {
+	var job models.DownloadJob
+	if err := q.db.First(&job, item.JobID).Error; err != nil {
+		log.Error("Failed to load download job %d: %v", item.JobID, err)
+		return
+	}
+	if !isRunnable(job.Status) {
+		q.interrupted(item, job.Status)
+		return
+	}
+
+	p, err := q.session(&job)
+	if err != nil {
+		q.retry(item, err)
+		q.updateJob(job.ID)
+		return
+	}
+
+	itemCtx, cancel := context.WithCancel(ctx)
+	q.mu.Lock()
+	if q.running[job.ID] == nil {
+		q.running[job.ID] = make(map[uint]context.CancelFunc)
+	}
+	q.running[job.ID][item.ID] = cancel
+	q.mu.Unlock()
+
+	var template *types.PathTemplate
+	if job.PathTemplate != "" {
+		if template, err = types.ParsePathTemplate(job.PathTemplate); err != nil {
+			log.Warn("download job %d: %v, naming files after the song", job.ID, err)
+		}
+	}
+	music := item.Music
+	results, err := p.Download(itemCtx, []*types.Music{&music}, &types.DownloadConfig{
+		Level:          job.Level,
+		Output:         job.Output,
+		ConflictPolicy: job.ConflictPolicy,
+		PathTemplate:   template,
+		OnProgress: func(progress types.DownloadProgress) {
+			q.bus.Publish(events.Event{
+				Username: job.Username,
+				Type:     EventProgress,
+				Data:     Progress{JobID: job.ID, ItemID: item.ID, DownloadProgress: progress},
+			})
+		},
+	})
+
+	q.mu.Lock()
+	delete(q.running[job.ID], item.ID)
+	q.mu.Unlock()
+	interrupted := itemCtx.Err() != nil
+	cancel()
+
+	if ctx.Err() != nil {
+		// shutting down, the item is queued again on the next start
+		return
+	}
+	if interrupted {
+		if err := q.db.Select("status").First(&job, job.ID).Error; err == nil {
+			q.interrupted(item, job.Status)
+		}
+		q.updateJob(job.ID)
+		return
+	}
+
+	var downloaded *types.DownloadedMusic
+	if err == nil {
+		if r := results.Results(); len(r) == 0 {
+			err = errors.New("no download result")
+		} else if r[0].Err != nil {
+			err = r[0].Err
+		} else {
+			q.scanner.IndexDownloads(ctx, r)
+			downloaded = r[0].Data
+		}
+	}
+	if err != nil {
+		log.Warn("Failed to download %s: %v", item.Music.String(), err)
+		q.retry(item, err)
+	} else {
+		err = q.db.Model(&item).Updates(map[string]any{
+			"status":    models.DownloadCompleted,
+			"attempts":  item.Attempts + 1,
+			"error":     "",
+			"file_path": downloaded.FilePath,
+			"song_id":   downloaded.SongID,
+			"quality":   downloaded.Quality,
+			"size":      downloaded.Size,
+		}).Error
+		if err != nil {
+			log.Error("Failed to save download %d: %v", item.ID, err)
+		}
+	}
+	q.updateJob(job.ID)
+}
+
+// interrupted puts back an item whose job was paused or cancelled while it was downloaded.
+func (q *Queue) interrupted(item models.DownloadItem, jobStatus string) {
+	status := models.DownloadPending
+	if jobStatus == models.DownloadCancelled {
+		status = models.DownloadCancelled
+	}
+	if err := q.db.Model(&item).Update("status", status).Error; err != nil {
+		log.Error("Failed to save download %d: %v", item.ID, err)
+	}
+}
+
+// retry schedules another attempt of a failed item, or fails it when it is out of attempts.
+func (q *Queue) retry(item models.DownloadItem, cause error) {
+	attempts := item.Attempts + 1
+	updates := map[string]any{"attempts": attempts, "error": cause.Error()}
+	if attempts >= maxAttempts {
+		updates["status"] = models.DownloadFailed
+	} else {
+		updates["status"] = models.DownloadPending
+		updates["next_attempt"] = time.Now().Add(backoff(attempts))
+	}
+	if err := q.db.Model(&item).Updates(updates).Error; err != nil {
+		log.Error("Failed to save download %d: %v", item.ID, err)
+	}
+}
+
+// backoff returns the delay before the next attempt of an item that failed attempts times.
+func backoff(attempts int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < 30*time.Minute; i++ {
+		delay *= 2
+	}
+	return min(delay, 30*time.Minute)
+}
+
+// updateJob counts the finished items of a job and finishes it when none is left.
+func (q *Queue) updateJob(id uint) {
+	var job models.DownloadJob
+	if err := q.db.Select("id, resolved").First(&job, id).Error; err != nil {
+		log.Error("Failed to load download job %d: %v", id, err)
+		return
+	}
+	if !job.Resolved {
+		return
+	}
+
+	var counts []struct {
+		Status string
+		N      int
+	}
+	if err := q.db.Model(&models.DownloadItem{}).Select("status, COUNT(*) AS n").
+		Where("job_id = ?", id).Group("status").Scan(&counts).Error; err != nil {
+		log.Error("Failed to count the items of download job %d: %v", id, err)
+		return
+	}
+	var done, failed, open int
+	for _, c := range counts {
+		switch c.Status {
+		case models.DownloadCompleted:
+			done += c.N
+		case models.DownloadFailed:
+			failed += c.N
+		case models.DownloadPending, models.DownloadRunning:
+			open += c.N
+		}
+	}
+	if err := q.db.Model(&models.DownloadJob{}).Where("id = ?", id).
+		Updates(map[string]any{"done": done, "failed": failed}).Error; err != nil {
+		log.Error("Failed to update download job %d: %v", id, err)
+		return
+	}
+	if open > 0 {
+		q.publishByID(id)
+		return
+	}
+	status := models.DownloadCompleted
+	if failed > 0 && done == 0 {
+		status = models.DownloadFailed
+	}
+	q.finish(id, status, "")
+}
+
+// finish ends a runnable job with status.
+func (q *Queue) finish(id uint, status, message string) {
+	now := time.Now()
+	err := q.db.Model(&models.DownloadJob{}).Where("id = ? AND status IN ?", id, runnable).
+		Updates(map[string]any{"status": status, "error": message, "finished_at": &now}).Error
+	if err != nil {
+		log.Error("Failed to finish download job %d: %v", id, err)
+	}
+	q.closeSession(id)
+	q.publishByID(id)
+}
+
+// session returns the provider the songs of a job are downloaded with, creating it on first use.
+func (q *Queue) session(job *models.DownloadJob) (provider.Provider, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if p, ok := q.sessions[job.ID]; ok {
+		return p, nil
+	}
+	p, err := q.providers(q.ctx, job.Username, job.Platform)
+	if err != nil {
+		return nil, fmt.Errorf("create provider: %w", err)
+	}
+	q.sessions[job.ID] = p
+	return p, nil
+}
+
+func (q *Queue) closeSession(id uint) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closeSessionLocked(id)
+}
+
+func (q *Queue) closeSessionLocked(id uint) {
+	if p, ok := q.sessions[id]; ok {
+		delete(q.sessions, id)
+		if err := p.Close(context.Background()); err != nil {
+			log.Debug("Failed to close the provider of download job %d: %v", id, err)
+		}
+	}
+}
+
+// interrupt stops the downloads of the items of a job.
+func (q *Queue) interrupt(id uint) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, cancel := range q.running[id] {
+		cancel()
+	}
+	delete(q.running, id)
+}
+
+func isRunnable(status string) bool {
+	return status == models.DownloadQueued || status == models.DownloadRunning
+}
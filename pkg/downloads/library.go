@@ -0,0 +1,78 @@
+package downloads
+
+import (
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/types"
+	"gorm.io/gorm"
+)
+
+// LibrarySongs returns the library IDs of the songs of platform among ids that are in the
+// library: by the ID on platform their files are tagged with, or else by the download job that
+// saved them, for files downloaded before songs were tagged.
+func LibrarySongs(db *gorm.DB, platform string, ids []int64) (map[int64]string, error) {
+	type match struct {
+		MusicID int64
+		SongID  string
+	}
+	songs := make(map[int64]string, len(ids))
+	if len(ids) == 0 {
+		return songs, nil
+	}
+
+	if platform == "netease" {
+		var tagged []match
+		err := db.Model(&models.Child{}).
+			Select("netease_id AS music_id, MIN(id) AS song_id").
+			Where("is_dir = ? AND netease_id IN ?", false, ids).
+			Group("netease_id").
+			Scan(&tagged).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range tagged {
+			songs[m.MusicID] = m.SongID
+		}
+	}
+
+	var downloaded []match
+	err := db.Model(&models.DownloadItem{}).
+		Select("json_extract(download_items.music, '$.id') AS music_id, MAX(download_items.song_id) AS song_id").
+		Joins("JOIN download_jobs ON download_jobs.id = download_items.job_id").
+		Joins("JOIN children ON children.id = download_items.song_id").
+		Where("download_items.status = ? AND download_jobs.platform = ?", models.DownloadCompleted, platform).
+		Where("json_extract(download_items.music, '$.id') IN ?", ids).
+		Group("music_id").
+		Scan(&downloaded).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range downloaded {
+		if _, ok := songs[m.MusicID]; !ok {
+			songs[m.MusicID] = m.SongID
+		}
+	}
+	return songs, nil
+}
+
+// skipInLibrary drops the songs of music that are in the library already and returns how many
+// were dropped. NetEase doesn't expose the ISRC of songs, so they are matched by ID only.
+func skipInLibrary(db *gorm.DB, platform string, music []*types.Music) ([]*types.Music, int, error) {
+	if len(music) == 0 {
+		return music, 0, nil
+	}
+	ids := make([]int64, 0, len(music))
+	for _, m := range music {
+		ids = append(ids, m.Id)
+	}
+	known, err := LibrarySongs(db, platform, ids)
+	if err != nil {
+		return music, 0, err
+	}
+	kept := make([]*types.Music, 0, len(music))
+	for _, m := range music {
+		if _, ok := known[m.Id]; !ok {
+			kept = append(kept, m)
+		}
+	}
+	return kept, len(music) - len(kept), nil
+}
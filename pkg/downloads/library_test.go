@@ -0,0 +1,37 @@
+package downloads
+
+import (
+	"testing"
+
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/types"
+)
+
+func TestSkipInLibrary(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&models.Child{ID: "tagged", Path: "/tagged.mp3", NeteaseID: 1})
+	db.Create(&models.Child{ID: "downloaded", Path: "/downloaded.mp3"})
+	// downloaded before songs were tagged with their ID
+	job := models.DownloadJob{Username: "alice", Platform: "netease", Status: models.DownloadCompleted}
+	db.Create(&job)
+	db.Create(&models.DownloadItem{JobID: job.ID, Status: models.DownloadCompleted, SongID: "downloaded", Music: types.Music{Id: 3}})
+	// a removed song doesn't count
+	db.Create(&models.DownloadItem{JobID: job.ID, Status: models.DownloadCompleted, SongID: "removed", Music: types.Music{Id: 4}})
+
+	music := []*types.Music{{Id: 1}, {Id: 3}, {Id: 4}, {Id: 5}}
+	kept, skipped, err := skipInLibrary(db, "netease", music)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != 2 || len(kept) != 2 || kept[0].Id != 4 || kept[1].Id != 5 {
+		t.Errorf("skipInLibrary() kept %d songs, skipped %d, want songs 4 and 5", len(kept), skipped)
+	}
+
+	songs, err := LibrarySongs(db, "netease", []int64{1, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(songs) != 2 || songs[1] != "tagged" || songs[3] != "downloaded" {
+		t.Errorf("LibrarySongs() = %v", songs)
+	}
+}
@@ -0,0 +1,253 @@
+package downloads
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/cookiecloud"
+	"github.com/stkevintan/miko/pkg/events"
+	"github.com/stkevintan/miko/pkg/provider"
+	"github.com/stkevintan/miko/pkg/types"
+	"gorm.io/gorm"
+)
+
+// fakeProvider resolves every URI to the songs of ids, calling onResolve first.
+type fakeProvider struct {
+	ids       []int64
+	onResolve func()
+}
+
+func (p *fakeProvider) GetCookieJar() cookiecloud.CookieJar       { return nil }
+func (p *fakeProvider) User(context.Context) (*types.User, error) { return nil, nil }
+func (p *fakeProvider) Close(context.Context) error               { return nil }
+func (p *fakeProvider) GetMusic(context.Context, []string) ([]*types.Music, error) {
+	if p.onResolve != nil {
+		p.onResolve()
+	}
+	music := make([]*types.Music, 0, len(p.ids))
+	for _, id := range p.ids {
+		music = append(music, &types.Music{Id: id, Name: "song"})
+	}
+	return music, nil
+}
+func (p *fakeProvider) Download(context.Context, []*types.Music, *types.DownloadConfig) (*types.MusicDownloadResults, error) {
+	return nil, errors.New("not implemented")
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.DownloadJob{}, &models.DownloadItem{}, &models.Child{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func newTestQueue(t *testing.T, p *fakeProvider) (*Queue, *gorm.DB) {
+	t.Helper()
+	db := newTestDB(t)
+	providers := func(context.Context, string, string) (provider.Provider, error) { return p, nil }
+	return New(db, providers, events.New(), nil), db
+}
+
+// enqueueResolved queues a job of the songs of p and resolves it.
+func enqueueResolved(t *testing.T, q *Queue) *models.DownloadJob {
+	t.Helper()
+	job, err := q.Enqueue("alice", "fake", []string{"uri"}, types.DownloadConfig{ConflictPolicy: "overwrite"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.resolve(context.Background(), job)
+	return job
+}
+
+func statuses(t *testing.T, db *gorm.DB, jobID uint) (string, []string) {
+	t.Helper()
+	var job models.DownloadJob
+	if err := db.First(&job, jobID).Error; err != nil {
+		t.Fatal(err)
+	}
+	var items []string
+	db.Model(&models.DownloadItem{}).Where("job_id = ?", jobID).Order("position").Pluck("status", &items)
+	return job.Status, items
+}
+
+func TestQueue_Claim(t *testing.T) {
+	q, db := newTestQueue(t, &fakeProvider{ids: []int64{1, 2}})
+	job := enqueueResolved(t, q)
+
+	first, ok := q.claim()
+	if !ok || first.Music.Id != 1 {
+		t.Fatalf("claim() = %d, %v, want the first song", first.Music.Id, ok)
+	}
+	second, ok := q.claim()
+	if !ok || second.Music.Id != 2 {
+		t.Fatalf("claim() = %d, %v, want the second song", second.Music.Id, ok)
+	}
+	if _, ok := q.claim(); ok {
+		t.Fatal("claim() returned an item twice")
+	}
+	if status, items := statuses(t, db, job.ID); status != models.DownloadRunning || items[0] != models.DownloadRunning {
+		t.Errorf("job %s with items %v, want running", status, items)
+	}
+
+	// items waiting for their next attempt aren't claimed
+	q.retry(first, errors.New("boom"))
+	if _, ok := q.claim(); ok {
+		t.Error("claim() returned an item before its next attempt")
+	}
+}
+
+func TestQueue_Pause(t *testing.T) {
+	q, db := newTestQueue(t, &fakeProvider{ids: []int64{1}})
+	job := enqueueResolved(t, q)
+
+	if _, err := q.Pause("bob", job.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Pause() by another user = %v, want not found", err)
+	}
+	if _, err := q.Pause("alice", job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := q.claim(); ok {
+		t.Error("claim() returned an item of a paused job")
+	}
+	if _, err := q.Pause("alice", job.ID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Pause() of a paused job = %v, want ErrInvalidState", err)
+	}
+
+	if _, err := q.Resume("alice", job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := q.claim(); !ok {
+		t.Error("claim() returned nothing after Resume")
+	}
+	if status, _ := statuses(t, db, job.ID); status != models.DownloadRunning {
+		t.Errorf("job %s, want running", status)
+	}
+}
+
+func TestQueue_Cancel(t *testing.T) {
+	q, db := newTestQueue(t, &fakeProvider{ids: []int64{1, 2}})
+	job := enqueueResolved(t, q)
+
+	item, _ := q.claim()
+	if _, err := q.Cancel("alice", job.ID); err != nil {
+		t.Fatal(err)
+	}
+	// the running item is put back by its worker once interrupted
+	q.interrupted(item, models.DownloadCancelled)
+	status, items := statuses(t, db, job.ID)
+	if status != models.DownloadCancelled || items[0] != models.DownloadCancelled || items[1] != models.DownloadCancelled {
+		t.Errorf("job %s with items %v, want everything cancelled", status, items)
+	}
+	if _, ok := q.claim(); ok {
+		t.Error("claim() returned an item of a cancelled job")
+	}
+}
+
+func TestQueue_Retry(t *testing.T) {
+	q, db := newTestQueue(t, &fakeProvider{ids: []int64{1, 2}})
+	job := enqueueResolved(t, q)
+
+	if _, err := q.Retry("alice", job.ID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Retry() of a running job = %v, want ErrInvalidState", err)
+	}
+
+	first, _ := q.claim()
+	second, _ := q.claim()
+	db.Model(&first).Updates(map[string]any{"status": models.DownloadCompleted})
+	db.Model(&second).Updates(map[string]any{"status": models.DownloadFailed, "attempts": maxAttempts})
+	q.updateJob(job.ID)
+	if status, _ := statuses(t, db, job.ID); status != models.DownloadCompleted {
+		t.Fatalf("job %s, want completed", status)
+	}
+
+	retried, err := q.Retry("alice", job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retried.Status != models.DownloadQueued || retried.Items[1].Status != models.DownloadPending || retried.Items[1].Attempts != 0 {
+		t.Errorf("Retry() = job %s with item %s after %d attempts", retried.Status, retried.Items[1].Status, retried.Items[1].Attempts)
+	}
+	if item, ok := q.claim(); !ok || item.ID != second.ID {
+		t.Error("claim() didn't return the retried item")
+	}
+}
+
+func TestQueue_RetryOutOfAttempts(t *testing.T) {
+	q, db := newTestQueue(t, &fakeProvider{ids: []int64{1}})
+	job := enqueueResolved(t, q)
+
+	item, _ := q.claim()
+	item.Attempts = maxAttempts - 1
+	q.retry(item, errors.New("boom"))
+	q.updateJob(job.ID)
+	if status, items := statuses(t, db, job.ID); status != models.DownloadFailed || items[0] != models.DownloadFailed {
+		t.Errorf("job %s with items %v, want failed", status, items)
+	}
+}
+
+func TestQueue_Requeue(t *testing.T) {
+	q, db := newTestQueue(t, &fakeProvider{ids: []int64{1}})
+	job := enqueueResolved(t, q)
+
+	if _, ok := q.claim(); !ok {
+		t.Fatal("claim() returned nothing")
+	}
+	// a restart finds the item running
+	q.requeue()
+	if _, items := statuses(t, db, job.ID); items[0] != models.DownloadPending {
+		t.Errorf("item %s after requeue, want pending", items[0])
+	}
+	if _, ok := q.claim(); !ok {
+		t.Error("claim() didn't return the requeued item")
+	}
+}
+
+func TestQueue_PauseWhileResolving(t *testing.T) {
+	p := &fakeProvider{ids: []int64{1}}
+	q, db := newTestQueue(t, p)
+	job, err := q.Enqueue("alice", "fake", []string{"uri"}, types.DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.onResolve = func() {
+		if _, err := q.Pause("alice", job.ID); err != nil {
+			t.Error(err)
+		}
+	}
+	q.resolve(context.Background(), job)
+
+	if status, items := statuses(t, db, job.ID); status != models.DownloadPaused || len(items) != 1 {
+		t.Errorf("job %s with items %v, want paused with its item", status, items)
+	}
+	if _, ok := q.claim(); ok {
+		t.Error("claim() returned an item of a job paused while resolving")
+	}
+}
+
+func TestQueue_CancelWhileResolving(t *testing.T) {
+	p := &fakeProvider{ids: []int64{1}}
+	q, db := newTestQueue(t, p)
+	job, err := q.Enqueue("alice", "fake", []string{"uri"}, types.DownloadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.onResolve = func() {
+		if _, err := q.Cancel("alice", job.ID); err != nil {
+			t.Error(err)
+		}
+	}
+	q.resolve(context.Background(), job)
+
+	if status, items := statuses(t, db, job.ID); status != models.DownloadCancelled || items[0] != models.DownloadCancelled {
+		t.Errorf("job %s with items %v, want cancelled", status, items)
+	}
+}
@@ -0,0 +1,118 @@
+package downloads
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/stkevintan/miko/config"
+	"github.com/stkevintan/miko/models"
+)
+
+// ErrOutputNotAllowed is returned for an output outside of the directories the user may
+// download to.
+var ErrOutputNotAllowed = errors.New("output is outside of the permitted download directories")
+
+// Roots returns the directories user may download to, the default one first. Users with the
+// upload role may download to the music folders they can access, the configured folder
+// included, users with the download role to the extra roots of cfg, and admins to both.
+func Roots(cfg *config.DownloadConfig, user *models.User, folders []models.MusicFolder) []string {
+	var roots []string
+	add := func(dir string) {
+		if dir == "" {
+			return
+		}
+		if abs, err := filepath.Abs(dir); err == nil && !slices.Contains(roots, abs) {
+			roots = append(roots, abs)
+		}
+	}
+	if user.AdminRole || user.UploadRole {
+		if len(user.MusicFolders) > 0 {
+			folders = user.MusicFolders
+		}
+		library := make([]string, 0, len(folders))
+		for _, folder := range folders {
+			abs, err := filepath.Abs(filepath.FromSlash(folder.Path))
+			if err == nil {
+				library = append(library, abs)
+			}
+		}
+		// the configured folder is the default one, when the user can access it
+		if folder, err := filepath.Abs(cfg.Folder); err == nil && cfg.Folder != "" &&
+			(user.AdminRole || len(user.MusicFolders) == 0 || slices.Contains(library, folder)) {
+			add(folder)
+		}
+		for _, dir := range library {
+			add(dir)
+		}
+	}
+	if user.AdminRole || user.DownloadRole {
+		for _, dir := range cfg.Roots {
+			add(dir)
+		}
+	}
+	return roots
+}
+
+// ResolveOutput resolves output to an absolute path inside one of roots after following its
+// symlinks. An empty output is the first root, a relative one is relative to it. The path is
+// returned under the root as it is spelled in roots, the way music folders are scanned, even
+// when the root is reached through a symlink.
+func ResolveOutput(output string, roots []string) (string, error) {
+	if len(roots) == 0 {
+		return "", ErrOutputNotAllowed
+	}
+	if output == "" {
+		output = roots[0]
+	} else if !filepath.IsAbs(output) {
+		output = filepath.Join(roots[0], output)
+	}
+	resolved, err := evalSymlinks(filepath.Clean(output))
+	if err != nil {
+		return "", err
+	}
+	for _, root := range roots {
+		target, err := evalSymlinks(root)
+		if err != nil {
+			continue
+		}
+		if within(target, resolved) {
+			rel, _ := filepath.Rel(target, resolved)
+			return filepath.Join(root, rel), nil
+		}
+	}
+	return "", ErrOutputNotAllowed
+}
+
+// evalSymlinks resolves the symlinks of path, the part of it that doesn't exist yet is kept
+// as is. A dangling symlink is an error as the directories created through it could be anywhere.
+func evalSymlinks(path string) (string, error) {
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		if _, lerr := os.Lstat(path); lerr == nil {
+			return "", fmt.Errorf("%s: dangling symlink", path)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		missing = append([]string{filepath.Base(path)}, missing...)
+		path = parent
+	}
+}
+
+// within reports whether path is root or inside it.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
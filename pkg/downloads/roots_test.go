@@ -0,0 +1,107 @@
+package downloads
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stkevintan/miko/config"
+	"github.com/stkevintan/miko/models"
+)
+
+func TestRoots(t *testing.T) {
+	cfg := &config.DownloadConfig{Folder: "/music/a", Roots: []string{"/incoming"}}
+	folders := []models.MusicFolder{{ID: 1, Path: "/music/a"}, {ID: 2, Path: "/music/b"}}
+
+	tests := []struct {
+		name string
+		user models.User
+		want []string
+	}{
+		{"admin", models.User{SubsonicSettings: models.SubsonicSettings{AdminRole: true}}, []string{"/music/a", "/music/b", "/incoming"}},
+		{"upload", models.User{SubsonicSettings: models.SubsonicSettings{UploadRole: true}}, []string{"/music/a", "/music/b"}},
+		{"upload to assigned folders", models.User{SubsonicSettings: models.SubsonicSettings{UploadRole: true, MusicFolders: folders[1:]}}, []string{"/music/b"}},
+		{"download", models.User{SubsonicSettings: models.SubsonicSettings{DownloadRole: true}}, []string{"/incoming"}},
+		{"none", models.User{}, nil},
+	}
+	for _, tt := range tests {
+		if got := Roots(cfg, &tt.user, folders); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Roots() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveOutput(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "music")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{root, outside} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "missing"), filepath.Join(root, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	roots := []string{root}
+
+	for output, want := range map[string]string{
+		"":                       root,
+		"new/dir":                filepath.Join(root, "new", "dir"),
+		filepath.Join(root, "x"): filepath.Join(root, "x"),
+		filepath.Join(outside, "..", "music", "y"): filepath.Join(root, "y"),
+	} {
+		got, err := ResolveOutput(output, roots)
+		if err != nil || got != want {
+			t.Errorf("ResolveOutput(%q) = %q, %v, want %q", output, got, err, want)
+		}
+	}
+
+	for _, output := range []string{"/etc", "../outside", "escape/x", filepath.Join(root, "escape")} {
+		if _, err := ResolveOutput(output, roots); !errors.Is(err, ErrOutputNotAllowed) {
+			t.Errorf("ResolveOutput(%q) = %v, want ErrOutputNotAllowed", output, err)
+		}
+	}
+	if _, err := ResolveOutput("dangling/x", roots); err == nil {
+		t.Error("ResolveOutput() through a dangling symlink succeeded")
+	}
+	if _, err := ResolveOutput("", nil); !errors.Is(err, ErrOutputNotAllowed) {
+		t.Errorf("ResolveOutput() without roots = %v, want ErrOutputNotAllowed", err)
+	}
+}
+
+func TestResolveOutput_SymlinkedRoot(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dir, "data")
+	if err := os.Mkdir(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// the music folder is configured through a symlink
+	root := filepath.Join(dir, "music")
+	if err := os.Symlink(target, root); err != nil {
+		t.Fatal(err)
+	}
+
+	for output, want := range map[string]string{
+		"":                         root,
+		"new/dir":                  filepath.Join(root, "new", "dir"),
+		filepath.Join(root, "x"):   filepath.Join(root, "x"),
+		filepath.Join(target, "y"): filepath.Join(root, "y"),
+	} {
+		got, err := ResolveOutput(output, []string{root})
+		if err != nil || got != want {
+			t.Errorf("ResolveOutput(%q) = %q, %v, want %q", output, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package events
+
+import "sync"
+
+// subscriberBuffer is the number of events a subscriber can lag behind before it misses some
+const subscriberBuffer = 64
+
+// Event is something that happened to the resources of a user. Type names the kind of event,
+// such as "progress", and Data is its JSON-serializable payload.
+type Event struct {
+	Username string `json:"-"`
+	Type     string `json:"type"`
+	Data     any    `json:"data"`
+}
+
+// Bus delivers published events to the subscribers of their user. Publishing never blocks:
+// a subscriber that doesn't keep up misses events.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[chan Event]struct{}
+}
+
+func New() *Bus {
+	return &Bus{subscribers: make(map[string]map[chan Event]struct{})}
+}
+
+// Subscribe returns the events of the user and a function to stop receiving them.
+func (b *Bus) Subscribe(username string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	if b.subscribers[username] == nil {
+		b.subscribers[username] = make(map[chan Event]struct{})
+	}
+	b.subscribers[username][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[username], ch)
+			if len(b.subscribers[username]) == 0 {
+				delete(b.subscribers, username)
+			}
+			b.mu.Unlock()
+		})
+	}
+}
+
+// Publish sends the event to the subscribers of its user.
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subscribers[e.Username] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package events
+
+import "testing"
+
+func TestBus(t *testing.T) {
+	b := New()
+	alice, stop := b.Subscribe("alice")
+	bob, stopBob := b.Subscribe("bob")
+	defer stopBob()
+
+	b.Publish(Event{Username: "alice", Type: "progress", Data: 1})
+	select {
+	case e := <-alice:
+		if e.Type != "progress" || e.Data != 1 {
+			t.Errorf("got %+v", e)
+		}
+	default:
+		t.Fatal("alice didn't receive her event")
+	}
+	select {
+	case e := <-bob:
+		t.Fatalf("bob received %+v", e)
+	default:
+	}
+
+	// a subscriber that doesn't read doesn't block publishing
+	for i := range subscriberBuffer * 2 {
+		b.Publish(Event{Username: "bob", Data: i})
+	}
+	if len(bob) != subscriberBuffer {
+		t.Errorf("bob has %d events, want %d", len(bob), subscriberBuffer)
+	}
+
+	stop()
+	stop()
+	b.Publish(Event{Username: "alice"})
+	if len(alice) != 0 {
+		t.Error("alice received an event after unsubscribing")
+	}
+}
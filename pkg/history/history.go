@@ -0,0 +1,118 @@
+package history
+
+import (
+	"time"
+
+	"github.com/stkevintan/miko/models"
+	"gorm.io/gorm"
+)
+
+// fullPlayRatio is the part of a song that must be listened to for a play to count as full.
+const fullPlayRatio = 0.9
+
+type Manager struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) *Manager {
+	return &Manager{db: db}
+}
+
+// Play is a scrobble submission. Listened is how long the song was played, zero when the client
+// didn't tell, in which case the song is assumed to be played to the end.
+type Play struct {
+	SongID   string
+	PlayedAt time.Time
+	Listened time.Duration
+}
+
+// Record adds plays of username to the history and updates the play count and last played time
+// of the songs. Plays of unknown songs are ignored.
+func (m *Manager) Record(username, client string, plays []Play) error {
+	if len(plays) == 0 {
+		return nil
+	}
+	ids := make([]string, len(plays))
+	for i, p := range plays {
+		ids[i] = p.SongID
+	}
+	var songs []models.Child
+	if err := m.db.Model(&models.Child{}).Select("id, duration").Where("id IN ?", ids).Find(&songs).Error; err != nil {
+		return err
+	}
+	durations := make(map[string]int, len(songs))
+	for _, song := range songs {
+		durations[song.ID] = song.Duration
+	}
+
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		for _, p := range plays {
+			duration, ok := durations[p.SongID]
+			if !ok {
+				continue
+			}
+			listened, full := duration, true
+			if p.Listened > 0 {
+				listened = min(int(p.Listened/time.Second), duration)
+				full = duration == 0 || float64(listened) >= float64(duration)*fullPlayRatio
+			}
+			record := models.PlayRecord{
+				Username: username,
+				SongID:   p.SongID,
+				PlayedAt: p.PlayedAt.UTC(),
+				Client:   client,
+				Duration: listened,
+				Full:     full,
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Child{}).Where("id = ?", p.SongID).Updates(map[string]interface{}{
+				"play_count":  gorm.Expr("play_count + 1"),
+				"last_played": p.PlayedAt,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Window limits history queries to plays of a user between From and To, a zero time leaves
+// that end of the window open.
+type Window struct {
+	Username string
+	From     time.Time
+	To       time.Time
+}
+
+func (w Window) scope(db *gorm.DB) *gorm.DB {
+	db = db.Where("play_records.username = ?", w.Username)
+	if !w.From.IsZero() {
+		db = db.Where("play_records.played_at >= ?", w.From.UTC())
+	}
+	if !w.To.IsZero() {
+		db = db.Where("play_records.played_at < ?", w.To.UTC())
+	}
+	return db
+}
+
+// HistoryEntry is a play with the song that was played.
+type HistoryEntry struct {
+	models.PlayRecord
+	Song *models.Child `gorm:"foreignKey:SongID" json:"song,omitempty"`
+}
+
+func (HistoryEntry) TableName() string {
+	return "play_records"
+}
+
+// History returns the plays in the window, most recent first.
+func (m *Manager) History(w Window, limit, offset int) ([]HistoryEntry, error) {
+	entries := []HistoryEntry{}
+	err := m.db.Scopes(w.scope).Preload("Song").
+		Order("play_records.played_at DESC, play_records.id DESC").
+		Limit(limit).Offset(offset).
+		Find(&entries).Error
+	return entries, err
+}
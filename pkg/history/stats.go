@@ -0,0 +1,163 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/stkevintan/miko/models"
+)
+
+// Kinds of top lists
+const (
+	KindSongs   = "songs"
+	KindArtists = "artists"
+	KindAlbums  = "albums"
+	KindGenres  = "genres"
+)
+
+// TopItem is an artist, album or genre ranked by its plays. ListenTime is in seconds.
+type TopItem struct {
+	ID         string `json:"id,omitempty"`
+	Name       string `json:"name"`
+	Plays      int    `json:"plays"`
+	ListenTime int    `json:"listenTime"`
+}
+
+// TopSong is a song ranked by its plays. ListenTime is in seconds.
+type TopSong struct {
+	models.Child
+	Plays      int `json:"plays"`
+	ListenTime int `json:"listenTime"`
+}
+
+// TopSongs returns the most played songs in the window.
+func (m *Manager) TopSongs(w Window, limit int) ([]TopSong, error) {
+	songs := []TopSong{}
+	err := m.db.Table("play_records").Scopes(w.scope).
+		Select("children.*, COUNT(*) AS plays, SUM(play_records.duration) AS listen_time").
+		Joins("JOIN children ON children.id = play_records.song_id").
+		Group("children.id").
+		Order("plays DESC, listen_time DESC").
+		Limit(limit).
+		Scan(&songs).Error
+	return songs, err
+}
+
+// Top returns the most played artists, albums or genres in the window.
+func (m *Manager) Top(kind string, w Window, limit int) ([]TopItem, error) {
+	db := m.db.Table("play_records").Scopes(w.scope).
+		Joins("JOIN children ON children.id = play_records.song_id")
+	switch kind {
+	case KindArtists:
+		db = db.Select("children.artist_id AS id, MAX(children.artist) AS name, COUNT(*) AS plays, SUM(play_records.duration) AS listen_time").
+			Where("children.artist_id != ''").
+			Group("children.artist_id")
+	case KindAlbums:
+		db = db.Select("children.album_id AS id, MAX(children.album) AS name, COUNT(*) AS plays, SUM(play_records.duration) AS listen_time").
+			Where("children.album_id != ''").
+			Group("children.album_id")
+	case KindGenres:
+		db = db.Select("song_genres.genre_name AS name, COUNT(*) AS plays, SUM(play_records.duration) AS listen_time").
+			Joins("JOIN song_genres ON song_genres.child_id = children.id").
+			Group("song_genres.genre_name")
+	default:
+		return nil, fmt.Errorf("unknown top list: %s", kind)
+	}
+
+	items := []TopItem{}
+	err := db.Order("plays DESC, listen_time DESC").Limit(limit).Scan(&items).Error
+	return items, err
+}
+
+// HeatMap is the listening time in seconds per weekday, Sunday first, and hour of the day.
+type HeatMap [7][24]int
+
+// HeatMap returns when the user listens to music in the window, in the time zone loc.
+func (m *Manager) HeatMap(w Window, loc *time.Location) (*HeatMap, error) {
+	plays, err := m.plays(w)
+	if err != nil {
+		return nil, err
+	}
+	return heatMap(plays, loc), nil
+}
+
+func heatMap(plays []models.PlayRecord, loc *time.Location) *HeatMap {
+	var hm HeatMap
+	for _, p := range plays {
+		t := p.PlayedAt.In(loc)
+		hm[t.Weekday()][t.Hour()] += p.Duration
+	}
+	return &hm
+}
+
+// Streak is a run of consecutive days with plays, dates are formatted as YYYY-MM-DD.
+type Streak struct {
+	Days  int    `json:"days"`
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+// Streaks are the streak that is still going, which ends today or yesterday, and the longest
+// streak of a user.
+type Streaks struct {
+	Current Streak `json:"current"`
+	Longest Streak `json:"longest"`
+}
+
+// Streaks returns the listening streaks in the window, days are counted in the time zone loc.
+func (m *Manager) Streaks(w Window, loc *time.Location, now time.Time) (*Streaks, error) {
+	plays, err := m.plays(w)
+	if err != nil {
+		return nil, err
+	}
+	times := make([]time.Time, len(plays))
+	for i, p := range plays {
+		times[i] = p.PlayedAt
+	}
+	return streaks(times, loc, now), nil
+}
+
+func streaks(times []time.Time, loc *time.Location, now time.Time) *Streaks {
+	seen := make(map[time.Time]bool)
+	var days []time.Time
+	for _, t := range times {
+		y, m, d := t.In(loc).Date()
+		day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	var result Streaks
+	var run Streak
+	for i, day := range days {
+		if i > 0 && days[i-1].AddDate(0, 0, 1).Equal(day) {
+			run.Days++
+		} else {
+			run = Streak{Days: 1, Start: day.Format(time.DateOnly)}
+		}
+		run.End = day.Format(time.DateOnly)
+		if run.Days > result.Longest.Days {
+			result.Longest = run
+		}
+	}
+
+	y, m, d := now.In(loc).Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	if run.End == today.Format(time.DateOnly) || run.End == today.AddDate(0, 0, -1).Format(time.DateOnly) {
+		result.Current = run
+	}
+	return &result
+}
+
+func (m *Manager) plays(w Window) ([]models.PlayRecord, error) {
+	var plays []models.PlayRecord
+	err := m.db.Model(&models.PlayRecord{}).Scopes(w.scope).
+		Select("played_at, duration").
+		Order("played_at").
+		Find(&plays).Error
+	return plays, err
+}
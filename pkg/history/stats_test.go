@@ -0,0 +1,70 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stkevintan/miko/models"
+)
+
+func TestStreaks(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.Parse(time.DateTime, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	times := []time.Time{
+		day("2024-03-01 10:00:00"),
+		day("2024-03-02 23:30:00"),
+		day("2024-03-02 08:00:00"),
+		day("2024-03-03 12:00:00"),
+		day("2024-03-10 12:00:00"),
+		day("2024-03-11 12:00:00"),
+	}
+
+	got := streaks(times, time.UTC, day("2024-03-12 09:00:00"))
+	want := Streaks{
+		Current: Streak{Days: 2, Start: "2024-03-10", End: "2024-03-11"},
+		Longest: Streak{Days: 3, Start: "2024-03-01", End: "2024-03-03"},
+	}
+	if *got != want {
+		t.Errorf("streaks = %+v, want %+v", *got, want)
+	}
+
+	got = streaks(times, time.UTC, day("2024-03-13 09:00:00"))
+	if got.Current.Days != 0 {
+		t.Errorf("current streak = %+v, want none after a day without plays", got.Current)
+	}
+
+	// in UTC+2 the late play of March 2nd happens on March 3rd
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	got = streaks(times[:3], loc, day("2024-03-03 12:00:00"))
+	want = Streaks{
+		Current: Streak{Days: 3, Start: "2024-03-01", End: "2024-03-03"},
+		Longest: Streak{Days: 3, Start: "2024-03-01", End: "2024-03-03"},
+	}
+	if *got != want {
+		t.Errorf("streaks in UTC+2 = %+v, want %+v", *got, want)
+	}
+}
+
+func TestHeatMap(t *testing.T) {
+	// Friday, March 1st 2024
+	playedAt := time.Date(2024, 3, 1, 22, 15, 0, 0, time.UTC)
+	plays := []models.PlayRecord{
+		{PlayedAt: playedAt, Duration: 200},
+		{PlayedAt: playedAt.Add(10 * time.Minute), Duration: 100},
+	}
+
+	hm := heatMap(plays, time.UTC)
+	if got := hm[time.Friday][22]; got != 300 {
+		t.Errorf("Friday 22h = %d, want 300", got)
+	}
+
+	hm = heatMap(plays, time.FixedZone("UTC+3", 3*60*60))
+	if got := hm[time.Saturday][1]; got != 300 {
+		t.Errorf("Saturday 1h in UTC+3 = %d, want 300", got)
+	}
+}
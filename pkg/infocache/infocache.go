@@ -0,0 +1,49 @@
+package infocache
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// TTL is how long fetched info is cached
+	TTL = 30 * 24 * time.Hour
+	// RetryTTL is how long incomplete info is cached, so that it is fetched again sooner when
+	// it wasn't found or a request failed
+	RetryTTL = 24 * time.Hour
+	// FetchTimeout bounds the time spent fetching info
+	FetchTimeout = 15 * time.Second
+)
+
+// Fetch fetches a fresh record and reports whether it is complete. It returns a record along
+// with its error too, holding what is known.
+type Fetch[T any] func(ctx context.Context) (record *T, complete bool, err error)
+
+// Get returns cached when it is set and hasn't expired, expiresAt giving the expiry time of a
+// record. Otherwise a record is fetched and saved in place of the cached one, for TTL when it
+// is complete and RetryTTL otherwise. When fetching fails, the stale cached record is returned
+// rather than nothing.
+func Get[T any](ctx context.Context, db *gorm.DB, cached *T, expiresAt func(*T) *time.Time, fetch Fetch[T]) (*T, error) {
+	if cached != nil && time.Now().Before(*expiresAt(cached)) {
+		return cached, nil
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, FetchTimeout)
+	defer cancel()
+	record, complete, err := fetch(fetchCtx)
+	if err != nil && cached != nil {
+		return cached, nil
+	}
+	ttl := TTL
+	if err != nil || !complete {
+		ttl = RetryTTL
+	}
+	*expiresAt(record) = time.Now().Add(ttl)
+	if err := db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(record).Error; err != nil {
+		return nil, err
+	}
+	return record, nil
+}
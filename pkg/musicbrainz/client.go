@@ -11,6 +11,10 @@ import (
 
 const baseURL = "https://musicbrainz.org/ws/2"
 
+// MusicBrainz allows 1 request per second for non-authenticated users, the limit is shared by
+// every client of the process.
+var limiter = time.Tick(1100 * time.Millisecond)
+
 type Client struct {
 	restyClient *resty.Client
 	limiter     <-chan time.Time
@@ -32,8 +36,7 @@ func NewClient() *Client {
 
 	return &Client{
 		restyClient: client,
-		// MusicBrainz allows 1 request per second for non-authenticated users
-		limiter: time.Tick(1100 * time.Millisecond),
+		limiter:     limiter,
 	}
 }
 
@@ -128,3 +131,127 @@ func (c *Client) FetchCoverArt(ctx context.Context, releaseID string) ([]byte, e
 
 	return resp.Body(), nil
 }
+
+// SearchArtist returns the artist best matching name.
+func (c *Client) SearchArtist(ctx context.Context, name string) (*Artist, error) {
+	select {
+	case <-c.limiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	var sr ArtistSearchResponse
+	resp, err := c.restyClient.R().
+		SetContext(ctx).
+		SetQueryParam("query", fmt.Sprintf("artist:\"%s\"", strings.ReplaceAll(name, "\"", "\\\""))).
+		SetQueryParam("fmt", "json").
+		SetResult(&sr).
+		Get("/artist/")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !resp.IsSuccess() {
+		return nil, fmt.Errorf("musicbrainz api error: %s", resp.Status())
+	}
+
+	for i := range sr.Artists {
+		if strings.EqualFold(sr.Artists[i].Name, name) {
+			return &sr.Artists[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no results found")
+}
+
+// GetArtist returns the artist with its genres and links.
+func (c *Client) GetArtist(ctx context.Context, id string) (*Artist, error) {
+	select {
+	case <-c.limiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	var a Artist
+	resp, err := c.restyClient.R().
+		SetContext(ctx).
+		SetQueryParam("inc", "url-rels+genres").
+		SetQueryParam("fmt", "json").
+		SetResult(&a).
+		Get("/artist/" + id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !resp.IsSuccess() {
+		return nil, fmt.Errorf("musicbrainz api error: %s", resp.Status())
+	}
+
+	return &a, nil
+}
+
+// SearchRelease returns the release of the artist titled album.
+func (c *Client) SearchRelease(ctx context.Context, artist, album string) (*Release, error) {
+	select {
+	case <-c.limiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	escape := func(val string) string { return strings.ReplaceAll(val, "\"", "\\\"") }
+	query := fmt.Sprintf("release:\"%s\"", escape(album))
+	if artist != "" {
+		query += fmt.Sprintf(" AND artist:\"%s\"", escape(artist))
+	}
+
+	var sr ReleaseSearchResponse
+	resp, err := c.restyClient.R().
+		SetContext(ctx).
+		SetQueryParam("query", query).
+		SetQueryParam("fmt", "json").
+		SetResult(&sr).
+		Get("/release/")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !resp.IsSuccess() {
+		return nil, fmt.Errorf("musicbrainz api error: %s", resp.Status())
+	}
+
+	for i := range sr.Releases {
+		if strings.EqualFold(sr.Releases[i].Title, album) {
+			return &sr.Releases[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no results found")
+}
+
+// GetRelease returns the release with its annotation.
+func (c *Client) GetRelease(ctx context.Context, id string) (*Release, error) {
+	select {
+	case <-c.limiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	var r Release
+	resp, err := c.restyClient.R().
+		SetContext(ctx).
+		SetQueryParam("inc", "annotation+release-groups").
+		SetQueryParam("fmt", "json").
+		SetResult(&r).
+		Get("/release/" + id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !resp.IsSuccess() {
+		return nil, fmt.Errorf("musicbrainz api error: %s", resp.Status())
+	}
+
+	return &r, nil
+}
@@ -31,6 +31,16 @@ type Release struct {
 	Media        []Media         `json:"media"`
 	ReleaseGroup ReleaseGroup    `json:"release-group"`
 	ArtistCredit []IDNamedEntity `json:"artist-credit"`
+	Annotation   string          `json:"annotation"`
+	CoverArt     CoverArt        `json:"cover-art-archive"`
+}
+
+type CoverArt struct {
+	Front bool `json:"front"`
+}
+
+type ReleaseSearchResponse struct {
+	Releases []Release `json:"releases"`
 }
 
 type WorkRelation struct {
@@ -64,3 +74,22 @@ type Recording struct {
 type SearchResponse struct {
 	Recordings []Recording `json:"recordings"`
 }
+
+type URLRelation struct {
+	Type string `json:"type"`
+	URL  struct {
+		Resource string `json:"resource"`
+	} `json:"url"`
+}
+
+type Artist struct {
+	ID             string        `json:"id"`
+	Name           string        `json:"name"`
+	Disambiguation string        `json:"disambiguation"`
+	Genres         []NamedEntity `json:"genres"`
+	Relations      []URLRelation `json:"relations"`
+}
+
+type ArtistSearchResponse struct {
+	Artists []Artist `json:"artists"`
+}
@@ -0,0 +1,338 @@
+package netease
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/chaunsin/netease-cloud-music/api"
+	nmTypes "github.com/chaunsin/netease-cloud-music/api/types"
+	"github.com/chaunsin/netease-cloud-music/api/weapi"
+	"github.com/stkevintan/miko/pkg/log"
+	"github.com/stkevintan/miko/pkg/types"
+)
+
+// searchTypeArtist is the type of the search API returning artists
+const searchTypeArtist = 100
+
+type searchReq struct {
+	S      string `json:"s"`
+	Type   int    `json:"type"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type searchArtistResp struct {
+	Code   int64 `json:"code"`
+	Result struct {
+		Artists []nmTypes.Artist `json:"artists"`
+	} `json:"result"`
+}
+
+// SearchArtist returns the artist named name, or the best match of the search when no artist
+// has exactly that name.
+func (d *NMProvider) SearchArtist(ctx context.Context, name string) (*types.Artist, error) {
+	var reply searchArtistResp
+	req := &searchReq{S: name, Type: searchTypeArtist, Limit: 10}
+	if _, err := d.cli.Request(ctx, "https://music.163.com/weapi/search/get", req, &reply, api.NewOptions()); err != nil {
+		return nil, fmt.Errorf("search artist: %w", err)
+	}
+	if reply.Code != 200 {
+		return nil, fmt.Errorf("search artist API error: %+v", reply)
+	}
+	if len(reply.Result.Artists) == 0 {
+		return nil, fmt.Errorf("artist %q not found", name)
+	}
+	match := reply.Result.Artists[0]
+	for _, a := range reply.Result.Artists {
+		if strings.EqualFold(a.Name, name) {
+			match = a
+			break
+		}
+	}
+	return &types.Artist{Id: match.Id, Name: match.Name}, nil
+}
+
+// ArtistTopSongs returns the most popular songs of the artist named name.
+func (d *NMProvider) ArtistTopSongs(ctx context.Context, name string, count int) ([]*types.Music, error) {
+	artist, err := d.SearchArtist(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.topSongs(ctx, artist.Id, count)
+	if err != nil {
+		return nil, err
+	}
+
+	music := make([]*types.Music, 0, len(resp.Songs))
+	for _, v := range resp.Songs {
+		artists := make([]types.Artist, 0, len(v.Ar))
+		for _, a := range v.Ar {
+			artists = append(artists, types.Artist{
+				Id:   a.Id,
+				Name: a.Name,
+			})
+		}
+		music = append(music, &types.Music{
+			Id:     v.Id,
+			Name:   v.Name,
+			Artist: artists,
+			Album: types.Album{
+				Id:     v.Al.Id,
+				Name:   v.Al.Name,
+				PicUrl: v.Al.PicUrl,
+			},
+			Time:        v.Dt,
+			TrackNumber: strconv.FormatInt(v.No, 10),
+			Disc:        v.Cd,
+		})
+	}
+	return music, nil
+}
+
+// topSongs returns up to count of the most popular songs of the artist.
+func (d *NMProvider) topSongs(ctx context.Context, id int64, count int) (*weapi.ArtistSongsResp, error) {
+	resp, err := d.request.ArtistSongs(ctx, &weapi.ArtistSongsReq{
+		Id:           id,
+		PrivateCloud: "true",
+		WorkType:     1,
+		Order:        "hot",
+		Limit:        int64(count),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ArtistSongs: %w", err)
+	}
+	if resp.Code != 200 {
+		return nil, fmt.Errorf("ArtistSongs API error: %+v", resp)
+	}
+	return resp, nil
+}
+
+// Modes of the download of an artist
+const (
+	artistModeTop    = "top"
+	artistModeAlbums = "albums"
+)
+
+// defaultTopSongs is the number of top songs downloaded when the artist URI has no limit
+const defaultTopSongs = 50
+
+// artistQuery selects the songs of an artist from the options of its URI, such as
+// https://music.163.com/#/artist?id=1&mode=albums&type=album,ep&year=2015-2020.
+// mode=top, the default, takes the limit most popular songs. mode=albums takes the songs of the
+// albums of the artist, of every type and year unless type lists some of album, ep, single,
+// compilation and live, or year is a year or a range of them.
+type artistQuery struct {
+	id       int64
+	mode     string
+	limit    int
+	types    []string
+	from, to int
+}
+
+func parseArtistQuery(uri string, id int64) (artistQuery, error) {
+	query := artistQuery{id: id, mode: artistModeTop, limit: defaultTopSongs}
+	var values url.Values
+	if i := strings.LastIndexByte(uri, '?'); i >= 0 {
+		var err error
+		if values, err = url.ParseQuery(uri[i+1:]); err != nil {
+			return query, fmt.Errorf("invalid artist options %q: %w", uri, err)
+		}
+	}
+
+	if mode := values.Get("mode"); mode != "" {
+		if mode != artistModeTop && mode != artistModeAlbums {
+			return query, fmt.Errorf("unknown artist mode %q, expected top or albums", mode)
+		}
+		query.mode = mode
+	}
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return query, fmt.Errorf("invalid limit %q", limit)
+		}
+		query.limit = n
+	}
+	if kinds := values.Get("type"); kinds != "" {
+		for _, kind := range strings.Split(kinds, ",") {
+			kind = strings.ToLower(strings.TrimSpace(kind))
+			switch kind {
+			case "album", "ep", "single", "compilation", "live":
+				query.types = append(query.types, kind)
+			default:
+				return query, fmt.Errorf("unknown album type %q", kind)
+			}
+		}
+	}
+	if year := values.Get("year"); year != "" {
+		from, to, isRange := strings.Cut(year, "-")
+		var err1, err2 error
+		query.from, err1 = strconv.Atoi(strings.TrimSpace(from))
+		query.to = query.from
+		if isRange {
+			query.to, err2 = strconv.Atoi(strings.TrimSpace(to))
+		}
+		if err1 != nil || err2 != nil || query.from > query.to {
+			return query, fmt.Errorf("invalid year %q", year)
+		}
+	}
+	return query, nil
+}
+
+type artistAlbumsReq struct {
+	Offset int  `json:"offset"`
+	Limit  int  `json:"limit"`
+	Total  bool `json:"total"`
+}
+
+type artistAlbum struct {
+	Id          int64  `json:"id"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	SubType     string `json:"subType"`
+	PublishTime int64  `json:"publishTime"`
+}
+
+type artistAlbumsResp struct {
+	Code      int64         `json:"code"`
+	More      bool          `json:"more"`
+	HotAlbums []artistAlbum `json:"hotAlbums"`
+}
+
+// types returns the album types of query the album is, NetEase doesn't tell EPs from singles.
+func (a *artistAlbum) types() []string {
+	switch {
+	case a.SubType == "现场版":
+		return []string{"live"}
+	case a.Type == "合集" || a.SubType == "合集":
+		return []string{"compilation"}
+	case strings.Contains(a.Type, "EP"), strings.Contains(a.Type, "Single"):
+		return []string{"ep", "single"}
+	}
+	return []string{"album"}
+}
+
+// includes reports whether the album is of the types and years selected by the query.
+func (q *artistQuery) includes(album *artistAlbum) bool {
+	if len(q.types) > 0 && !slices.ContainsFunc(album.types(), func(t string) bool {
+		return slices.Contains(q.types, t)
+	}) {
+		return false
+	}
+	year := publishYear(album.PublishTime)
+	return q.from == 0 || (year >= q.from && year <= q.to)
+}
+
+// artistAlbums returns every album of the artist.
+func (d *NMProvider) artistAlbums(ctx context.Context, id int64) ([]artistAlbum, error) {
+	const pageSize = 100
+	var albums []artistAlbum
+	url := fmt.Sprintf("https://music.163.com/weapi/artist/albums/%d", id)
+	for offset := 0; ; offset += pageSize {
+		var reply artistAlbumsResp
+		req := &artistAlbumsReq{Offset: offset, Limit: pageSize, Total: true}
+		if _, err := d.cli.Request(ctx, url, req, &reply, api.NewOptions()); err != nil {
+			return nil, fmt.Errorf("artist albums: %w", err)
+		}
+		if reply.Code != 200 {
+			return nil, fmt.Errorf("artist albums API error: %+v", reply)
+		}
+		albums = append(albums, reply.HotAlbums...)
+		if !reply.More || len(reply.HotAlbums) == 0 {
+			return albums, nil
+		}
+	}
+}
+
+// artistSongs returns the songs of the artist selected by query that are not in set yet, and
+// adds them to it.
+func (d *NMProvider) artistSongs(ctx context.Context, query artistQuery, set map[int64]struct{}) ([]*types.Music, error) {
+	if query.mode == artistModeTop {
+		resp, err := d.topSongs(ctx, query.id, query.limit)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]int64, 0, len(resp.Songs))
+		for _, v := range resp.Songs {
+			ids = append(ids, v.Id)
+		}
+		return d.songDetails(ctx, ids, set)
+	}
+
+	albums, err := d.artistAlbums(ctx, query.id)
+	if err != nil {
+		return nil, err
+	}
+	var music []*types.Music
+	for _, album := range albums {
+		if !query.includes(&album) {
+			continue
+		}
+		songs, err := d.albumSongs(ctx, album.Id, set)
+		if err != nil {
+			return nil, err
+		}
+		music = append(music, songs...)
+	}
+	return music, nil
+}
+
+type artistDetailResp struct {
+	Code   int64 `json:"code"`
+	Artist struct {
+		Id        int64  `json:"id"`
+		Name      string `json:"name"`
+		BriefDesc string `json:"briefDesc"`
+		PicUrl    string `json:"picUrl"`
+		Img1v1Url string `json:"img1v1Url"`
+	} `json:"artist"`
+}
+
+type similarArtistsResp struct {
+	Code    int64 `json:"code"`
+	Artists []struct {
+		Id   int64  `json:"id"`
+		Name string `json:"name"`
+	} `json:"artists"`
+}
+
+// ArtistInfo returns the description, picture and similar artists of the artist named name.
+// Similar artists are only known to logged in users and left empty otherwise.
+func (d *NMProvider) ArtistInfo(ctx context.Context, name string) (*types.ArtistInfo, error) {
+	artist, err := d.SearchArtist(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var detail artistDetailResp
+	url := fmt.Sprintf("https://music.163.com/weapi/v1/artist/%d", artist.Id)
+	if _, err := d.cli.Request(ctx, url, &struct{}{}, &detail, api.NewOptions()); err != nil {
+		return nil, fmt.Errorf("artist detail: %w", err)
+	}
+	if detail.Code != 200 {
+		return nil, fmt.Errorf("artist detail API error: %+v", detail)
+	}
+	info := &types.ArtistInfo{
+		Artist:      *artist,
+		Description: detail.Artist.BriefDesc,
+		PicUrl:      detail.Artist.PicUrl,
+		URL:         fmt.Sprintf("https://music.163.com/#/artist?id=%d", artist.Id),
+	}
+	if info.PicUrl == "" {
+		info.PicUrl = detail.Artist.Img1v1Url
+	}
+
+	var similar similarArtistsResp
+	req := map[string]string{"artistid": fmt.Sprintf("%d", artist.Id)}
+	if _, err := d.cli.Request(ctx, "https://music.163.com/weapi/discovery/simiArtist", req, &similar, api.NewOptions()); err != nil {
+		log.Debug("Failed to get artists similar to %s: %v", name, err)
+	} else if similar.Code == 200 {
+		for _, a := range similar.Artists {
+			info.Similar = append(info.Similar, types.Artist{Id: a.Id, Name: a.Name})
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,82 @@
+package netease
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseArtistQuery(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want artistQuery
+	}{
+		{"https://music.163.com/#/artist?id=1", artistQuery{id: 1, mode: artistModeTop, limit: defaultTopSongs}},
+		{"https://music.163.com/#/artist?id=1&limit=10", artistQuery{id: 1, mode: artistModeTop, limit: 10}},
+		{
+			"https://music.163.com/#/artist?id=1&mode=albums&type=Album,%20ep&year=2015-2020",
+			artistQuery{id: 1, mode: artistModeAlbums, limit: defaultTopSongs, types: []string{"album", "ep"}, from: 2015, to: 2020},
+		},
+		{"https://music.163.com/#/artist?id=1&mode=albums&year=2019", artistQuery{id: 1, mode: artistModeAlbums, limit: defaultTopSongs, from: 2019, to: 2019}},
+	}
+	for _, tt := range tests {
+		got, err := parseArtistQuery(tt.uri, 1)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseArtistQuery(%q) = %+v, %v, want %+v", tt.uri, got, err, tt.want)
+		}
+	}
+
+	for _, uri := range []string{
+		"artist?id=1&mode=all",
+		"artist?id=1&limit=0",
+		"artist?id=1&type=bootleg",
+		"artist?id=1&year=2020-2015",
+		"artist?id=1&year=soon",
+	} {
+		if _, err := parseArtistQuery(uri, 1); err == nil {
+			t.Errorf("parseArtistQuery(%q) succeeded, want an error", uri)
+		}
+	}
+}
+
+func TestArtistAlbum_Types(t *testing.T) {
+	tests := []struct {
+		album artistAlbum
+		want  []string
+	}{
+		{artistAlbum{Type: "专辑", SubType: "录音室版"}, []string{"album"}},
+		{artistAlbum{Type: "专辑", SubType: "现场版"}, []string{"live"}},
+		{artistAlbum{Type: "合集"}, []string{"compilation"}},
+		{artistAlbum{Type: "EP/Single"}, []string{"ep", "single"}},
+		{artistAlbum{Type: "Single"}, []string{"ep", "single"}},
+	}
+	for _, tt := range tests {
+		if got := tt.album.types(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("types() of %+v = %v, want %v", tt.album, got, tt.want)
+		}
+	}
+}
+
+func TestArtistQuery_Includes(t *testing.T) {
+	published := func(year int) int64 {
+		return time.Date(year, 6, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	}
+	query := artistQuery{types: []string{"single"}, from: 2015, to: 2020}
+	tests := []struct {
+		album artistAlbum
+		want  bool
+	}{
+		{artistAlbum{Type: "EP/Single", PublishTime: published(2018)}, true},
+		{artistAlbum{Type: "专辑", PublishTime: published(2018)}, false},
+		{artistAlbum{Type: "EP/Single", PublishTime: published(2021)}, false},
+		{artistAlbum{Type: "EP/Single"}, false},
+	}
+	for _, tt := range tests {
+		if got := query.includes(&tt.album); got != tt.want {
+			t.Errorf("includes(%+v) = %v, want %v", tt.album, got, tt.want)
+		}
+	}
+	if !(&artistQuery{}).includes(&artistAlbum{Type: "专辑"}) {
+		t.Error("a query without filters left out an album")
+	}
+}
@@ -41,9 +41,11 @@ func (d *NMProvider) Download(ctx context.Context, music []*types.Music, config
 			defer sema.Release(1)
 			dl, err := d.newDownloader(music, config)
 			if err != nil {
+				err = fmt.Errorf("create download unit for %s: %w", music.String(), err)
+				config.Report(types.DownloadProgress{MusicId: music.Id, Name: music.Name, Phase: types.PhaseFailed, Error: err.Error()})
 				mutex.Lock()
 				results.Add(&types.DownloadResult{
-					Err: fmt.Errorf("create download unit for %s: %w", music.String(), err),
+					Err: err,
 				})
 				mutex.Unlock()
 				log.Error("create download unit for %s err: %v", music.String(), err)
@@ -51,14 +53,17 @@ func (d *NMProvider) Download(ctx context.Context, music []*types.Music, config
 			}
 			result, err := dl.Download(ctx)
 			if err != nil {
+				err = fmt.Errorf("download %s: %w", music.String(), err)
+				dl.report(types.PhaseFailed, func(p *types.DownloadProgress) { p.Error = err.Error() })
 				mutex.Lock()
 				results.Add(&types.DownloadResult{
-					Err: fmt.Errorf("download %s: %w", music.String(), err),
+					Err: err,
 				})
 				mutex.Unlock()
-				log.Error("download %s err: %v", music.String(), err)
+				log.Error("%v", err)
 				return
 			}
+			dl.report(types.PhaseDone, func(p *types.DownloadProgress) { p.Result = result })
 			mutex.Lock()
 			results.Add(&types.DownloadResult{
 				Data: result,
@@ -80,7 +85,9 @@ type Downloader struct {
 	Level          nmTypes.Level
 	Output         string
 	ConflictPolicy types.ConflictPolicy
+	PathTemplate   *types.PathTemplate
 	provider       *NMProvider
+	config         *types.DownloadConfig
 }
 
 func (d *NMProvider) newDownloader(music *types.Music, config *types.DownloadConfig) (*Downloader, error) {
@@ -99,15 +106,28 @@ func (d *NMProvider) newDownloader(music *types.Music, config *types.DownloadCon
 		Level:          level,
 		Output:         config.Output,
 		ConflictPolicy: policy,
+		PathTemplate:   config.PathTemplate,
 		provider:       d,
+		config:         config,
 	}, nil
 }
 
+// report reports the phase of the download, set fills in the details of the phase.
+func (d *Downloader) report(phase string, set func(p *types.DownloadProgress)) {
+	p := types.DownloadProgress{MusicId: d.Music.Id, Name: d.Music.Name, Phase: phase}
+	if set != nil {
+		set(&p)
+	}
+	d.config.Report(p)
+}
+
 func (d *Downloader) Download(ctx context.Context) (*types.DownloadedMusic, error) {
 	if d.Music == nil {
 		return nil, fmt.Errorf("music is required")
 	}
 
+	d.report(types.PhaseResolving, nil)
+
 	// Get available qualities for the song
 	quality, actualLevel, err := d.provider.getBestQuality(ctx, d.Music.SongId(), d.Level)
 	if err != nil {
@@ -144,6 +164,7 @@ func (d *Downloader) Download(ctx context.Context) (*types.DownloadedMusic, erro
 			return nil, fmt.Errorf("failed to download to local: %w", err)
 		}
 		if proceed {
+			d.report(types.PhaseTagging, nil)
 			// set music tags
 			err = d.provider.setMusicTags(ctx, d.Music, dest)
 			if err != nil {
@@ -165,12 +186,21 @@ func (d *Downloader) Download(ctx context.Context) (*types.DownloadedMusic, erro
 	return result, nil
 }
 
+// destination returns the path of the file of the song, a positive index is added to the name
+// to avoid a conflict.
+func (d *Downloader) destination(extType string, index int) string {
+	if d.PathTemplate != nil {
+		return filepath.Join(d.Output, filepath.FromSlash(d.PathTemplate.Path(d.Music, extType, index)))
+	}
+	return filepath.Join(d.Output, d.Music.Filename(extType, index))
+}
+
 // downloadToLocal downloads the song to a local file
 // returns the file path, whether the file need proceed to tag, and an error if any
 func (d *Downloader) downloadToLocal(ctx context.Context, info *SongDownloadInfo) (string, bool, error) {
 	var (
 		// drd = downResp.Data[0]
-		dest     = filepath.Join(d.Output, d.Music.Filename(info.Type, 0))
+		dest     = d.destination(info.Type, 0)
 		tempName = fmt.Sprintf("download-*-%s.tmp", d.Music.NameString())
 	)
 
@@ -194,7 +224,16 @@ func (d *Downloader) downloadToLocal(ctx context.Context, info *SongDownloadInfo
 	defer file.Close()
 
 	// 下载
-	resp, err := d.provider.cli.Download(ctx, info.Url, nil, nil, file, nil)
+	var out io.Writer = file
+	var progress *types.ProgressWriter
+	if d.config != nil && d.config.OnProgress != nil {
+		progress = types.NewProgressWriter(types.DownloadProgress{MusicId: d.Music.Id, Name: d.Music.Name, BytesTotal: info.Size}, d.config.OnProgress)
+		out = io.MultiWriter(file, progress)
+	}
+	resp, err := d.provider.cli.Download(ctx, info.Url, nil, nil, out, nil)
+	if progress != nil {
+		progress.Flush()
+	}
 	if err != nil {
 		_ = os.Remove(file.Name())
 		return "", false, fmt.Errorf("download: %w", err)
@@ -211,6 +250,7 @@ func (d *Downloader) downloadToLocal(ctx context.Context, info *SongDownloadInfo
 		info.Id, info.Url, info.Level, info.Br, info.EncodeType, info.Type, size/float64(utils.MB), int64(size), nmTypes.Free(info.Fee), file.Name(), dest)
 
 	// 校验md5文件完整性
+	d.report(types.PhaseVerifying, func(p *types.DownloadProgress) { p.BytesDone, p.BytesTotal = info.Size, info.Size })
 	if _, err := file.Seek(0, io.SeekStart); err != nil {
 		_ = os.Remove(file.Name())
 		return "", false, fmt.Errorf("seek: %w", err)
@@ -232,7 +272,7 @@ func (d *Downloader) downloadToLocal(ctx context.Context, info *SongDownloadInfo
 			break
 		}
 		if d.ConflictPolicy == types.ConflictPolicyRename {
-			dest = filepath.Join(d.Output, d.Music.Filename(info.Type, i))
+			dest = d.destination(info.Type, i)
 		}
 	}
 
@@ -241,6 +281,10 @@ func (d *Downloader) downloadToLocal(ctx context.Context, info *SongDownloadInfo
 		log.Error("close %s file err: %s", file.Name(), err)
 		_ = os.Remove(file.Name())
 	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		_ = os.Remove(file.Name())
+		return "", false, fmt.Errorf("create directory: %w", err)
+	}
 	if err := os.Rename(file.Name(), dest); err != nil {
 		_ = os.Remove(file.Name())
 		return "", false, fmt.Errorf("rename: %w", err)
@@ -42,12 +42,14 @@ func parseURI(source string) (string, int64, error) {
 	return matched[1], id, nil
 }
 
-// GetMusic returns the music information array
+// GetMusic returns the music information array. Artist URIs take the options described by
+// artistQuery.
 func (d *NMProvider) GetMusic(ctx context.Context, uris []string) ([]*types.Music, error) {
 	var (
-		source = make(map[string][]int64)
-		set    = make(map[int64]struct{})
-		music  []*types.Music
+		source  = make(map[string][]int64)
+		artists []artistQuery
+		set     = make(map[int64]struct{})
+		music   []*types.Music
 	)
 
 	for _, uri := range uris {
@@ -55,6 +57,14 @@ func (d *NMProvider) GetMusic(ctx context.Context, uris []string) ([]*types.Musi
 		if err != nil {
 			return nil, fmt.Errorf("parse uri: %w", err)
 		}
+		if kind == "artist" {
+			query, err := parseArtistQuery(uri, id)
+			if err != nil {
+				return nil, fmt.Errorf("parse uri: %w", err)
+			}
+			artists = append(artists, query)
+			continue
+		}
 		if v, ok := source[kind]; ok {
 			source[kind] = append(v, id)
 		} else {
@@ -65,97 +75,18 @@ func (d *NMProvider) GetMusic(ctx context.Context, uris []string) ([]*types.Musi
 	for k, ids := range source {
 		switch k {
 		case "song":
-			{
-				var tmp = make([]int64, 0, len(ids))
-				for _, id := range ids {
-					if _, ok := set[id]; ok {
-						continue
-					}
-					set[id] = struct{}{}
-					tmp = append(tmp, id)
-				}
-
-				// Process in batches of 500
-				pages, _ := utils.SplitSlice(tmp, 500)
-				for _, p := range pages {
-					var c = make([]weapi.SongDetailReqList, 0, len(p))
-					for _, v := range p {
-						c = append(c, weapi.SongDetailReqList{Id: fmt.Sprintf("%v", v), V: 0})
-					}
-					resp, err := d.request.SongDetail(ctx, &weapi.SongDetailReq{C: c})
-					if err != nil {
-						return nil, fmt.Errorf("SongDetail: %w", err)
-					}
-					if resp.Code != 200 {
-						return nil, fmt.Errorf("SongDetail err: %+v", resp)
-					}
-					if len(resp.Songs) <= 0 {
-						log.Warn("SongDetail() Songs is empty")
-						continue
-					}
-					for _, v := range resp.Songs {
-						artist := make([]types.Artist, 0, len(v.Ar))
-						for _, a := range v.Ar {
-							artist = append(artist, types.Artist{
-								Id:   a.Id,
-								Name: a.Name,
-							})
-						}
-						album := types.Album{
-							Id:     v.Al.Id,
-							Name:   v.Al.Name,
-							PicUrl: v.Al.PicUrl,
-						}
-						music = append(music, &types.Music{
-							Id:          v.Id,
-							Name:        v.Name,
-							Artist:      artist,
-							Album:       album,
-							Time:        v.Dt,
-							TrackNumber: v.Cd,
-						})
-					}
-				}
+			songs, err := d.songDetails(ctx, ids, set)
+			if err != nil {
+				return nil, err
 			}
+			music = append(music, songs...)
 		case "album":
 			for _, id := range ids {
-				album, err := d.request.Album(ctx, &weapi.AlbumReq{Id: fmt.Sprintf("%d", id)})
+				songs, err := d.albumSongs(ctx, id, set)
 				if err != nil {
-					return nil, fmt.Errorf("album(%v): %w", id, err)
-				}
-				if album.Code != 200 {
-					return nil, fmt.Errorf("album(%v) err: %+v", id, album)
-				}
-				if len(album.Songs) <= 0 {
-					log.Warn("Album(%v) Songs is empty", id)
-					continue
-				}
-				for _, v := range album.Songs {
-					if _, ok := set[v.Id]; ok {
-						continue
-					}
-					set[v.Id] = struct{}{}
-					artist := make([]types.Artist, 0, len(v.Ar))
-					for _, a := range v.Ar {
-						artist = append(artist, types.Artist{
-							Id:   a.Id,
-							Name: a.Name,
-						})
-					}
-					album := types.Album{
-						Id:     v.Al.Id,
-						Name:   v.Al.Name,
-						PicUrl: v.Al.PicUrl,
-					}
-					music = append(music, &types.Music{
-						Id:          v.Id,
-						Name:        v.Name,
-						Artist:      artist,
-						Album:       album,
-						Time:        v.Dt,
-						TrackNumber: v.Cd,
-					})
+					return nil, err
 				}
+				music = append(music, songs...)
 			}
 		case "playlist":
 			for _, id := range ids {
@@ -172,63 +103,133 @@ func (d *NMProvider) GetMusic(ctx context.Context, uris []string) ([]*types.Musi
 				}
 				var tmp = make([]int64, 0, len(playlist.Playlist.TrackIds))
 				for _, v := range playlist.Playlist.TrackIds {
-					if _, ok := set[v.Id]; ok {
-						continue
-					}
-					set[v.Id] = struct{}{}
 					tmp = append(tmp, v.Id)
 				}
-
-				// Process in batches
-				pages, _ := utils.SplitSlice(tmp, 500)
-				for _, p := range pages {
-					var c = make([]weapi.SongDetailReqList, 0, len(p))
-					for _, v := range p {
-						c = append(c, weapi.SongDetailReqList{Id: fmt.Sprintf("%v", v), V: 0})
-					}
-					resp, err := d.request.SongDetail(ctx, &weapi.SongDetailReq{C: c})
-					if err != nil {
-						return nil, fmt.Errorf("SongDetail: %w", err)
-					}
-					if resp.Code != 200 {
-						return nil, fmt.Errorf("SongDetail err: %+v", resp)
-					}
-					if len(resp.Songs) <= 0 {
-						log.Warn("SongDetail Songs is empty")
-						continue
-					}
-					for _, v := range resp.Songs {
-						artist := make([]types.Artist, 0, len(v.Ar))
-						for _, a := range v.Ar {
-							artist = append(artist, types.Artist{
-								Id:   a.Id,
-								Name: a.Name,
-							})
-						}
-						album := types.Album{
-							Id:     v.Al.Id,
-							Name:   v.Al.Name,
-							PicUrl: v.Al.PicUrl,
-						}
-						music = append(music, &types.Music{
-							Id:          v.Id,
-							Name:        v.Name,
-							Artist:      artist,
-							Album:       album,
-							Time:        v.Dt,
-							TrackNumber: v.Cd,
-						})
-					}
+				songs, err := d.songDetails(ctx, tmp, set)
+				if err != nil {
+					return nil, err
 				}
+				music = append(music, songs...)
 			}
 		default:
 			return nil, fmt.Errorf("[%s] is not supported", k)
 		}
 	}
 
+	for _, query := range artists {
+		songs, err := d.artistSongs(ctx, query, set)
+		if err != nil {
+			return nil, err
+		}
+		music = append(music, songs...)
+	}
+
 	if len(music) <= 0 {
 		return nil, fmt.Errorf("input uri is empty or the song is copyrighted")
 	}
 
 	return music, nil
 }
+
+// songDetails returns the songs of ids that are not in set yet, and adds them to it.
+func (d *NMProvider) songDetails(ctx context.Context, ids []int64, set map[int64]struct{}) ([]*types.Music, error) {
+	var tmp = make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := set[id]; ok {
+			continue
+		}
+		set[id] = struct{}{}
+		tmp = append(tmp, id)
+	}
+
+	var music []*types.Music
+	// Process in batches of 500
+	pages, _ := utils.SplitSlice(tmp, 500)
+	for _, p := range pages {
+		var c = make([]weapi.SongDetailReqList, 0, len(p))
+		for _, v := range p {
+			c = append(c, weapi.SongDetailReqList{Id: fmt.Sprintf("%v", v), V: 0})
+		}
+		resp, err := d.request.SongDetail(ctx, &weapi.SongDetailReq{C: c})
+		if err != nil {
+			return nil, fmt.Errorf("SongDetail: %w", err)
+		}
+		if resp.Code != 200 {
+			return nil, fmt.Errorf("SongDetail err: %+v", resp)
+		}
+		if len(resp.Songs) <= 0 {
+			log.Warn("SongDetail() Songs is empty")
+			continue
+		}
+		for _, v := range resp.Songs {
+			artist := make([]types.Artist, 0, len(v.Ar))
+			for _, a := range v.Ar {
+				artist = append(artist, types.Artist{
+					Id:   a.Id,
+					Name: a.Name,
+				})
+			}
+			album := types.Album{
+				Id:     v.Al.Id,
+				Name:   v.Al.Name,
+				PicUrl: v.Al.PicUrl,
+				Year:   publishYear(v.PublishTime),
+			}
+			music = append(music, &types.Music{
+				Id:          v.Id,
+				Name:        v.Name,
+				Artist:      artist,
+				Album:       album,
+				Time:        v.Dt,
+				TrackNumber: strconv.FormatInt(v.No, 10),
+				Disc:        v.Cd,
+			})
+		}
+	}
+	return music, nil
+}
+
+// albumSongs returns the songs of the album that are not in set yet, and adds them to it.
+func (d *NMProvider) albumSongs(ctx context.Context, id int64, set map[int64]struct{}) ([]*types.Music, error) {
+	album, err := d.request.Album(ctx, &weapi.AlbumReq{Id: fmt.Sprintf("%d", id)})
+	if err != nil {
+		return nil, fmt.Errorf("album(%v): %w", id, err)
+	}
+	if album.Code != 200 {
+		return nil, fmt.Errorf("album(%v) err: %+v", id, album)
+	}
+	if len(album.Songs) <= 0 {
+		log.Warn("Album(%v) Songs is empty", id)
+		return nil, nil
+	}
+	var music []*types.Music
+	for _, v := range album.Songs {
+		if _, ok := set[v.Id]; ok {
+			continue
+		}
+		set[v.Id] = struct{}{}
+		artist := make([]types.Artist, 0, len(v.Ar))
+		for _, a := range v.Ar {
+			artist = append(artist, types.Artist{
+				Id:   a.Id,
+				Name: a.Name,
+			})
+		}
+		music = append(music, &types.Music{
+			Id:     v.Id,
+			Name:   v.Name,
+			Artist: artist,
+			Album: types.Album{
+				Id:     v.Al.Id,
+				Name:   v.Al.Name,
+				PicUrl: v.Al.PicUrl,
+				Artist: album.Album.Artist.Name,
+				Year:   publishYear(album.Album.PublishTime),
+			},
+			Time:        v.Dt,
+			TrackNumber: strconv.FormatInt(v.No, 10),
+			Disc:        v.Cd,
+		})
+	}
+	return music, nil
+}
@@ -27,7 +27,10 @@ type NMProvider struct {
 	jar     cookiecloud.CookieJar
 }
 
-var _ provider.Provider = (*NMProvider)(nil)
+var (
+	_ provider.Provider       = (*NMProvider)(nil)
+	_ provider.ArtistProvider = (*NMProvider)(nil)
+)
 
 // NewProvider creates a new NMProvider for multiple songs (returns concrete type)
 func NewProvider(jar cookiecloud.CookieJar) (provider.Provider, error) {
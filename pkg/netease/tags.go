@@ -3,6 +3,7 @@ package netease
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/stkevintan/miko/pkg/log"
@@ -17,7 +18,7 @@ func (d *NMProvider) setMusicTags(ctx context.Context, music *types.Music, fileP
 		artistNames = append(artistNames, ar.Name)
 	}
 
-	err := tags.Write(filePath, map[string][]string{
+	values := map[string][]string{
 		// Multi-valued tags allowed
 		tags.Artist:      artistNames,
 		tags.Album:       {music.Album.Name},
@@ -25,7 +26,19 @@ func (d *NMProvider) setMusicTags(ctx context.Context, music *types.Music, fileP
 		tags.Length:      {fmt.Sprintf("%d", music.Time/1000)}, // convert milliseconds to seconds
 		tags.Lyrics:      {music.Lyrics},
 		tags.TrackNumber: {music.TrackNumber},
-	})
+		// the library recognizes the song by its ID when it is downloaded again
+		tags.NeteaseID: {strconv.FormatInt(music.Id, 10)},
+	}
+	if music.Disc != "" {
+		values[tags.DiscNumber] = []string{music.Disc}
+	}
+	if music.Album.Artist != "" {
+		values[tags.AlbumArtist] = []string{music.Album.Artist}
+	}
+	if music.Album.Year > 0 {
+		values[tags.Date] = []string{fmt.Sprintf("%d", music.Album.Year)}
+	}
+	err := tags.Write(filePath, values)
 	if err != nil {
 		return fmt.Errorf("WriteTags: %w", err)
 	}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/chaunsin/netease-cloud-music/api/types"
 	nmTypes "github.com/chaunsin/netease-cloud-music/api/types"
@@ -51,3 +52,11 @@ func ValidateQualityLevel(level string) (nmTypes.Level, error) {
 		}
 	}
 }
+
+// publishYear returns the year of a publish time in milliseconds, or 0 when it is unknown.
+func publishYear(ms int64) int {
+	if ms <= 0 {
+		return 0
+	}
+	return time.UnixMilli(ms).UTC().Year()
+}
@@ -0,0 +1,241 @@
+package playlists
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	_ "image/gif"
+	_ "image/png"
+)
+
+// CoverArtPrefix prefixes the cover art ids of playlists, as "al-" does for albums.
+const CoverArtPrefix = "pl-"
+
+// collageSize is the width and height of a generated collage in pixels.
+const collageSize = 600
+
+// CollageCovers is the number of album covers a collage is made of.
+const CollageCovers = 4
+
+func CoverArtID(playlistID uint) string {
+	return CoverArtPrefix + strconv.FormatUint(uint64(playlistID), 10)
+}
+
+// ParseCoverArtID returns the playlist id of a cover art id made by CoverArtID.
+func ParseCoverArtID(coverArt string) (uint, bool) {
+	rest, found := strings.CutPrefix(coverArt, CoverArtPrefix)
+	if !found {
+		return 0, false
+	}
+	id, _, _ := strings.Cut(rest, "-")
+	n, err := strconv.ParseUint(id, 10, 64)
+	return uint(n), err == nil
+}
+
+// customCoverPath is where an image uploaded for a playlist is stored in the cover cache.
+func customCoverPath(cacheDir string, playlistID uint) string {
+	return filepath.Join(cacheDir, CoverArtID(playlistID)+"-custom")
+}
+
+// SaveCustomCover stores an uploaded image as the cover of a playlist.
+func SaveCustomCover(cacheDir string, playlistID uint, data []byte) error {
+	if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("unsupported image: %w", err)
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(customCoverPath(cacheDir, playlistID), data, 0644)
+}
+
+// RemoveCoverArt deletes the cached collages of a playlist, and its uploaded image too when
+// custom is set.
+func RemoveCoverArt(cacheDir string, playlistID uint, custom bool) error {
+	matches, err := filepath.Glob(filepath.Join(cacheDir, CoverArtID(playlistID)+"-*"))
+	if err != nil {
+		return err
+	}
+	customPath := customCoverPath(cacheDir, playlistID)
+	var errs []error
+	for _, path := range matches {
+		if path == customPath && !custom {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CoverArt returns the path of the cover of a playlist: the image uploaded for it, or else a
+// collage of the first four of covers found in the cache, the cover art ids of its first
+// albums. Collages are named after the covers they are made of, so a collage is regenerated
+// once the playlist content or one of the covers changes. An empty path is returned when no
+// cover is found.
+func CoverArt(cacheDir string, playlistID uint, covers []string) (string, error) {
+	customPath := customCoverPath(cacheDir, playlistID)
+	if fileExists(customPath) {
+		return customPath, nil
+	}
+
+	var paths []string
+	key := md5.New()
+	seen := make(map[string]bool)
+	for _, coverArt := range covers {
+		if len(paths) == CollageCovers {
+			break
+		}
+		if coverArt == "" || seen[coverArt] {
+			continue
+		}
+		seen[coverArt] = true
+		path := filepath.Join(cacheDir, coverArt)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		paths = append(paths, path)
+		fmt.Fprintf(key, "%s|%d|", coverArt, info.ModTime().UnixNano())
+	}
+	if len(paths) == 0 {
+		return "", nil
+	}
+
+	path := filepath.Join(cacheDir, fmt.Sprintf("%s-%x", CoverArtID(playlistID), key.Sum(nil)[:6]))
+	if fileExists(path) {
+		return path, nil
+	}
+
+	images := make([][]byte, 0, len(paths))
+	for _, cover := range paths {
+		data, err := os.ReadFile(cover)
+		if err != nil {
+			return "", err
+		}
+		images = append(images, data)
+	}
+	collage, err := Collage(images)
+	if err != nil {
+		return "", err
+	}
+
+	// concurrent requests each write a whole collage, the last rename wins
+	tmp, err := os.CreateTemp(cacheDir, ".collage-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(collage); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+
+	// collages of previous playlist contents are stale now
+	stale, err := filepath.Glob(filepath.Join(cacheDir, CoverArtID(playlistID)+"-*"))
+	if err != nil {
+		return "", err
+	}
+	for _, p := range stale {
+		if p != path && p != customPath {
+			os.Remove(p)
+		}
+	}
+	return path, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Collage draws up to four images into a 2×2 grid, a single image fills the whole collage and
+// two or three images are repeated to fill the grid. Images that can't be decoded are skipped.
+func Collage(images [][]byte) ([]byte, error) {
+	var decoded []image.Image
+	for _, data := range images {
+		if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+			decoded = append(decoded, img)
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, collageSize, collageSize))
+	half := collageSize / 2
+	tiles := [4]image.Rectangle{
+		image.Rect(0, 0, half, half),
+		image.Rect(half, 0, collageSize, half),
+		image.Rect(0, half, half, collageSize),
+		image.Rect(half, half, collageSize, collageSize),
+	}
+	switch len(decoded) {
+	case 0:
+		return nil, errors.New("no decodable cover image")
+	case 1:
+		drawScaled(dst, dst.Bounds(), decoded[0])
+	case 2:
+		for i, n := range [4]int{0, 1, 1, 0} {
+			drawScaled(dst, tiles[i], decoded[n])
+		}
+	case 3:
+		for i, n := range [4]int{0, 1, 2, 0} {
+			drawScaled(dst, tiles[i], decoded[n])
+		}
+	default:
+		for i := range tiles {
+			drawScaled(dst, tiles[i], decoded[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawScaled draws the centered square of src into the rectangle r of dst, averaging the
+// source pixels covered by every destination pixel.
+func drawScaled(dst *image.RGBA, r image.Rectangle, src image.Image) {
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	if side == 0 {
+		return
+	}
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	w, h := r.Dx(), r.Dy()
+
+	for dy := range h {
+		sy0 := y0 + dy*side/h
+		sy1 := max(y0+(dy+1)*side/h, sy0+1)
+		for dx := range w {
+			sx0 := x0 + dx*side/w
+			sx1 := max(x0+(dx+1)*side/w, sx0+1)
+			var rs, gs, bs, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, _ := src.At(sx, sy).RGBA()
+					rs, gs, bs, n = rs+uint64(cr), gs+uint64(cg), bs+uint64(cb), n+1
+				}
+			}
+			dst.SetRGBA(r.Min.X+dx, r.Min.Y+dy, color.RGBA{
+				R: uint8(rs / n >> 8), G: uint8(gs / n >> 8), B: uint8(bs / n >> 8), A: 0xff,
+			})
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package playlists
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeCover(t *testing.T, dir, name string, c color.Color) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	for y := range 30 {
+		for x := range 40 {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCoverArt(t *testing.T) {
+	dir := t.TempDir()
+	writeCover(t, dir, "al-1", color.RGBA{R: 255, A: 255})
+	writeCover(t, dir, "al-2", color.RGBA{B: 255, A: 255})
+
+	covers := []string{"al-1", "al-1", "al-2", "al-missing"}
+	path, err := CoverArt(dir, 7, covers)
+	if err != nil || path == "" {
+		t.Fatalf("CoverArt() = %q, %v", path, err)
+	}
+	data, _ := os.ReadFile(path)
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != collageSize {
+		t.Errorf("collage width = %d, want %d", img.Bounds().Dx(), collageSize)
+	}
+	// the top left tile is the first album, the top right one the second
+	if r, _, b, _ := img.At(10, 10).RGBA(); r < b {
+		t.Errorf("top left tile is not red")
+	}
+	if r, _, b, _ := img.At(collageSize-10, 10).RGBA(); b < r {
+		t.Errorf("top right tile is not blue")
+	}
+
+	again, _ := CoverArt(dir, 7, covers)
+	if again != path {
+		t.Errorf("unchanged playlist got a new collage %q, want %q", again, path)
+	}
+	changed, _ := CoverArt(dir, 7, covers[2:])
+	if changed == path {
+		t.Errorf("changed playlist kept collage %q", path)
+	}
+	if fileExists(path) {
+		t.Errorf("stale collage %q was not removed", path)
+	}
+
+	if id, ok := ParseCoverArtID(filepath.Base(changed)); !ok || id != 7 {
+		t.Errorf("ParseCoverArtID(%q) = %d, %v", filepath.Base(changed), id, ok)
+	}
+}
@@ -0,0 +1,214 @@
+package playlists
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/stkevintan/miko/models"
+)
+
+// Formats of playlist files
+const (
+	FormatM3U  = "m3u"
+	FormatM3U8 = "m3u8"
+	FormatXSPF = "xspf"
+)
+
+// Entry is a track listed in a playlist file. Artist, Title and Duration are only known when
+// the file carries extended info.
+type Entry struct {
+	// Line is the line of an M3U file or the position of an XSPF track, starting at 1
+	Line     int
+	Location string
+	Artist   string
+	Title    string
+	Duration int
+}
+
+// FormatOf returns the playlist format of a file name, or an empty string for other files.
+func FormatOf(name string) string {
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), ".")); ext {
+	case FormatM3U, FormatM3U8, FormatXSPF:
+		return ext
+	}
+	return ""
+}
+
+// IsPlaylistFile reports whether path is a playlist file that can be imported.
+func IsPlaylistFile(path string) bool {
+	return FormatOf(path) != ""
+}
+
+// Parse reads the entries of a playlist file in the given format.
+func Parse(format string, r io.Reader) ([]Entry, error) {
+	switch format {
+	case FormatM3U, FormatM3U8:
+		return ParseM3U(r)
+	case FormatXSPF:
+		return ParseXSPF(r)
+	}
+	return nil, fmt.Errorf("unsupported playlist format %q", format)
+}
+
+// Write writes songs as a playlist file of the given format.
+func Write(w io.Writer, format, name string, songs []models.Child, location func(models.Child) string) error {
+	switch format {
+	case FormatM3U, FormatM3U8:
+		return WriteM3U8(w, name, songs, location)
+	case FormatXSPF:
+		return WriteXSPF(w, name, songs, location)
+	}
+	return fmt.Errorf("unsupported playlist format %q", format)
+}
+
+// ParseM3U reads a plain or extended M3U playlist. #EXTINF lines provide the duration, artist
+// and title of the entry that follows them.
+func ParseM3U(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	var info Entry
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		switch {
+		case text == "":
+		case strings.HasPrefix(text, "#EXTINF:"):
+			info = parseEXTINF(strings.TrimPrefix(text, "#EXTINF:"))
+		case strings.HasPrefix(text, "#"):
+		default:
+			info.Line = line
+			info.Location = locationPath(text)
+			entries = append(entries, info)
+			info = Entry{}
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// parseEXTINF parses "123,Artist - Title", attributes such as tvg-id="x" before the comma are ignored.
+func parseEXTINF(s string) Entry {
+	var e Entry
+	head, display, found := strings.Cut(s, ",")
+	if !found {
+		return e
+	}
+	if fields := strings.Fields(head); len(fields) > 0 {
+		if d, err := strconv.Atoi(fields[0]); err == nil && d > 0 {
+			e.Duration = d
+		}
+	}
+	if artist, title, found := strings.Cut(display, " - "); found {
+		e.Artist, e.Title = strings.TrimSpace(artist), strings.TrimSpace(title)
+	} else {
+		e.Title = strings.TrimSpace(display)
+	}
+	return e
+}
+
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"playlist"`
+	Version string      `xml:"version,attr"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title,omitempty"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location string `xml:"location,omitempty"`
+	Title    string `xml:"title,omitempty"`
+	Creator  string `xml:"creator,omitempty"`
+	Album    string `xml:"album,omitempty"`
+	Duration int    `xml:"duration,omitempty"`
+}
+
+// ParseXSPF reads an XSPF playlist, file:// locations are turned into paths.
+func ParseXSPF(r io.Reader) ([]Entry, error) {
+	var p xspfPlaylist
+	if err := xml.NewDecoder(r).Decode(&p); err != nil {
+		return nil, fmt.Errorf("invalid XSPF playlist: %w", err)
+	}
+	entries := make([]Entry, len(p.Tracks))
+	for i, t := range p.Tracks {
+		entries[i] = Entry{
+			Line:     i + 1,
+			Location: locationPath(strings.TrimSpace(t.Location)),
+			Artist:   strings.TrimSpace(t.Creator),
+			Title:    strings.TrimSpace(t.Title),
+			Duration: t.Duration / 1000,
+		}
+	}
+	return entries, nil
+}
+
+// locationPath turns a file:// URL or a percent-encoded relative URL into a path, other
+// locations are returned unchanged.
+func locationPath(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	switch u.Scheme {
+	case "file":
+		return u.Path
+	case "":
+		if strings.Contains(location, "%") {
+			return u.Path
+		}
+	}
+	return location
+}
+
+// WriteM3U8 writes songs as an extended M3U playlist. location returns the path written for a song.
+func WriteM3U8(w io.Writer, name string, songs []models.Child, location func(models.Child) string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#EXTM3U")
+	if name != "" {
+		fmt.Fprintf(bw, "#PLAYLIST:%s\n", name)
+	}
+	for _, song := range songs {
+		fmt.Fprintf(bw, "#EXTINF:%d,%s - %s\n", song.Duration, song.Artist, song.Title)
+		fmt.Fprintln(bw, location(song))
+	}
+	return bw.Flush()
+}
+
+// WriteXSPF writes songs as an XSPF playlist. location returns the path written for a song,
+// absolute paths become file:// URLs.
+func WriteXSPF(w io.Writer, name string, songs []models.Child, location func(models.Child) string) error {
+	p := xspfPlaylist{Version: "1", Xmlns: "http://xspf.org/ns/0/", Title: name}
+	for _, song := range songs {
+		p.Tracks = append(p.Tracks, xspfTrack{
+			Location: locationURL(location(song)),
+			Title:    song.Title,
+			Creator:  song.Artist,
+			Album:    song.Album,
+			Duration: song.Duration * 1000,
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(p); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func locationURL(path string) string {
+	path = filepath.ToSlash(path)
+	if strings.HasPrefix(path, "/") {
+		return (&url.URL{Scheme: "file", Path: path}).String()
+	}
+	return (&url.URL{Path: path}).String()
+}
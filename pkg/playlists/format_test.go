@@ -0,0 +1,47 @@
+package playlists
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stkevintan/miko/models"
+)
+
+func TestParseM3U(t *testing.T) {
+	in := "\ufeff#EXTM3U\n#EXTINF:125,The Beatles - Yesterday\nBeatles/01 Yesterday.flac\n\n# comment\nfile:///music/a%20b.mp3\n"
+	got, err := ParseM3U(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{Line: 3, Location: "Beatles/01 Yesterday.flac", Artist: "The Beatles", Title: "Yesterday", Duration: 125},
+		{Line: 6, Location: "/music/a b.mp3"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseM3U() = %+v, want %+v", got, want)
+	}
+}
+
+func TestXSPFRoundTrip(t *testing.T) {
+	songs := []models.Child{
+		{Title: "Help!", Artist: "The Beatles", Path: "/music/Beatles/02 Help!.flac", Duration: 140},
+		{Title: "晴天", Artist: "周杰伦", Path: "/music/Jay/03.flac", Duration: 269},
+	}
+	var buf bytes.Buffer
+	if err := WriteXSPF(&buf, "mix", songs, func(s models.Child) string { return s.Path }); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseXSPF(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{Line: 1, Location: "/music/Beatles/02 Help!.flac", Artist: "The Beatles", Title: "Help!", Duration: 140},
+		{Line: 2, Location: "/music/Jay/03.flac", Artist: "周杰伦", Title: "晴天", Duration: 269},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseXSPF() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,392 @@
+package playlists
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/log"
+	"gorm.io/gorm"
+)
+
+// Manager imports playlist files into the library and keeps synced playlists up to date.
+type Manager struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) *Manager {
+	return &Manager{db: db}
+}
+
+// Unmatched is a playlist file entry that matches no song of the library.
+type Unmatched struct {
+	Line  int    `json:"line"`
+	Entry string `json:"entry"`
+}
+
+type ImportResult struct {
+	Playlist  *models.PlaylistRecord `json:"playlist"`
+	Matched   int                    `json:"matched"`
+	Unmatched []Unmatched            `json:"unmatched"`
+}
+
+// Import creates a playlist owned by owner from the entries of a playlist file. Relative
+// locations are resolved against baseDir when it is set.
+func (m *Manager) Import(owner, name string, entries []Entry, baseDir string) (*ImportResult, error) {
+	ids, unmatched, err := m.Resolve(entries, baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &models.PlaylistRecord{Name: name, Owner: owner}
+	err = m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(p).Error; err != nil {
+			return err
+		}
+		return replaceSongs(tx, p.ID, ids)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ImportResult{Playlist: p, Matched: len(ids), Unmatched: unmatched}, nil
+}
+
+// Sync creates or refreshes the playlist read from the playlist file at path, a playlist whose
+// songs are the same is left untouched. Synced playlists are named after their file and are
+// read-only for clients.
+func (m *Manager) Sync(path, owner string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	entries, err := Parse(FormatOf(path), f)
+	if err != nil {
+		return err
+	}
+	ids, unmatched, err := m.Resolve(entries, filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	if len(unmatched) > 0 {
+		log.Warn("Playlist %q: %d of %d entries match no song", path, len(unmatched), len(entries))
+	}
+
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		var p models.PlaylistRecord
+		if err := tx.Where("path = ?", path).Limit(1).Find(&p).Error; err != nil {
+			return err
+		}
+		if p.ID == 0 {
+			p = models.PlaylistRecord{
+				Name:  strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+				Owner: owner,
+				Path:  path,
+			}
+		} else {
+			var current []string
+			if err := tx.Model(&models.PlaylistSong{}).Where("playlist_id = ?", p.ID).
+				Order("position").Pluck("song_id", &current).Error; err != nil {
+				return err
+			}
+			if slices.Equal(current, ids) && p.Unmatched == len(unmatched) {
+				return nil
+			}
+		}
+		p.Unmatched = len(unmatched)
+		// saving an existing playlist bumps its change time
+		if err := tx.Save(&p).Error; err != nil {
+			return err
+		}
+		return replaceSongs(tx, p.ID, ids)
+	})
+}
+
+// Mirror sets the songs of the playlist id of owner to ids, creating a playlist named name when
+// there is no such playlist, and records the songs added and removed in its activity. It returns
+// the ID of the playlist and how many songs were added and removed.
+func (m *Manager) Mirror(id uint, owner, name string, ids []string) (uint, int, int, error) {
+	var added, removed []string
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		var p models.PlaylistRecord
+		if err := tx.Where("id = ? AND owner = ?", id, owner).Limit(1).Find(&p).Error; err != nil {
+			return err
+		}
+		if p.ID == 0 {
+			p = models.PlaylistRecord{Name: name, Owner: owner}
+			if err := tx.Create(&p).Error; err != nil {
+				return err
+			}
+		}
+		id = p.ID
+
+		var current []string
+		if err := tx.Model(&models.PlaylistSong{}).Where("playlist_id = ?", p.ID).
+			Order("position").Pluck("song_id", &current).Error; err != nil {
+			return err
+		}
+		added, removed = diff(current, ids), diff(ids, current)
+		if slices.Equal(current, ids) {
+			return nil
+		}
+		// saving the playlist bumps its change time
+		if err := tx.Save(&p).Error; err != nil {
+			return err
+		}
+		if err := replaceSongs(tx, p.ID, ids); err != nil {
+			return err
+		}
+		var activities []models.PlaylistActivity
+		for _, songID := range added {
+			activities = append(activities, models.PlaylistActivity{PlaylistID: p.ID, Username: owner, Action: models.PlaylistActionAdd, SongID: songID})
+		}
+		for _, songID := range removed {
+			activities = append(activities, models.PlaylistActivity{PlaylistID: p.ID, Username: owner, Action: models.PlaylistActionRemove, SongID: songID})
+		}
+		if len(activities) == 0 {
+			return nil
+		}
+		return tx.Create(&activities).Error
+	})
+	return id, len(added), len(removed), err
+}
+
+// Delete removes the playlist p with its songs, collaborators and activity, then its cached
+// covers from cacheDir.
+func Delete(db *gorm.DB, cacheDir string, p *models.PlaylistRecord) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("playlist_id = ?", p.ID).Delete(&models.PlaylistActivity{}).Error; err != nil {
+			return err
+		}
+		return tx.Select("Songs", "Collaborators").Delete(p).Error
+	})
+	if err != nil {
+		return err
+	}
+	if err := RemoveCoverArt(cacheDir, p.ID, true); err != nil {
+		log.Warn("Failed to remove cover art of playlist %d: %v", p.ID, err)
+	}
+	return nil
+}
+
+// diff returns the IDs of b that are not in a.
+func diff(a, b []string) []string {
+	seen := make(map[string]bool, len(a))
+	for _, id := range a {
+		seen[id] = true
+	}
+	var out []string
+	for _, id := range b {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func replaceSongs(tx *gorm.DB, playlistID uint, ids []string) error {
+	if err := tx.Where("playlist_id = ?", playlistID).Delete(&models.PlaylistSong{}).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	songs := make([]models.PlaylistSong, len(ids))
+	for i, id := range ids {
+		songs[i] = models.PlaylistSong{PlaylistID: playlistID, SongID: id, Position: i}
+	}
+	return tx.CreateInBatches(&songs, 500).Error
+}
+
+// Resolve finds the song of every entry, by absolute path, by path relative to baseDir, by the
+// trailing components of the path and finally by artist and title. Entries matching no song
+// are reported in file order.
+func (m *Manager) Resolve(entries []Entry, baseDir string) ([]string, []Unmatched, error) {
+	var ids []string
+	var unmatched []Unmatched
+	for _, e := range entries {
+		id, err := m.resolve(e, baseDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		if id == "" {
+			unmatched = append(unmatched, Unmatched{Line: e.Line, Entry: describe(e)})
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, unmatched, nil
+}
+
+// Match finds the song of a single entry as Resolve does, returning an empty ID when it matches
+// no song.
+func (m *Manager) Match(e Entry, baseDir string) (string, error) {
+	return m.resolve(e, baseDir)
+}
+
+func describe(e Entry) string {
+	if e.Location != "" {
+		return e.Location
+	}
+	if e.Artist != "" {
+		return e.Artist + " - " + e.Title
+	}
+	return e.Title
+}
+
+func (m *Manager) resolve(e Entry, baseDir string) (string, error) {
+	if e.Location != "" && !strings.Contains(e.Location, "://") {
+		// playlists written on Windows use backslashes
+		loc := filepath.FromSlash(strings.ReplaceAll(e.Location, `\`, "/"))
+		if !filepath.IsAbs(loc) && baseDir != "" {
+			if id, err := m.byPath(filepath.Join(baseDir, loc)); id != "" || err != nil {
+				return id, err
+			}
+		}
+		if filepath.IsAbs(loc) {
+			if id, err := m.byPath(filepath.Clean(loc)); id != "" || err != nil {
+				return id, err
+			}
+		}
+		if id, err := m.bySuffix(loc); id != "" || err != nil {
+			return id, err
+		}
+	}
+	return m.byArtistTitle(e)
+}
+
+func (m *Manager) byPath(path string) (string, error) {
+	var ids []string
+	err := m.db.Model(&models.Child{}).Where("path = ? AND is_dir = ?", path, false).Limit(1).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// maxSuffixComponents bounds how many trailing path components are tried, typically
+// artist/album/file.
+const maxSuffixComponents = 4
+
+// bySuffix matches the trailing components of a path that was written for another machine or
+// another music folder layout. Fewer components are tried until a single song matches.
+func (m *Manager) bySuffix(loc string) (string, error) {
+	var parts []string
+	for _, part := range strings.Split(filepath.ToSlash(loc), "/") {
+		if part != "" && part != "." && part != ".." && !strings.HasSuffix(part, ":") {
+			parts = append(parts, part)
+		}
+	}
+	for n := min(len(parts), maxSuffixComponents); n > 0; n-- {
+		suffix := string(filepath.Separator) + filepath.Join(parts[len(parts)-n:]...)
+		var ids []string
+		err := m.db.Model(&models.Child{}).
+			Where(`path LIKE ? ESCAPE '\' AND is_dir = ?`, "%"+escapeLike(suffix), false).
+			Limit(2).Pluck("id", &ids).Error
+		if err != nil {
+			return "", err
+		}
+		switch len(ids) {
+		case 1:
+			return ids[0], nil
+		case 2:
+			// shorter suffixes are only more ambiguous
+			return "", nil
+		}
+	}
+	return "", nil
+}
+
+// byArtistTitle matches songs by title and artist, taken from the extended info of the entry
+// or from an "Artist - Title" file name. When several songs match, the one whose duration is
+// closest wins.
+func (m *Manager) byArtistTitle(e Entry) (string, error) {
+	artist, title := e.Artist, e.Title
+	if title == "" && e.Location != "" {
+		stem := filepath.Base(filepath.ToSlash(e.Location))
+		stem = strings.TrimSuffix(stem, filepath.Ext(stem))
+		if a, t, found := strings.Cut(stem, " - "); found {
+			artist, title = a, t
+		} else {
+			title = strings.TrimLeft(stem, "0123456789. -_")
+		}
+	}
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "", nil
+	}
+
+	var candidates []models.Child
+	err := m.db.Model(&models.Child{}).Select("id, artist, duration").
+		Where("LOWER(title) = LOWER(?) AND is_dir = ?", title, false).
+		Limit(50).Find(&candidates).Error
+	if err != nil {
+		return "", err
+	}
+
+	want := normalize(artist)
+	best, bestDiff := "", -1
+	for _, c := range candidates {
+		if want != "" {
+			got := normalize(c.Artist)
+			if !strings.Contains(got, want) && !strings.Contains(want, got) {
+				continue
+			}
+		}
+		diff := 0
+		if e.Duration > 0 {
+			diff = max(c.Duration-e.Duration, e.Duration-c.Duration)
+		} else if best != "" && want == "" {
+			// same title by unknown artists, too ambiguous to pick one
+			return "", nil
+		}
+		if bestDiff < 0 || diff < bestDiff {
+			best, bestDiff = c.ID, diff
+		}
+	}
+	return best, nil
+}
+
+// normalize lower-cases s and drops everything but letters and digits.
+func normalize(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func escapeLike(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(v)
+}
+
+// Location returns a function giving the path of a song as written in an exported playlist.
+// Paths are absolute unless relative is set, in which case they are relative to baseDir, or
+// to the music folder of the song when baseDir is empty.
+func Location(folders []models.MusicFolder, relative bool, baseDir string) func(models.Child) string {
+	roots := make(map[uint]string, len(folders))
+	for _, f := range folders {
+		roots[f.ID] = f.Path
+	}
+	return func(song models.Child) string {
+		if !relative {
+			return song.Path
+		}
+		base := baseDir
+		if base == "" {
+			base = roots[song.MusicFolderID]
+		}
+		rel, err := filepath.Rel(base, song.Path)
+		if err != nil {
+			return song.Path
+		}
+		return filepath.ToSlash(rel)
+	}
+}
@@ -0,0 +1,67 @@
+package playlists
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stkevintan/miko/models"
+	"gorm.io/gorm"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.PlaylistRecord{}, &models.PlaylistSong{}, &models.PlaylistActivity{}); err != nil {
+		t.Fatal(err)
+	}
+	return New(db)
+}
+
+func playlistSongs(t *testing.T, m *Manager, id uint) []string {
+	t.Helper()
+	var songs []string
+	if err := m.db.Model(&models.PlaylistSong{}).Where("playlist_id = ?", id).Order("position").Pluck("song_id", &songs).Error; err != nil {
+		t.Fatal(err)
+	}
+	return songs
+}
+
+func TestMirror(t *testing.T) {
+	m := newTestManager(t)
+
+	id, added, removed, err := m.Mirror(0, "alice", "Mix", []string{"a", "b"})
+	if err != nil || id == 0 || added != 2 || removed != 0 {
+		t.Fatalf("Mirror() of a new playlist = %d, +%d, -%d, %v", id, added, removed, err)
+	}
+
+	// reordering adds and removes nothing
+	if _, added, removed, err := m.Mirror(id, "alice", "Mix", []string{"b", "c", "a"}); err != nil || added != 1 || removed != 0 {
+		t.Errorf("Mirror() = +%d, -%d, %v, want one song added", added, removed, err)
+	}
+	if songs := playlistSongs(t, m, id); !slices.Equal(songs, []string{"b", "c", "a"}) {
+		t.Errorf("songs = %v, want the remote order", songs)
+	}
+
+	if _, added, removed, err := m.Mirror(id, "alice", "Mix", []string{"c"}); err != nil || added != 0 || removed != 2 {
+		t.Errorf("Mirror() = +%d, -%d, %v, want two songs removed", added, removed, err)
+	}
+	var actions []string
+	m.db.Model(&models.PlaylistActivity{}).Where("playlist_id = ?", id).Order("id").Pluck("action", &actions)
+	if len(actions) != 5 {
+		t.Errorf("activity = %v, want the 5 songs added and removed", actions)
+	}
+
+	// the playlist of another user is left alone
+	other, _, _, err := m.Mirror(id, "bob", "Mix", []string{"d"})
+	if err != nil || other == id {
+		t.Fatalf("Mirror() for another user = %d, %v, want a new playlist", other, err)
+	}
+	if songs := playlistSongs(t, m, id); !slices.Equal(songs, []string{"c"}) {
+		t.Errorf("songs = %v, want them unchanged", songs)
+	}
+}
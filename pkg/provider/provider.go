@@ -25,3 +25,12 @@ type Provider interface {
 
 	Close(ctx context.Context) error
 }
+
+// ArtistProvider is implemented by providers that know about artists.
+type ArtistProvider interface {
+	// ArtistTopSongs returns up to count of the most popular songs of the artist named name
+	ArtistTopSongs(ctx context.Context, name string, count int) ([]*types.Music, error)
+
+	// ArtistInfo returns the description, picture and similar artists of the artist named name
+	ArtistInfo(ctx context.Context, name string) (*types.ArtistInfo, error)
+}
@@ -0,0 +1,156 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/log"
+	"github.com/stkevintan/miko/pkg/shared"
+	"github.com/stkevintan/miko/pkg/types"
+)
+
+// ErrScanInProgress is returned when a scan starts while another one is running.
+var ErrScanInProgress = errors.New("scan already in progress")
+
+// ScanFiles indexes the files along with the directories leading to them from their music
+// folder, and returns the IDs of the songs by file. Files outside of the music folders are
+// skipped. A running scan is waited for.
+func (s *Scanner) ScanFiles(ctx context.Context, files ...string) (map[string]string, error) {
+	var folders []models.MusicFolder
+	if err := s.db.Find(&folders).Error; err != nil {
+		return nil, err
+	}
+
+	var tasks []shared.WalkTask
+	queued := make(map[string]bool)
+	ids := make(map[string]string)
+	for _, file := range files {
+		p, folder, ok := locate(folders, file)
+		if !ok {
+			log.Debug("%q is not in a music folder, not indexing it", file)
+			continue
+		}
+		var dirs []string
+		for dir := path.Dir(p); len(dir) >= len(folder.Path); dir = path.Dir(dir) {
+			dirs = append(dirs, dir)
+			if dir == folder.Path {
+				break
+			}
+		}
+		for i := len(dirs) - 1; i >= 0; i-- {
+			if task, ok := walkTask(dirs[i], folder, queued); ok {
+				tasks = append(tasks, task)
+			}
+		}
+		if task, ok := walkTask(p, folder, queued); ok {
+			tasks = append(tasks, task)
+			ids[file] = GenerateID(p, folder)
+		}
+	}
+	if len(tasks) == 0 {
+		return ids, nil
+	}
+
+	for {
+		taskChan := make(chan shared.WalkTask, len(tasks))
+		for _, task := range tasks {
+			taskChan <- task
+		}
+		close(taskChan)
+
+		seenIDs, err := s.Scan(ctx, false, taskChan)
+		if errors.Is(err, ErrScanInProgress) {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Second):
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		for file, id := range ids {
+			if _, ok := seenIDs.Load(id); !ok {
+				delete(ids, file)
+			}
+		}
+		return ids, nil
+	}
+}
+
+// IndexDownloads indexes the files of the successful downloads and sets their song IDs.
+func (s *Scanner) IndexDownloads(ctx context.Context, results []*types.DownloadResult) {
+	var files []string
+	for _, r := range results {
+		if r != nil && r.Err == nil && r.Data != nil && r.Data.FilePath != "" {
+			files = append(files, r.Data.FilePath)
+		}
+	}
+	if len(files) == 0 {
+		return
+	}
+	ids, err := s.ScanFiles(ctx, files...)
+	if err != nil {
+		log.Warn("Failed to index downloaded files: %v", err)
+		return
+	}
+	for _, r := range results {
+		if r != nil && r.Err == nil && r.Data != nil {
+			r.Data.SongID = ids[r.Data.FilePath]
+		}
+	}
+}
+
+// walkTask returns the task scanning p unless it is queued already or missing.
+func walkTask(p string, folder models.MusicFolder, queued map[string]bool) (shared.WalkTask, bool) {
+	if queued[p] {
+		return shared.WalkTask{}, false
+	}
+	info, err := os.Stat(filepath.FromSlash(p))
+	if err != nil {
+		log.Warn("Failed to index %q: %v", p, err)
+		return shared.WalkTask{}, false
+	}
+	queued[p] = true
+	return shared.WalkTask{Path: p, D: fs.FileInfoToDirEntry(info), Folder: folder}, true
+}
+
+// locate returns the path of file in the form the scanner stores it and the music folder
+// containing it, the innermost one when folders are nested.
+func locate(folders []models.MusicFolder, file string) (string, models.MusicFolder, bool) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", models.MusicFolder{}, false
+	}
+	var (
+		found  models.MusicFolder
+		rel    string
+		ok     bool
+		parent = ".." + string(filepath.Separator)
+	)
+	for _, folder := range folders {
+		root, err := filepath.Abs(filepath.FromSlash(folder.Path))
+		if err != nil {
+			continue
+		}
+		r, err := filepath.Rel(root, abs)
+		if err != nil || r == ".." || strings.HasPrefix(r, parent) {
+			continue
+		}
+		if !ok || len(folder.Path) > len(found.Path) {
+			found, rel, ok = folder, r, true
+		}
+	}
+	if !ok {
+		return "", models.MusicFolder{}, false
+	}
+	return path.Join(found.Path, filepath.ToSlash(rel)), found, true
+}
@@ -0,0 +1,30 @@
+package scanner
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stkevintan/miko/models"
+)
+
+func TestLocate(t *testing.T) {
+	root := filepath.ToSlash(t.TempDir())
+	folders := []models.MusicFolder{
+		{ID: 1, Path: root},
+		{ID: 2, Path: root + "/audiobooks"},
+	}
+
+	p, folder, ok := locate(folders, filepath.FromSlash(root+"/A/2020 - B/01-01 C.flac"))
+	if !ok || folder.ID != 1 || p != root+"/A/2020 - B/01-01 C.flac" {
+		t.Errorf("locate() = %q, %d, %v", p, folder.ID, ok)
+	}
+
+	p, folder, ok = locate(folders, filepath.FromSlash(root+"/audiobooks/x/../D.mp3"))
+	if !ok || folder.ID != 2 || p != root+"/audiobooks/D.mp3" {
+		t.Errorf("locate() nested = %q, %d, %v", p, folder.ID, ok)
+	}
+
+	if _, _, ok := locate(folders, filepath.Join(filepath.Dir(root), "elsewhere.mp3")); ok {
+		t.Error("locate() found a folder for a file outside of the music folders")
+	}
+}
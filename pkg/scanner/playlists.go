@@ -0,0 +1,70 @@
+package scanner
+
+import (
+	"os"
+	"time"
+
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/log"
+	"github.com/stkevintan/miko/pkg/playlists"
+)
+
+type playlistFile struct {
+	path    string
+	modTime time.Time
+}
+
+// syncPlaylists imports the playlist files found by a scan as playlists of the first admin.
+// Incremental scans skip files that didn't change since they were last synced, unless the scan
+// saved songs they may refer to or their last sync left entries without a song.
+func (s *Scanner) syncPlaylists(files []playlistFile, incremental bool) {
+	if len(files) == 0 {
+		return
+	}
+
+	var owners []string
+	if err := s.db.Model(&models.User{}).Where("admin_role = ?", true).Order("created_at").Limit(1).Pluck("username", &owners).Error; err != nil || len(owners) == 0 {
+		log.Warn("Skipping playlist sync, no admin user to own the playlists: %v", err)
+		return
+	}
+
+	synced := make(map[string]time.Time)
+	if incremental && s.scanCount.Load() == 0 {
+		var records []models.PlaylistRecord
+		if err := s.db.Select("path, updated_at").Where("path <> '' AND unmatched = 0").Find(&records).Error; err != nil {
+			log.Warn("Failed to load synced playlists: %v", err)
+		}
+		for _, r := range records {
+			synced[r.Path] = r.UpdatedAt
+		}
+	}
+
+	m := playlists.New(s.db)
+	for _, f := range files {
+		if last, ok := synced[f.path]; ok && !f.modTime.After(last) {
+			continue
+		}
+		if err := m.Sync(f.path, owners[0]); err != nil {
+			log.Warn("Failed to sync playlist %q: %v", f.path, err)
+		}
+	}
+}
+
+// prunePlaylists deletes the playlists synced from files that no longer exist.
+func (s *Scanner) prunePlaylists() {
+	var records []models.PlaylistRecord
+	if err := s.db.Select("id, path").Where("path <> ''").Find(&records).Error; err != nil {
+		log.Warn("Failed to load synced playlists: %v", err)
+		return
+	}
+	for _, r := range records {
+		if _, err := os.Stat(r.Path); !os.IsNotExist(err) {
+			continue
+		}
+		if err := playlists.Delete(s.db, GetCoverCacheDir(s.cfg), &r); err != nil {
+			log.Warn("Failed to delete playlist of removed file %q: %v", r.Path, err)
+		} else {
+			log.Info("Deleted playlist of removed file %q", r.Path)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stkevintan/miko/config"
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/playlists"
+	"gorm.io/gorm"
+)
+
+func TestSyncPlaylists(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Child{}, &models.PlaylistRecord{}, &models.PlaylistSong{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&models.User{Username: "admin", SubsonicSettings: models.SubsonicSettings{AdminRole: true}})
+	s := New(db, &config.Config{Subsonic: &config.SubsonicConfig{DataDir: t.TempDir()}})
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mix.m3u")
+	if err := os.WriteFile(path, []byte("a.mp3\nb.mp3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(path)
+	files := []playlistFile{{path: path, modTime: info.ModTime()}}
+	db.Create(&models.Child{ID: "a", Path: filepath.Join(dir, "a.mp3")})
+
+	songs := func() []string {
+		var p models.PlaylistRecord
+		db.Where("path = ?", path).First(&p)
+		var ids []string
+		db.Model(&models.PlaylistSong{}).Where("playlist_id = ?", p.ID).Order("position").Pluck("song_id", &ids)
+		return ids
+	}
+
+	s.syncPlaylists(files, false)
+	if got := songs(); !slices.Equal(got, []string{"a"}) {
+		t.Fatalf("songs = %v, want a", got)
+	}
+
+	// the missing song shows up in a scan of another folder
+	db.Create(&models.Child{ID: "b", Path: filepath.Join(dir, "b.mp3")})
+	s.syncPlaylists(files, true)
+	if got := songs(); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("songs = %v, want the unmatched entry resolved", got)
+	}
+
+	// a resolved playlist is skipped until the scan saves songs
+	db.Where("1 = 1").Delete(&models.PlaylistSong{})
+	s.syncPlaylists(files, true)
+	if got := songs(); len(got) != 0 {
+		t.Errorf("songs = %v, want the unchanged playlist skipped", got)
+	}
+	s.scanCount.Store(1)
+	s.syncPlaylists(files, true)
+	if got := songs(); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("songs = %v, want the playlist resolved again", got)
+	}
+}
+
+func TestPrunePlaylists(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AutoMigrate(&models.PlaylistRecord{}, &models.PlaylistSong{}, &models.PlaylistCollaborator{}, &models.PlaylistActivity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(db, &config.Config{Subsonic: &config.SubsonicConfig{DataDir: t.TempDir()}})
+
+	removed := models.PlaylistRecord{Owner: "admin", Path: filepath.Join(t.TempDir(), "gone.m3u"),
+		Songs:         []models.PlaylistSong{{SongID: "a"}},
+		Collaborators: []models.PlaylistCollaborator{{Username: "bob", Permission: "edit"}},
+	}
+	db.Create(&removed)
+	db.Create(&models.PlaylistActivity{PlaylistID: removed.ID, Username: "admin", Action: models.PlaylistActionAdd, SongID: "a"})
+	cacheDir := GetCoverCacheDir(s.cfg)
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cover := filepath.Join(cacheDir, playlists.CoverArtID(removed.ID)+"-custom")
+	if err := os.WriteFile(cover, []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s.prunePlaylists()
+	for _, model := range []any{&models.PlaylistRecord{}, &models.PlaylistSong{}, &models.PlaylistCollaborator{}, &models.PlaylistActivity{}} {
+		var n int64
+		db.Model(model).Count(&n)
+		if n != 0 {
+			t.Errorf("%d rows of %T left after pruning", n, model)
+		}
+	}
+	if _, err := os.Stat(cover); !os.IsNotExist(err) {
+		t.Errorf("cover of the pruned playlist left: %v", err)
+	}
+}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/stkevintan/miko/models"
 	"github.com/stkevintan/miko/pkg/log"
+	"github.com/stkevintan/miko/pkg/playlists"
+	"github.com/stkevintan/miko/pkg/search"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -62,6 +64,12 @@ func (s *Scanner) Prune(seenIDs *sync.Map) {
 		if err := tx.Exec(`DELETE FROM album_artists WHERE album_id3_id NOT IN (SELECT id FROM album_id3)`).Error; err != nil {
 			return err
 		}
+		if err := tx.Exec(`DELETE FROM song_contributors WHERE child_id NOT IN (SELECT id FROM children)`).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`DELETE FROM chapters WHERE child_id NOT IN (SELECT id FROM children)`).Error; err != nil {
+			return err
+		}
 		if err := tx.Exec(`DELETE FROM song_genres WHERE child_id NOT IN (SELECT id FROM children)`).Error; err != nil {
 			return err
 		}
@@ -80,6 +88,7 @@ func (s *Scanner) Prune(seenIDs *sync.Map) {
 			AND NOT EXISTS (SELECT 1 FROM album_id3 WHERE album_id3.artist_id = artist_id3.id)
 			AND NOT EXISTS (SELECT 1 FROM song_artists WHERE song_artists.artist_id3_id = artist_id3.id)
 			AND NOT EXISTS (SELECT 1 FROM album_artists WHERE album_artists.artist_id3_id = artist_id3.id)
+			AND NOT EXISTS (SELECT 1 FROM song_contributors WHERE song_contributors.artist_id = artist_id3.id)
 		`)
 		if result.Error != nil {
 			return result.Error
@@ -88,6 +97,10 @@ func (s *Scanner) Prune(seenIDs *sync.Map) {
 			log.Info("Pruned %d orphaned artists", result.RowsAffected)
 		}
 
+		if err := search.Prune(tx); err != nil {
+			return err
+		}
+
 		// 7. Prune orphaned genres
 		result = tx.Exec(`
 			DELETE FROM genres 
@@ -110,6 +123,9 @@ func (s *Scanner) Prune(seenIDs *sync.Map) {
 
 	// 8. Prune unreferenced cover art files
 	s.pruneCoverArtCache()
+
+	// 9. Prune playlists synced from deleted playlist files
+	s.prunePlaylists()
 }
 
 func (s *Scanner) pruneCoverArtCache() {
@@ -140,12 +156,26 @@ func (s *Scanner) pruneCoverArtCache() {
 	collectCovers(&models.AlbumID3{})
 	collectCovers(&models.ArtistID3{})
 
+	var playlistIDs []uint
+	if err := s.db.Model(&models.PlaylistRecord{}).Pluck("id", &playlistIDs).Error; err != nil {
+		log.Warn("Failed to pluck playlist ids: %v", err)
+		// keep every playlist cover rather than deleting covers of existing playlists
+		return
+	}
+	playlistExists := make(map[uint]bool, len(playlistIDs))
+	for _, id := range playlistIDs {
+		playlistExists[id] = true
+	}
+
 	prunedCount := 0
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
 		}
 		name := entry.Name()
+		if id, ok := playlists.ParseCoverArtID(name); ok && playlistExists[id] {
+			continue
+		}
 		if !referencedCovers[name] {
 			if err := os.Remove(filepath.Join(cacheDir, name)); err != nil {
 				log.Warn("Failed to remove unreferenced cover art %q: %v", name, err)
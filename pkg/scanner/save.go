@@ -1,13 +1,18 @@
 package scanner
 
 import (
+	"cmp"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/collation"
 	"github.com/stkevintan/miko/pkg/log"
+	"github.com/stkevintan/miko/pkg/search"
 	"github.com/stkevintan/miko/pkg/tags"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -19,22 +24,34 @@ type imageTask struct {
 }
 
 type worker struct {
-	seenArtists map[string]bool
-	seenGenres  map[string]bool
-	seenAlbums  map[string]bool
-	imageTasks  chan imageTask
-	cacheDir    string
-	db          *gorm.DB
+	// seenArtists tells the artists saved by the scan whether they were saved with a sort name
+	seenArtists    map[string]bool
+	seenGenres     map[string]bool
+	albums         map[string]*models.AlbumID3
+	imageTasks     chan imageTask
+	cacheDir       string
+	articles       []string
+	variousArtists string
+	contributors   []models.SongContributor
+	chapters       []models.Chapter
+	searchEntries  []search.Entry
+	db             *gorm.DB
+	// partial is set when the scan may miss tracks of an album, its aggregates are then merged
+	// with the stored ones rather than rebuilt
+	partial bool
 }
 
-func (s *Scanner) saveResults(resultChan <-chan scanResult, cacheDir string) {
+func (s *Scanner) saveResults(resultChan <-chan scanResult, cacheDir string, partial bool) {
 	w := &worker{
-		seenArtists: make(map[string]bool),
-		seenGenres:  make(map[string]bool),
-		seenAlbums:  make(map[string]bool),
-		imageTasks:  make(chan imageTask, s.numWorkers*10),
-		cacheDir:    cacheDir,
-		db:          s.db,
+		seenArtists:    make(map[string]bool),
+		seenGenres:     make(map[string]bool),
+		albums:         make(map[string]*models.AlbumID3),
+		imageTasks:     make(chan imageTask, s.numWorkers*10),
+		cacheDir:       cacheDir,
+		articles:       strings.Fields(s.cfg.Subsonic.IgnoredArticles),
+		variousArtists: s.cfg.Subsonic.VariousArtists,
+		partial:        partial,
+		db:             s.db,
 	}
 
 	var imageWg sync.WaitGroup
@@ -44,6 +61,9 @@ func (s *Scanner) saveResults(resultChan <-chan scanResult, cacheDir string) {
 	flushChildren := func() {
 		if len(children) > 0 {
 			s.db.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(children, 100)
+			w.flushContributors(children)
+			w.flushChapters(children)
+			w.indexSongs(children)
 			children = children[:0]
 		}
 	}
@@ -53,6 +73,8 @@ func (s *Scanner) saveResults(resultChan <-chan scanResult, cacheDir string) {
 		if res.tags != nil {
 			w.processMetadata(child, res.tags, res.path)
 		}
+		child.OrderName = collation.SortKey(cmp.Or(child.SortName, child.Title))
+		w.addChapters(child, res.chapters)
 
 		children = append(children, *child)
 		if len(children) >= 100 {
@@ -64,10 +86,118 @@ func (s *Scanner) saveResults(resultChan <-chan scanResult, cacheDir string) {
 	}
 
 	flushChildren()
+	w.flushAlbums()
 	close(w.imageTasks)
 	imageWg.Wait()
 }
 
+// flushAlbums persists the albums collected during the scan. Albums are written once all
+// their tracks have been seen so that per-disc and per-release data is complete.
+func (w *worker) flushAlbums() {
+	for _, album := range w.albums {
+		if err := w.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(album).Error; err != nil {
+			log.Warn("Failed to save album %q: %v", album.Name, err)
+		}
+		w.searchEntries = append(w.searchEntries, search.Entry{
+			Kind:   search.KindAlbum,
+			ID:     album.ID,
+			Title:  album.Name,
+			Artist: album.Artist,
+		})
+	}
+	if err := search.Index(w.db, w.searchEntries); err != nil {
+		log.Warn("Failed to index albums and artists: %v", err)
+	}
+	w.searchEntries = nil
+}
+
+func (w *worker) indexSongs(children []models.Child) {
+	entries := make([]search.Entry, 0, len(children))
+	for _, child := range children {
+		if child.IsDir {
+			continue
+		}
+		entries = append(entries, search.Entry{
+			Kind:   search.KindSong,
+			ID:     child.ID,
+			Title:  child.Title,
+			Album:  child.Album,
+			Artist: child.Artist,
+			Extra:  strings.Join(append([]string{child.DisplayComposer, child.Label, child.Comment}, child.ISRC...), " "),
+			Lyrics: child.Lyrics,
+			Path:   child.Path,
+		})
+	}
+	if err := search.Index(w.db, entries); err != nil {
+		log.Warn("Failed to index songs: %v", err)
+	}
+}
+
+// flushContributors replaces the contributor credits of the given songs with the ones read
+// from their tags.
+func (w *worker) flushContributors(children []models.Child) {
+	ids := make([]string, 0, len(children))
+	for _, child := range children {
+		if !child.IsDir {
+			ids = append(ids, child.ID)
+		}
+	}
+	if len(ids) > 0 {
+		if err := w.db.Where("child_id IN ?", ids).Delete(&models.SongContributor{}).Error; err != nil {
+			log.Warn("Failed to clear song contributors: %v", err)
+		}
+	}
+	if len(w.contributors) > 0 {
+		if err := w.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(w.contributors, 100).Error; err != nil {
+			log.Warn("Failed to save song contributors: %v", err)
+		}
+		w.contributors = w.contributors[:0]
+	}
+}
+
+// addChapters queues the chapters of a song. Chapters without an end last until the next
+// chapter starts, the last one until the end of the song.
+func (w *worker) addChapters(child *models.Child, chapters []tags.Chapter) {
+	for i, c := range chapters {
+		end := c.End
+		if end <= c.Start {
+			if i+1 < len(chapters) {
+				end = chapters[i+1].Start
+			} else {
+				end = time.Duration(child.Duration) * time.Second
+			}
+		}
+		w.chapters = append(w.chapters, models.Chapter{
+			ChildID: child.ID,
+			Number:  i + 1,
+			Title:   c.Title,
+			Start:   c.Start.Milliseconds(),
+			End:     end.Milliseconds(),
+		})
+	}
+}
+
+// flushChapters replaces the chapters of the given songs with the ones read from the files.
+func (w *worker) flushChapters(children []models.Child) {
+	ids := make([]string, 0, len(children))
+	for _, child := range children {
+		if !child.IsDir {
+			ids = append(ids, child.ID)
+		}
+	}
+	if len(ids) > 0 {
+		if err := w.db.Where("child_id IN ?", ids).Delete(&models.Chapter{}).Error; err != nil {
+			log.Warn("Failed to clear chapters: %v", err)
+		}
+	}
+	if len(w.chapters) > 0 {
+		if err := w.db.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(w.chapters, 100).Error; err != nil {
+			log.Warn("Failed to save chapters: %v", err)
+		}
+		w.chapters = w.chapters[:0]
+	}
+}
+
 func (s *Scanner) SaveCoverArt(coverArt string, data []byte) error {
 	if coverArt == "" {
 		return nil
@@ -110,9 +240,10 @@ func (w *worker) processMetadata(child *models.Child, t *tags.Tags, path string)
 	if t.Title != "" {
 		child.Title = t.Title
 	}
+	child.SortName = t.TitleSort
 	if t.Artist != "" {
 		child.Artist = t.Artist
-		child.Artists = w.getArtistsFromNames(t.Artists)
+		child.Artists = w.getArtistsFromNames(t.Artists, t.ArtistSorts)
 		if len(child.Artists) > 0 {
 			child.ArtistID = child.Artists[0].ID
 		}
@@ -123,6 +254,9 @@ func (w *worker) processMetadata(child *models.Child, t *tags.Tags, path string)
 	child.Track = t.Track
 	child.DiscNumber = t.Disc
 	child.Year = t.Year
+	child.ReleaseDate = toItemDate(t.ReleaseDate)
+	child.OriginalDate = toItemDate(t.OriginalDate)
+	child.OriginalYear = t.OriginalDate.Year
 	if t.Genre != "" {
 		child.Genre = t.Genre
 		child.Genres = w.getGenresFromNames(t.Genres)
@@ -130,6 +264,20 @@ func (w *worker) processMetadata(child *models.Child, t *tags.Tags, path string)
 	if t.Lyrics != "" {
 		child.Lyrics = t.Lyrics
 	}
+	child.DisplayComposer = t.Composer
+	child.Label = t.Label
+	child.ISRC = t.ISRC
+	child.NeteaseID = t.NeteaseID
+	child.Comment = t.Comment
+	for _, c := range t.Contributors {
+		artists := w.getArtistsFromNames([]string{c.Name}, nil)
+		w.contributors = append(w.contributors, models.SongContributor{
+			ChildID:  child.ID,
+			ArtistID: artists[0].ID,
+			Role:     c.Role,
+			SubRole:  c.SubRole,
+		})
+	}
 	child.Duration = t.Duration
 	child.BitRate = t.Bitrate
 
@@ -147,7 +295,12 @@ func (w *worker) handleAlbum(child *models.Child, t *tags.Tags, path string) {
 	albumArtistStr := t.AlbumArtist
 	var albumArtists []models.ArtistID3
 	if albumArtistStr != "" {
-		albumArtists = w.getArtistsFromNames(t.AlbumArtists)
+		albumArtists = w.getArtistsFromNames(t.AlbumArtists, t.AlbumArtistSorts)
+	} else if t.Compilation {
+		// Compilations without an album artist are grouped under a shared artist
+		// instead of being split into one album per track artist
+		albumArtistStr = w.variousArtists
+		albumArtists = w.getArtistsFromNames([]string{albumArtistStr}, nil)
 	}
 
 	groupArtist := child.Artist
@@ -162,30 +315,68 @@ func (w *worker) handleAlbum(child *models.Child, t *tags.Tags, path string) {
 		displayArtist = "Unknown Artist"
 	}
 
+	// A MusicBrainz release ID identifies the album regardless of how the artist tags are spelled
 	albumID := GenerateAlbumID(displayArtist, child.Album)
+	if t.MBAlbumID != "" {
+		albumID = GenerateAlbumIDByMBID(t.MBAlbumID)
+	}
 	child.AlbumID = albumID
 	child.CoverArt = "al-" + albumID
 
-	// Create the album DB entry only once
-	if !w.seenAlbums[albumID] {
+	album, ok := w.albums[albumID]
+	if !ok {
 		created := time.Now()
 		if child.Created != nil {
 			created = *child.Created
 		}
-		album := models.AlbumID3{
-			ID:       albumID,
-			Name:     child.Album,
-			Artist:   displayArtist,
-			Created:  created,
-			CoverArt: child.CoverArt,
+		album = &models.AlbumID3{
+			ID:            albumID,
+			Name:          child.Album,
+			SortName:      t.AlbumSort,
+			OrderName:     collation.SortKey(collation.StripArticles(cmp.Or(t.AlbumSort, child.Album), w.articles)),
+			Artist:        displayArtist,
+			Created:       created,
+			CoverArt:      child.CoverArt,
+			MusicBrainzID: t.MBAlbumID,
 		}
 		if len(groupArtists) > 0 {
 			album.ArtistID = groupArtists[0].ID
 			album.Artists = groupArtists
 		}
 
-		w.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&album)
-		w.seenAlbums[albumID] = true
+		// Keep disc titles of discs that are not part of this (possibly incremental) scan, and
+		// the dates, discs and release types of tracks that are not when the scan is partial
+		var existing models.AlbumID3
+		if err := w.db.Where("id = ?", albumID).Limit(1).Find(&existing).Error; err == nil && existing.ID != "" {
+			album.DiscTitles = existing.DiscTitles
+			if w.partial {
+				mergeAlbum(album, &existing)
+			}
+		}
+		// Save right away so songs never reference a missing album, flushAlbums stores the merged data
+		w.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(album)
+		w.albums[albumID] = album
+	}
+
+	// Album dates come from its tracks, the earliest known date wins
+	album.ReleaseDate = earliestDate(album.ReleaseDate, child.ReleaseDate)
+	album.OriginalDate = earliestDate(album.OriginalDate, child.OriginalDate)
+	if album.ReleaseDate != nil {
+		album.Year = album.ReleaseDate.Year
+	}
+	if album.OriginalDate != nil {
+		album.OriginalYear = album.OriginalDate.Year
+	}
+
+	album.IsCompilation = album.IsCompilation || t.Compilation
+	album.DiscCount = max(album.DiscCount, t.DiscTotal, t.Disc)
+	for _, releaseType := range t.ReleaseTypes {
+		if releaseType != "" && !slices.Contains(album.ReleaseTypes, releaseType) {
+			album.ReleaseTypes = append(album.ReleaseTypes, releaseType)
+		}
+	}
+	if t.DiscSubtitle != "" {
+		setDiscTitle(album, max(t.Disc, 1), t.DiscSubtitle)
 	}
 
 	// Always queue an imageTask to attempt to cache cover art from this song.
@@ -194,18 +385,104 @@ func (w *worker) handleAlbum(child *models.Child, t *tags.Tags, path string) {
 	w.imageTasks <- imageTask{path: path, coverArt: child.CoverArt}
 }
 
-func (w *worker) getArtistsFromNames(names []string) []models.ArtistID3 {
+// mergeAlbum folds the aggregates of the stored album into album, which is then completed with
+// the tracks of the scan.
+func mergeAlbum(album, existing *models.AlbumID3) {
+	if !existing.Created.IsZero() && existing.Created.Before(album.Created) {
+		album.Created = existing.Created
+	}
+	album.ReleaseDate = existing.ReleaseDate
+	album.OriginalDate = existing.OriginalDate
+	album.Year = existing.Year
+	album.OriginalYear = existing.OriginalYear
+	album.IsCompilation = existing.IsCompilation
+	album.DiscCount = existing.DiscCount
+	album.ReleaseTypes = existing.ReleaseTypes
+}
+
+func toItemDate(d tags.PartialDate) *models.ItemDate {
+	if d.IsZero() {
+		return nil
+	}
+	return &models.ItemDate{Year: d.Year, Month: d.Month, Day: d.Day}
+}
+
+// earliestDate returns the earlier of two dates. Unknown months and days sort after known ones
+// within the same year, so a more precise date is preferred over a bare year.
+func earliestDate(a, b *models.ItemDate) *models.ItemDate {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	key := func(d *models.ItemDate) int {
+		month, day := d.Month, d.Day
+		if month == 0 {
+			month = 13
+		}
+		if day == 0 {
+			day = 32
+		}
+		return d.Year*10000 + month*100 + day
+	}
+	if key(b) < key(a) {
+		return b
+	}
+	return a
+}
+
+func setDiscTitle(album *models.AlbumID3, disc int, title string) {
+	for i := range album.DiscTitles {
+		if album.DiscTitles[i].Disc == disc {
+			album.DiscTitles[i].Title = title
+			return
+		}
+	}
+	album.DiscTitles = append(album.DiscTitles, models.DiscTitle{Disc: disc, Title: title})
+	slices.SortFunc(album.DiscTitles, func(a, b models.DiscTitle) int {
+		return a.Disc - b.Disc
+	})
+}
+
+// artistUpsert saves an artist keeping the stored sort name, and the order derived from it,
+// when the artist is credited without a sort tag.
+var artistUpsert = clause.OnConflict{
+	Columns: []clause.Column{{Name: "id"}},
+	DoUpdates: clause.Assignments(map[string]any{
+		"name":       gorm.Expr("excluded.name"),
+		"cover_art":  gorm.Expr("excluded.cover_art"),
+		"sort_name":  gorm.Expr("COALESCE(NULLIF(excluded.sort_name, ''), artist_id3.sort_name)"),
+		"order_name": gorm.Expr("CASE WHEN excluded.sort_name <> '' OR COALESCE(artist_id3.sort_name, '') = '' THEN excluded.order_name ELSE artist_id3.order_name END"),
+	}),
+}
+
+// getArtistsFromNames resolves artist names to artist records. sortNames are matched to names by
+// position and fall back to the name itself when missing.
+func (w *worker) getArtistsFromNames(names []string, sortNames []string) []models.ArtistID3 {
 	var artists []models.ArtistID3
-	for _, name := range names {
+	for i, name := range names {
 		artistID := GenerateArtistID(name)
+		var sortName string
+		if len(sortNames) == len(names) {
+			sortName = sortNames[i]
+		}
 		artist := models.ArtistID3{
-			ID:       artistID,
-			Name:     name,
-			CoverArt: "ar-" + artistID,
+			ID:        artistID,
+			Name:      name,
+			SortName:  sortName,
+			OrderName: collation.SortKey(collation.StripArticles(cmp.Or(sortName, name), w.articles)),
+			CoverArt:  "ar-" + artistID,
+		}
+		// credits without a sort tag, such as contributors, come before or after the tagged
+		// ones: an artist is saved again once a sort tag is seen
+		sorted, seen := w.seenArtists[artistID]
+		if !seen || (!sorted && sortName != "") {
+			w.db.Clauses(artistUpsert).Create(&artist)
+			w.seenArtists[artistID] = sortName != ""
 		}
-		if !w.seenArtists[artistID] {
-			w.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&artist)
-			w.seenArtists[artistID] = true
+		if !seen {
+			w.searchEntries = append(w.searchEntries, search.Entry{Kind: search.KindArtist, ID: artistID, Title: name})
 		}
 		artists = append(artists, artist)
 	}
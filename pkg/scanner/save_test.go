@@ -0,0 +1,98 @@
+package scanner
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/collation"
+	"github.com/stkevintan/miko/pkg/tags"
+	"gorm.io/gorm"
+)
+
+func newTestWorker(t *testing.T, partial bool) *worker {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.ArtistID3{}, &models.AlbumID3{}); err != nil {
+		t.Fatal(err)
+	}
+	return &worker{
+		seenArtists: make(map[string]bool),
+		albums:      make(map[string]*models.AlbumID3),
+		imageTasks:  make(chan imageTask, 10),
+		partial:     partial,
+		db:          db,
+	}
+}
+
+func TestHandleAlbum_Merge(t *testing.T) {
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	stored := models.AlbumID3{
+		ID:            GenerateAlbumID("X", "A"),
+		Name:          "A",
+		Created:       created,
+		Year:          2001,
+		ReleaseDate:   &models.ItemDate{Year: 2001},
+		IsCompilation: true,
+		DiscCount:     2,
+		ReleaseTypes:  []string{"album"},
+		DiscTitles:    []models.DiscTitle{{Disc: 2, Title: "Live"}},
+	}
+	track := &tags.Tags{Album: "A", AlbumArtist: "X", AlbumArtists: []string{"X"}, Disc: 1, ReleaseDate: tags.PartialDate{Year: 2005}, ReleaseTypes: []string{"single"}}
+
+	t.Run("partial", func(t *testing.T) {
+		w := newTestWorker(t, true)
+		w.db.Create(&stored)
+		now := time.Now()
+		w.handleAlbum(&models.Child{ID: "s", Album: "A", Created: &now, ReleaseDate: &models.ItemDate{Year: 2005}}, track, "s.mp3")
+
+		album := w.albums[stored.ID]
+		if album.Year != 2001 || album.DiscCount != 2 || !album.IsCompilation || !album.Created.Equal(created) {
+			t.Errorf("album = year %d, %d discs, compilation %v, created %v, want the stored aggregates", album.Year, album.DiscCount, album.IsCompilation, album.Created)
+		}
+		if !slices.Equal(album.ReleaseTypes, []string{"album", "single"}) || len(album.DiscTitles) != 1 {
+			t.Errorf("album = release types %v, disc titles %v, want both release types and the stored title", album.ReleaseTypes, album.DiscTitles)
+		}
+	})
+
+	t.Run("full", func(t *testing.T) {
+		w := newTestWorker(t, false)
+		w.db.Create(&stored)
+		w.handleAlbum(&models.Child{ID: "s", Album: "A", ReleaseDate: &models.ItemDate{Year: 2005}}, track, "s.mp3")
+
+		album := w.albums[stored.ID]
+		if album.Year != 2005 || album.DiscCount != 1 || album.IsCompilation || !slices.Equal(album.ReleaseTypes, []string{"single"}) {
+			t.Errorf("album = year %d, %d discs, compilation %v, types %v, want them rebuilt from the track", album.Year, album.DiscCount, album.IsCompilation, album.ReleaseTypes)
+		}
+	})
+}
+
+func TestGetArtistsFromNames_KeepsSortName(t *testing.T) {
+	w := newTestWorker(t, false)
+	stored := func() models.ArtistID3 {
+		var artist models.ArtistID3
+		w.db.First(&artist, "id = ?", GenerateArtistID("The Band"))
+		return artist
+	}
+
+	// a composer credit comes before the file tagged with the artist sort name
+	w.getArtistsFromNames([]string{"The Band"}, nil)
+	w.getArtistsFromNames([]string{"The Band"}, []string{"Band, The"})
+	w.getArtistsFromNames([]string{"The Band"}, nil)
+	if artist := stored(); artist.SortName != "Band, The" || artist.OrderName != collation.SortKey("Band, The") {
+		t.Errorf("artist sort name %q, order %q, want the tagged sort name", artist.SortName, artist.OrderName)
+	}
+
+	// the next scan credits the artist without a sort tag first
+	w = &worker{seenArtists: make(map[string]bool), db: w.db}
+	w.getArtistsFromNames([]string{"The Band"}, nil)
+	if artist := stored(); artist.SortName != "Band, The" || artist.OrderName != collation.SortKey("Band, The") {
+		t.Errorf("artist sort name %q, order %q after a rescan, want the tagged sort name", artist.SortName, artist.OrderName)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/stkevintan/miko/config"
 	"github.com/stkevintan/miko/models"
 	"github.com/stkevintan/miko/pkg/log"
+	"github.com/stkevintan/miko/pkg/playlists"
 	"github.com/stkevintan/miko/pkg/shared"
 	"github.com/stkevintan/miko/pkg/tags"
 	"gorm.io/gorm"
@@ -22,7 +23,9 @@ import (
 type Scanner struct {
 	db           *gorm.DB
 	cfg          *config.Config
+	mu           sync.Mutex // held by the running scan
 	isScanning   atomic.Bool
+	isFullScan   atomic.Bool
 	scanCount    atomic.Int64
 	lastScanTime atomic.Int64
 	numWorkers   int
@@ -39,13 +42,14 @@ func New(db *gorm.DB, cfg *config.Config) *Scanner {
 }
 
 type scanResult struct {
-	path  string
-	child *models.Child
-	tags  *tags.Tags
+	path     string
+	child    *models.Child
+	tags     *tags.Tags
+	chapters []tags.Chapter
 }
 
 func (s *Scanner) IsScanning() bool {
-	return s.isScanning.Load()
+	return s.isScanning.Load() || s.isFullScan.Load()
 }
 
 func (s *Scanner) ScanCount() int64 {
@@ -56,10 +60,15 @@ func (s *Scanner) LastScanTime() int64 {
 	return s.lastScanTime.Load()
 }
 
+// ScanAll scans every music folder and prunes what is gone. It does nothing while another full
+// scan runs, and waits for a running scan of a few files, such as downloads being indexed.
 func (s *Scanner) ScanAll(ctx context.Context, incremental bool) {
-	if s.IsScanning() {
+	if !s.isFullScan.CompareAndSwap(false, true) {
 		return
 	}
+	defer s.isFullScan.Store(false)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	taskChan, err := s.walker.WalkAllRoots(ctx)
 	if err != nil {
@@ -67,7 +76,8 @@ func (s *Scanner) ScanAll(ctx context.Context, incremental bool) {
 		return
 	}
 
-	seenIDs, err := s.Scan(ctx, incremental, taskChan)
+	// a full scan sees every track, so albums are rebuilt from their tracks
+	seenIDs, err := s.scan(ctx, incremental, incremental, taskChan)
 	if err != nil {
 		log.Warn("ScanAll failed: %v", err)
 	}
@@ -82,7 +92,7 @@ func (s *Scanner) ScanAll(ctx context.Context, incremental bool) {
 
 func (s *Scanner) ScanPath(ctx context.Context, id string) (*sync.Map, error) {
 	if s.IsScanning() {
-		return nil, fmt.Errorf("scan already in progress")
+		return nil, ErrScanInProgress
 	}
 
 	taskChan, err := s.walker.WalkByID(ctx, id)
@@ -94,10 +104,19 @@ func (s *Scanner) ScanPath(ctx context.Context, id string) (*sync.Map, error) {
 	return s.Scan(ctx, false, taskChan)
 }
 
+// Scan indexes the files of taskChan. Albums are merged with their stored data as the files
+// may be a part of them only.
 func (s *Scanner) Scan(ctx context.Context, incremental bool, taskChan <-chan shared.WalkTask) (*sync.Map, error) {
-	if !s.isScanning.CompareAndSwap(false, true) {
-		return nil, fmt.Errorf("scan already in progress")
+	if !s.mu.TryLock() {
+		return nil, ErrScanInProgress
 	}
+	defer s.mu.Unlock()
+	return s.scan(ctx, incremental, true, taskChan)
+}
+
+// scan runs a scan, s.mu must be held.
+func (s *Scanner) scan(ctx context.Context, incremental, partial bool, taskChan <-chan shared.WalkTask) (*sync.Map, error) {
+	s.isScanning.Store(true)
 	s.scanCount.Store(0)
 	defer s.isScanning.Store(false)
 
@@ -125,6 +144,8 @@ func (s *Scanner) Scan(ctx context.Context, incremental bool, taskChan <-chan sh
 
 	resultChan := make(chan scanResult, s.numWorkers*10)
 	var wg sync.WaitGroup
+	var playlistMu sync.Mutex
+	var playlistFiles []playlistFile
 
 	// Workers
 	for range s.numWorkers {
@@ -153,6 +174,15 @@ func (s *Scanner) Scan(ctx context.Context, incremental bool, taskChan <-chan sh
 					continue
 				}
 
+				if s.cfg.Subsonic.SyncPlaylists && playlists.IsPlaylistFile(task.Path) {
+					if info, err := task.D.Info(); err == nil {
+						playlistMu.Lock()
+						playlistFiles = append(playlistFiles, playlistFile{path: task.Path, modTime: info.ModTime()})
+						playlistMu.Unlock()
+					}
+					continue
+				}
+
 				// File processing
 				if !shared.IsAudioFile(task.Path) {
 					continue
@@ -187,17 +217,27 @@ func (s *Scanner) Scan(ctx context.Context, incremental bool, taskChan <-chan sh
 					ContentType:   contentType,
 					Created:       &modTime, // Corresponds to file modification time for incremental scans.
 					MusicFolderID: task.Folder.ID,
-					// TODO: Add audiobook support
-					Type: "music",
+					Type:          models.MediaTypeMusic,
 				}
 
 				t, err := tags.Read(task.Path)
-				if err != nil {
+				var genres []string
+				if err == nil {
+					genres = t.Genres
+				}
+				var chapters []tags.Chapter
+				if IsAudiobook(task.Path, genres, s.cfg.Subsonic.AudiobookFolders) {
+					child.Type = models.MediaTypeAudiobook
+					if chapters, err = tags.ReadChapters(task.Path); err != nil {
+						log.Warn("Failed to read chapters of %q: %v", task.Path, err)
+					}
+				}
+				if t == nil {
 					// Still add the child even if tags fail
-					resultChan <- scanResult{path: task.Path, child: child}
+					resultChan <- scanResult{path: task.Path, child: child, chapters: chapters}
 					continue
 				}
-				resultChan <- scanResult{path: task.Path, child: child, tags: t}
+				resultChan <- scanResult{path: task.Path, child: child, tags: t, chapters: chapters}
 			}
 		}()
 	}
@@ -206,12 +246,15 @@ func (s *Scanner) Scan(ctx context.Context, incremental bool, taskChan <-chan sh
 	doneSaver := make(chan struct{})
 	go func() {
 		defer close(doneSaver)
-		s.saveResults(resultChan, cacheDir)
+		s.saveResults(resultChan, cacheDir, partial)
 	}()
 
 	wg.Wait()
 	close(resultChan)
 	<-doneSaver
 
+	// playlists are synced once all their songs are saved
+	s.syncPlaylists(playlistFiles, incremental)
+
 	return seenIDs, nil
 }
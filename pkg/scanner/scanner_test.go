@@ -0,0 +1,58 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stkevintan/miko/config"
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/shared"
+	"gorm.io/gorm"
+)
+
+func TestScanAll_WaitsForScan(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.MusicFolder{}, &models.Child{}, &models.AlbumID3{}); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Subsonic: &config.SubsonicConfig{DataDir: t.TempDir()}}
+	s := New(db, cfg)
+
+	// a scan of a few files is running
+	s.mu.Lock()
+	done := make(chan struct{})
+	go func() {
+		s.ScanAll(context.Background(), false)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("ScanAll() returned while another scan was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if !s.IsScanning() {
+		t.Error("IsScanning() = false while a full scan is waiting")
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ScanAll() didn't run once the other scan was done")
+	}
+	if s.LastScanTime() == 0 {
+		t.Error("ScanAll() didn't scan")
+	}
+	tasks := make(chan shared.WalkTask)
+	close(tasks)
+	if _, err := s.Scan(context.Background(), false, tasks); errors.Is(err, ErrScanInProgress) {
+		t.Error("Scan() is still locked out after ScanAll()")
+	}
+}
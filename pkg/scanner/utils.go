@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"mime"
 	"path/filepath"
+	"strings"
 
 	"github.com/stkevintan/miko/config"
 	"github.com/stkevintan/miko/models"
@@ -13,6 +14,10 @@ import (
 
 func GetContentType(path string) string {
 	ext := filepath.Ext(path)
+	if strings.EqualFold(ext, ".m4b") {
+		// audiobooks are plain MP4 audio, but the extension is rarely registered
+		return "audio/mp4"
+	}
 	contentType := mime.TypeByExtension(ext)
 	if contentType == "" && len(ext) > 1 {
 		contentType = "audio/" + ext[1:]
@@ -20,6 +25,27 @@ func GetContentType(path string) string {
 	return contentType
 }
 
+// IsAudiobook reports whether a file is an audiobook: it lives in one of the configured audiobook
+// folders, has the .m4b extension or is tagged with the audiobook genre.
+func IsAudiobook(path string, genres []string, audiobookFolders []string) bool {
+	if strings.EqualFold(filepath.Ext(path), ".m4b") {
+		return true
+	}
+	for _, genre := range genres {
+		switch strings.ToLower(strings.TrimSpace(genre)) {
+		case "audiobook", "audiobooks", "audio book":
+			return true
+		}
+	}
+	for _, folder := range audiobookFolders {
+		rel, err := filepath.Rel(filepath.Clean(folder), path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
 func GetCoverCacheDir(cfg *config.Config) string {
 	return filepath.Join(cfg.Subsonic.DataDir, "cache", "covers")
 }
@@ -38,6 +64,10 @@ func GenerateAlbumID(artist, album string) string {
 	return fmt.Sprintf("%x", md5.Sum([]byte(artist+"|"+album)))
 }
 
+func GenerateAlbumIDByMBID(mbid string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte("mbz|"+mbid)))
+}
+
 func GenerateArtistID(name string) string {
 	return shared.GenerateHash(name)
 }
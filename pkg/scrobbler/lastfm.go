@@ -0,0 +1,139 @@
+package scrobbler
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Last.fm error codes worth retrying: service offline, temporarily unavailable and rate limit
+// exceeded.
+var lastFMTemporaryErrors = map[int]bool{11: true, 16: true, 29: true}
+
+// lastFM scrobbles to a Last.fm API compatible service with the session key of an account.
+type lastFM struct {
+	client     *resty.Client
+	apiKey     string
+	secret     string
+	sessionKey string
+}
+
+func newLastFM(baseURL, apiKey, secret, sessionKey string) *lastFM {
+	return &lastFM{
+		client: resty.New().
+			SetBaseURL(baseURL).
+			SetHeader("User-Agent", userAgent).
+			SetTimeout(requestTimeout),
+		apiKey:     apiKey,
+		secret:     secret,
+		sessionKey: sessionKey,
+	}
+}
+
+// lastFMSignature signs the parameters of a call as described by the Last.fm authentication
+// spec: the parameters sorted by name and concatenated, followed by the secret, md5 hashed.
+func lastFMSignature(params map[string]string, secret string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k != "format" && k != "callback" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteString(params[k])
+	}
+	b.WriteString(secret)
+	return fmt.Sprintf("%x", md5.Sum([]byte(b.String())))
+}
+
+// call invokes a signed write method and decodes the response into result when it is not nil.
+func (fm *lastFM) call(ctx context.Context, method string, params map[string]string, result any) error {
+	params["method"] = method
+	params["api_key"] = fm.apiKey
+	if fm.sessionKey != "" {
+		params["sk"] = fm.sessionKey
+	}
+	params["api_sig"] = lastFMSignature(params, fm.secret)
+	params["format"] = "json"
+
+	resp, err := fm.client.R().SetContext(ctx).SetFormData(params).Post("")
+	if err != nil {
+		return err
+	}
+	var failure struct {
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(resp.Body(), &failure); err == nil && failure.Error != 0 {
+		err := fmt.Errorf("last.fm %s: %s (%d)", method, failure.Message, failure.Error)
+		if lastFMTemporaryErrors[failure.Error] {
+			return err
+		}
+		return &PermanentError{Err: err}
+	}
+	if !resp.IsSuccess() {
+		return fmt.Errorf("last.fm %s: %s", method, resp.Status())
+	}
+	if result != nil {
+		return json.Unmarshal(resp.Body(), result)
+	}
+	return nil
+}
+
+// session logs in with the credentials of an account and returns its name and session key.
+func (fm *lastFM) session(ctx context.Context, username, password string) (string, string, error) {
+	var result struct {
+		Session struct {
+			Name string `json:"name"`
+			Key  string `json:"key"`
+		} `json:"session"`
+	}
+	err := fm.call(ctx, "auth.getMobileSession", map[string]string{
+		"username": username,
+		"password": password,
+	}, &result)
+	if err != nil {
+		return "", "", err
+	}
+	if result.Session.Key == "" {
+		return "", "", fmt.Errorf("last.fm auth.getMobileSession: no session key returned")
+	}
+	return result.Session.Name, result.Session.Key, nil
+}
+
+func (fm *lastFM) trackParams(track Track) map[string]string {
+	params := map[string]string{
+		"artist": track.Artist,
+		"track":  track.Title,
+	}
+	if track.Album != "" {
+		params["album"] = track.Album
+	}
+	if track.Duration > 0 {
+		params["duration"] = strconv.Itoa(track.Duration)
+	}
+	if track.TrackNumber > 0 {
+		params["trackNumber"] = strconv.Itoa(track.TrackNumber)
+	}
+	return params
+}
+
+func (fm *lastFM) nowPlaying(ctx context.Context, track Track) error {
+	return fm.call(ctx, "track.updateNowPlaying", fm.trackParams(track), nil)
+}
+
+func (fm *lastFM) scrobble(ctx context.Context, track Track, playedAt time.Time) error {
+	params := fm.trackParams(track)
+	params["timestamp"] = strconv.FormatInt(playedAt.Unix(), 10)
+	return fm.call(ctx, "track.scrobble", params, nil)
+}
@@ -0,0 +1,109 @@
+package scrobbler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// listenBrainz submits listens with the user token of a ListenBrainz account.
+type listenBrainz struct {
+	client *resty.Client
+}
+
+func newListenBrainz(baseURL, token string) *listenBrainz {
+	return &listenBrainz{
+		client: resty.New().
+			SetBaseURL(strings.TrimSuffix(baseURL, "/")).
+			SetHeader("User-Agent", userAgent).
+			SetHeader("Authorization", "Token "+token).
+			SetTimeout(requestTimeout),
+	}
+}
+
+type listenBrainzListen struct {
+	ListenedAt    int64                 `json:"listened_at,omitempty"`
+	TrackMetadata listenBrainzTrackMeta `json:"track_metadata"`
+}
+
+type listenBrainzTrackMeta struct {
+	ArtistName     string         `json:"artist_name"`
+	TrackName      string         `json:"track_name"`
+	ReleaseName    string         `json:"release_name,omitempty"`
+	AdditionalInfo map[string]any `json:"additional_info,omitempty"`
+}
+
+type listenBrainzError struct {
+	Code  int    `json:"code"`
+	Error string `json:"error"`
+}
+
+// validate returns the name of the account the token belongs to.
+func (lb *listenBrainz) validate(ctx context.Context) (string, error) {
+	var result struct {
+		Valid    bool   `json:"valid"`
+		UserName string `json:"user_name"`
+		Message  string `json:"message"`
+	}
+	resp, err := lb.client.R().SetContext(ctx).Get("/1/validate-token")
+	if err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(resp.Body(), &result); err != nil || !resp.IsSuccess() || !result.Valid {
+		return "", fmt.Errorf("invalid listenbrainz token: %s", result.Message)
+	}
+	return result.UserName, nil
+}
+
+func (lb *listenBrainz) nowPlaying(ctx context.Context, track Track) error {
+	return lb.submit(ctx, "playing_now", listenBrainzListen{TrackMetadata: lb.metadata(track)})
+}
+
+func (lb *listenBrainz) scrobble(ctx context.Context, track Track, playedAt time.Time) error {
+	return lb.submit(ctx, "single", listenBrainzListen{ListenedAt: playedAt.Unix(), TrackMetadata: lb.metadata(track)})
+}
+
+func (lb *listenBrainz) metadata(track Track) listenBrainzTrackMeta {
+	info := map[string]any{"submission_client": "Miko"}
+	if track.Duration > 0 {
+		info["duration_ms"] = track.Duration * 1000
+	}
+	if track.TrackNumber > 0 {
+		info["tracknumber"] = track.TrackNumber
+	}
+	return listenBrainzTrackMeta{
+		ArtistName:     track.Artist,
+		TrackName:      track.Title,
+		ReleaseName:    track.Album,
+		AdditionalInfo: info,
+	}
+}
+
+func (lb *listenBrainz) submit(ctx context.Context, listenType string, listen listenBrainzListen) error {
+	resp, err := lb.client.R().
+		SetContext(ctx).
+		SetBody(map[string]any{
+			"listen_type": listenType,
+			"payload":     []listenBrainzListen{listen},
+		}).
+		Post("/1/submit-listens")
+	if err != nil {
+		return err
+	}
+	if resp.IsSuccess() {
+		return nil
+	}
+	var failure listenBrainzError
+	json.Unmarshal(resp.Body(), &failure)
+	err = fmt.Errorf("listenbrainz: %s %s", resp.Status(), failure.Error)
+	// bad requests and rejected tokens won't succeed on retry, rate limits and outages will
+	if code := resp.StatusCode(); code >= 400 && code < 500 && code != http.StatusTooManyRequests {
+		return &PermanentError{Err: err}
+	}
+	return err
+}
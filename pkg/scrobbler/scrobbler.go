@@ -0,0 +1,395 @@
+package scrobbler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/stkevintan/miko/config"
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/crypto"
+	"github.com/stkevintan/miko/pkg/history"
+	"github.com/stkevintan/miko/pkg/log"
+	"gorm.io/gorm"
+)
+
+const (
+	userAgent      = "Miko/1.0.0 (https://github.com/stkevintan/miko)"
+	requestTimeout = 15 * time.Second
+
+	// batchSize is the number of due events sent per round
+	batchSize = 50
+	// maxAttempts is the number of times a submission is sent before it is given up
+	maxAttempts = 10
+	// nowPlayingTTL is how long a now playing event is worth retrying
+	nowPlayingTTL = 5 * time.Minute
+	// idleInterval is how often the outbox is checked when no event is due
+	idleInterval = 10 * time.Minute
+)
+
+// Track is the song metadata sent to scrobbling services. Duration is in seconds.
+type Track struct {
+	Artist      string
+	Title       string
+	Album       string
+	Duration    int
+	TrackNumber int
+}
+
+// PermanentError is a failure that retrying won't fix, such as a revoked token.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// ErrServiceNotAllowed is returned when a user links an account of a Last.fm compatible service
+// that isn't allowed by the config.
+var ErrServiceNotAllowed = errors.New("last.fm compatible service not allowed")
+
+type client interface {
+	nowPlaying(ctx context.Context, track Track) error
+	scrobble(ctx context.Context, track Track, playedAt time.Time) error
+}
+
+// Scrobbler forwards the now playing and submission events of users to the ListenBrainz and
+// Last.fm compatible accounts they linked. Events are queued in the database so they survive
+// restarts and outages of the services, and are sent by Run.
+type Scrobbler struct {
+	db     *gorm.DB
+	cfg    *config.ScrobblerConfig
+	secret []byte
+	wake   chan struct{}
+}
+
+func New(db *gorm.DB, cfg *config.Config, secret []byte) *Scrobbler {
+	return &Scrobbler{
+		db:     db,
+		cfg:    cfg.Scrobbler,
+		secret: secret,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Accounts returns the scrobbling accounts username linked.
+func (s *Scrobbler) Accounts(username string) ([]models.ScrobbleAccount, error) {
+	accounts := []models.ScrobbleAccount{}
+	err := s.db.Where("username = ?", username).Order("service").Find(&accounts).Error
+	return accounts, err
+}
+
+// LinkListenBrainz links the ListenBrainz account the user token belongs to.
+func (s *Scrobbler) LinkListenBrainz(ctx context.Context, username, token string) (*models.ScrobbleAccount, error) {
+	name, err := newListenBrainz(s.cfg.ListenBrainzURL, token).validate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := crypto.Encrypt(token, s.secret)
+	if err != nil {
+		return nil, err
+	}
+	account := models.ScrobbleAccount{
+		Username: username,
+		Service:  models.ScrobbleListenBrainz,
+		Account:  name,
+		Token:    encrypted,
+	}
+	return &account, s.db.Save(&account).Error
+}
+
+// LastFMLogin are the credentials of an account of a Last.fm compatible service. BaseURL,
+// APIKey and APISecret default to the configured service, another service needs an API account
+// of the user.
+type LastFMLogin struct {
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	BaseURL   string `json:"baseUrl,omitempty"`
+	APIKey    string `json:"apiKey,omitempty"`
+	APISecret string `json:"apiSecret,omitempty"`
+}
+
+// LinkLastFM logs in to a Last.fm compatible service and links the account. The password is
+// only used to get a session key and isn't stored.
+func (s *Scrobbler) LinkLastFM(ctx context.Context, username string, login LastFMLogin) (*models.ScrobbleAccount, error) {
+	baseURL, apiKey, apiSecret := s.cfg.LastFMURL, s.cfg.LastFMAPIKey, s.cfg.LastFMSecret
+	if login.BaseURL == s.cfg.LastFMURL {
+		login.BaseURL = ""
+	}
+	if login.BaseURL != "" {
+		if err := s.allowService(username, login.BaseURL); err != nil {
+			return nil, err
+		}
+		// the API account of Miko is only ever sent to the configured service
+		if login.APIKey == "" || login.APISecret == "" {
+			return nil, errors.New("an api key and secret of your own are required for another service")
+		}
+		baseURL = login.BaseURL
+	}
+	if login.APIKey != "" {
+		apiKey, apiSecret = login.APIKey, login.APISecret
+	}
+	if apiKey == "" || apiSecret == "" {
+		return nil, errors.New("no last.fm api key configured")
+	}
+
+	name, sessionKey, err := newLastFM(baseURL, apiKey, apiSecret, "").session(ctx, login.Username, login.Password)
+	if err != nil {
+		return nil, err
+	}
+	account := models.ScrobbleAccount{
+		Username: username,
+		Service:  models.ScrobbleLastFM,
+		Account:  name,
+	}
+	if login.BaseURL != "" {
+		account.BaseURL = login.BaseURL
+	}
+	if account.Token, err = crypto.Encrypt(sessionKey, s.secret); err != nil {
+		return nil, err
+	}
+	if login.APIKey != "" {
+		account.APIKey = login.APIKey
+		if account.APISecret, err = crypto.Encrypt(login.APISecret, s.secret); err != nil {
+			return nil, err
+		}
+	}
+	return &account, s.db.Save(&account).Error
+}
+
+// allowService checks that username may link an account of the service at baseURL.
+func (s *Scrobbler) allowService(username, baseURL string) error {
+	if slices.Contains(s.cfg.LastFMURLs, baseURL) {
+		return nil
+	}
+	var user models.User
+	if err := s.db.Select("username, admin_role").Where("username = ?", username).Limit(1).Find(&user).Error; err != nil {
+		return err
+	}
+	if !user.AdminRole {
+		return ErrServiceNotAllowed
+	}
+	return nil
+}
+
+// Unlink removes a scrobbling account of username along with its pending events.
+func (s *Scrobbler) Unlink(username, service string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("username = ? AND service = ?", username, service).Delete(&models.ScrobbleEvent{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("username = ? AND service = ?", username, service).Delete(&models.ScrobbleAccount{}).Error
+	})
+}
+
+// NowPlaying queues a now playing event of username for every linked account.
+func (s *Scrobbler) NowPlaying(username, songID string) error {
+	return s.enqueue(username, models.ScrobbleNowPlaying, []history.Play{{SongID: songID, PlayedAt: time.Now()}})
+}
+
+// Submit queues the plays of username for every linked account.
+func (s *Scrobbler) Submit(username string, plays []history.Play) error {
+	return s.enqueue(username, models.ScrobbleSubmission, plays)
+}
+
+func (s *Scrobbler) enqueue(username, kind string, plays []history.Play) error {
+	var user models.User
+	if err := s.db.Select("username, scrobbling_enabled").Where("username = ?", username).Limit(1).Find(&user).Error; err != nil {
+		return err
+	}
+	if !user.ScrobblingEnabled || len(plays) == 0 {
+		return nil
+	}
+	var services []string
+	if err := s.db.Model(&models.ScrobbleAccount{}).Where("username = ?", username).Pluck("service", &services).Error; err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	events := make([]models.ScrobbleEvent, 0, len(services)*len(plays))
+	for _, service := range services {
+		for _, play := range plays {
+			events = append(events, models.ScrobbleEvent{
+				Username:    username,
+				Service:     service,
+				Kind:        kind,
+				SongID:      play.SongID,
+				PlayedAt:    play.PlayedAt.UTC(),
+				NextAttempt: now.UTC(),
+			})
+		}
+	}
+	if err := s.db.Create(&events).Error; err != nil {
+		return err
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run sends the queued events until ctx is done.
+func (s *Scrobbler) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-s.wake:
+		}
+		timer.Reset(s.flush(ctx))
+	}
+}
+
+// flush sends the due events and returns how long to wait for the next one.
+func (s *Scrobbler) flush(ctx context.Context) time.Duration {
+	clients := make(map[string]client)
+	for ctx.Err() == nil {
+		var events []models.ScrobbleEvent
+		if err := s.db.Where("next_attempt <= ?", time.Now().UTC()).Order("id").Limit(batchSize).Find(&events).Error; err != nil {
+			log.Error("Failed to load scrobble events: %v", err)
+			return idleInterval
+		}
+		for i := range events {
+			s.send(ctx, clients, &events[i])
+		}
+		if len(events) < batchSize {
+			break
+		}
+	}
+
+	var next models.ScrobbleEvent
+	if err := s.db.Order("next_attempt").Limit(1).Find(&next).Error; err != nil || next.ID == 0 {
+		return idleInterval
+	}
+	return min(max(time.Until(next.NextAttempt), time.Second), idleInterval)
+}
+
+// send forwards an event and removes it from the outbox, or schedules a retry with exponential
+// backoff when the service failed temporarily.
+func (s *Scrobbler) send(ctx context.Context, clients map[string]client, event *models.ScrobbleEvent) {
+	key := event.Username + ":" + event.Service
+	svc, ok := clients[key]
+	var err error
+	if !ok {
+		if svc, err = s.client(event.Username, event.Service); err == nil {
+			clients[key] = svc
+		} else {
+			err = &PermanentError{Err: err}
+		}
+	}
+
+	if err == nil {
+		var track *Track
+		if track, err = s.track(event.SongID); err != nil {
+			err = &PermanentError{Err: err}
+		} else if event.Kind == models.ScrobbleNowPlaying {
+			err = svc.nowPlaying(ctx, *track)
+		} else {
+			err = svc.scrobble(ctx, *track, event.PlayedAt)
+		}
+	}
+	if ctx.Err() != nil {
+		// shutting down, the event is sent again on the next start
+		return
+	}
+
+	event.Attempts++
+	var permanent *PermanentError
+	switch {
+	case err == nil:
+	case errors.As(err, &permanent):
+		log.Warn("Dropping %s scrobble of %s to %s: %v", event.Kind, event.Username, event.Service, err)
+	case event.Kind == models.ScrobbleNowPlaying && time.Since(event.CreatedAt) > nowPlayingTTL:
+		log.Debug("Dropping stale now playing event of %s to %s: %v", event.Username, event.Service, err)
+	case event.Attempts >= maxAttempts:
+		log.Warn("Giving up %s scrobble of %s to %s after %d attempts: %v", event.Kind, event.Username, event.Service, event.Attempts, err)
+	default:
+		event.NextAttempt = time.Now().UTC().Add(backoff(event.Attempts))
+		event.LastError = err.Error()
+		if err := s.db.Save(event).Error; err != nil {
+			log.Error("Failed to reschedule scrobble event %d: %v", event.ID, err)
+		}
+		return
+	}
+	if err := s.db.Delete(event).Error; err != nil {
+		log.Error("Failed to remove scrobble event %d: %v", event.ID, err)
+	}
+}
+
+// backoff is the delay before the next attempt after a number of failed attempts, doubling
+// from 30 seconds up to 6 hours.
+func backoff(attempts int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < 6*time.Hour; i++ {
+		delay *= 2
+	}
+	return min(delay, 6*time.Hour)
+}
+
+// client returns a client of the account username linked to service.
+func (s *Scrobbler) client(username, service string) (client, error) {
+	var account models.ScrobbleAccount
+	if err := s.db.Where("username = ? AND service = ?", username, service).Limit(1).Find(&account).Error; err != nil {
+		return nil, err
+	}
+	if account.Username == "" {
+		return nil, fmt.Errorf("no %s account linked", service)
+	}
+	token, err := crypto.Decrypt(account.Token, s.secret)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt %s token: %w", service, err)
+	}
+
+	switch service {
+	case models.ScrobbleListenBrainz:
+		return newListenBrainz(s.cfg.ListenBrainzURL, token), nil
+	case models.ScrobbleLastFM:
+		baseURL, apiKey, apiSecret := s.cfg.LastFMURL, s.cfg.LastFMAPIKey, s.cfg.LastFMSecret
+		if account.BaseURL != "" && account.BaseURL != s.cfg.LastFMURL {
+			if account.APIKey == "" {
+				return nil, fmt.Errorf("%s account of %s has no api key", service, account.BaseURL)
+			}
+			baseURL = account.BaseURL
+		}
+		if account.APIKey != "" {
+			apiKey = account.APIKey
+			if apiSecret, err = crypto.Decrypt(account.APISecret, s.secret); err != nil {
+				return nil, fmt.Errorf("decrypt %s api secret: %w", service, err)
+			}
+		}
+		return newLastFM(baseURL, apiKey, apiSecret, token), nil
+	default:
+		return nil, fmt.Errorf("unknown scrobbling service: %s", service)
+	}
+}
+
+func (s *Scrobbler) track(songID string) (*Track, error) {
+	var song models.Child
+	if err := s.db.Where("id = ?", songID).Limit(1).Find(&song).Error; err != nil {
+		return nil, err
+	}
+	if song.ID == "" {
+		return nil, fmt.Errorf("song %s not found", songID)
+	}
+	return &Track{
+		Artist:      song.Artist,
+		Title:       song.Title,
+		Album:       song.Album,
+		Duration:    song.Duration,
+		TrackNumber: song.Track,
+	}, nil
+}
@@ -0,0 +1,174 @@
+package scrobbler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stkevintan/miko/config"
+	"github.com/stkevintan/miko/models"
+	"gorm.io/gorm"
+)
+
+var track = Track{Artist: "Artist", Title: "Title", Album: "Album", Duration: 200, TrackNumber: 3}
+
+func TestListenBrainz(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":401,"error":"Invalid authorization token."}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/1/validate-token":
+			w.Write([]byte(`{"code":200,"message":"Token valid.","valid":true,"user_name":"lb-user"}`))
+		case "/1/submit-listens":
+			json.NewDecoder(r.Body).Decode(&got)
+			w.Write([]byte(`{"status":"ok"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	lb := newListenBrainz(srv.URL+"/", "secret")
+	if name, err := lb.validate(ctx); err != nil || name != "lb-user" {
+		t.Fatalf("validate = %q, %v", name, err)
+	}
+	playedAt := time.Unix(1700000000, 0)
+	if err := lb.scrobble(ctx, track, playedAt); err != nil {
+		t.Fatal(err)
+	}
+	if got["listen_type"] != "single" {
+		t.Errorf("listen_type = %v, want single", got["listen_type"])
+	}
+	listen := got["payload"].([]any)[0].(map[string]any)
+	if listen["listened_at"] != float64(1700000000) {
+		t.Errorf("listened_at = %v", listen["listened_at"])
+	}
+	if meta := listen["track_metadata"].(map[string]any); meta["track_name"] != "Title" || meta["artist_name"] != "Artist" {
+		t.Errorf("track_metadata = %v", meta)
+	}
+
+	var permanent *PermanentError
+	if err := newListenBrainz(srv.URL, "revoked").nowPlaying(ctx, track); !errors.As(err, &permanent) {
+		t.Errorf("rejected token error = %v, want a permanent error", err)
+	}
+}
+
+func TestLastFM(t *testing.T) {
+	failWith := 0
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = make(map[string]string)
+		for k := range r.PostForm {
+			got[k] = r.PostForm.Get(k)
+		}
+		sig := got["api_sig"]
+		delete(got, "api_sig")
+		if sig != lastFMSignature(got, "shh") {
+			w.Write([]byte(`{"error":13,"message":"Invalid method signature supplied"}`))
+			return
+		}
+		if failWith != 0 {
+			json.NewEncoder(w).Encode(map[string]any{"error": failWith, "message": "failed"})
+			return
+		}
+		if got["method"] == "auth.getMobileSession" {
+			w.Write([]byte(`{"session":{"name":"fm-user","key":"session-key","subscriber":0}}`))
+			return
+		}
+		w.Write([]byte(`{"scrobbles":{}}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	name, key, err := newLastFM(srv.URL, "key", "shh", "").session(ctx, "fm-user", "password")
+	if err != nil || name != "fm-user" || key != "session-key" {
+		t.Fatalf("session = %q, %q, %v", name, key, err)
+	}
+
+	fm := newLastFM(srv.URL, "key", "shh", key)
+	if err := fm.scrobble(ctx, track, time.Unix(1700000000, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if got["method"] != "track.scrobble" || got["sk"] != "session-key" || got["timestamp"] != "1700000000" || got["trackNumber"] != "3" {
+		t.Errorf("scrobble params = %v", got)
+	}
+
+	var permanent *PermanentError
+	failWith = 29
+	if err := fm.nowPlaying(ctx, track); err == nil || errors.As(err, &permanent) {
+		t.Errorf("rate limit error = %v, want a temporary error", err)
+	}
+	failWith = 9
+	if err := fm.nowPlaying(ctx, track); !errors.As(err, &permanent) {
+		t.Errorf("invalid session error = %v, want a permanent error", err)
+	}
+}
+
+func TestLinkLastFM_Service(t *testing.T) {
+	var apiKey string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		apiKey = r.PostForm.Get("api_key")
+		w.Write([]byte(`{"session":{"name":"fm-user","key":"session-key","subscriber":0}}`))
+	}))
+	defer srv.Close()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.ScrobbleAccount{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&models.User{Username: "alice"})
+	db.Create(&models.User{Username: "root", SubsonicSettings: models.SubsonicSettings{AdminRole: true}})
+	cfg := &config.Config{Scrobbler: &config.ScrobblerConfig{LastFMURL: "https://last.fm.invalid/", LastFMAPIKey: "miko", LastFMSecret: "miko-secret"}}
+	s := New(db, cfg, []byte("secret"))
+	ctx := context.Background()
+
+	login := LastFMLogin{Username: "fm-user", Password: "password", BaseURL: srv.URL}
+	if _, err := s.LinkLastFM(ctx, "alice", login); !errors.Is(err, ErrServiceNotAllowed) {
+		t.Errorf("LinkLastFM() by a user = %v, want ErrServiceNotAllowed", err)
+	}
+	if _, err := s.LinkLastFM(ctx, "root", login); err == nil || apiKey != "" {
+		t.Errorf("LinkLastFM() without an api key = %v, sent api key %q, want an error", err, apiKey)
+	}
+
+	login.APIKey, login.APISecret = "own", "own-secret"
+	if _, err := s.LinkLastFM(ctx, "root", login); err != nil || apiKey != "own" {
+		t.Errorf("LinkLastFM() by an admin = %v, sent api key %q, want the own one", err, apiKey)
+	}
+	cfg.Scrobbler.LastFMURLs = []string{srv.URL}
+	if _, err := s.LinkLastFM(ctx, "alice", login); err != nil {
+		t.Errorf("LinkLastFM() of an allowed service = %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{5, 8 * time.Minute},
+		{20, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
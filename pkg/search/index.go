@@ -0,0 +1,219 @@
+package search
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+
+	"github.com/stkevintan/miko/pkg/log"
+	"gorm.io/gorm"
+)
+
+// Table is the FTS5 table holding the search index.
+const Table = "search_index"
+
+// Kinds of indexed items
+const (
+	KindArtist = "artist"
+	KindAlbum  = "album"
+	KindSong   = "song"
+)
+
+// columns of the index, kind and ref_id are stored but not tokenized. extra holds extended
+// tags such as composer, label, ISRC and comments.
+var columns = []string{"kind", "ref_id", "title", "album", "artist", "extra", "lyrics", "path"}
+
+// weights are the BM25 weights of columns, a title hit ranks above an album or artist hit
+// which in turn rank above hits in tags, lyrics and file names.
+var weights = []string{"0", "0", "10.0", "4.0", "4.0", "2.0", "1.0", "1.0"}
+
+// Entry is a row of the search index.
+type Entry struct {
+	Kind   string
+	ID     string
+	Title  string
+	Album  string
+	Artist string
+	Extra  string
+	Lyrics string
+	Path   string
+}
+
+// rowid derives the FTS rowid from kind and id, so an entry can be replaced without scanning
+// the unindexed ref_id column.
+func (e Entry) rowid() int64 {
+	h := fnv.New64a()
+	h.Write([]byte(e.Kind + "|" + e.ID))
+	return int64(h.Sum64() >> 1)
+}
+
+func (e Entry) values() []any {
+	return []any{
+		e.rowid(), e.Kind, e.ID,
+		Normalize(e.Title), Normalize(e.Album), Normalize(e.Artist),
+		Normalize(e.Extra), Normalize(e.Lyrics), Normalize(e.Path),
+	}
+}
+
+// EnsureIndex creates the search index and fills it from the library. An index created with a
+// different set of columns is dropped and rebuilt.
+func EnsureIndex(db *gorm.DB) error {
+	var existing []string
+	if err := db.Raw("SELECT name FROM pragma_table_info(?)", Table).Scan(&existing).Error; err != nil {
+		return err
+	}
+	if slices.Equal(existing, columns) {
+		return nil
+	}
+
+	if len(existing) > 0 {
+		log.Info("Search index layout changed, rebuilding")
+		if err := db.Exec("DROP TABLE " + Table).Error; err != nil {
+			return err
+		}
+	}
+	schema := strings.Join(columns[2:], ", ")
+	err := db.Exec(fmt.Sprintf(
+		"CREATE VIRTUAL TABLE %s USING fts5(kind UNINDEXED, ref_id UNINDEXED, %s, tokenize = 'unicode61 remove_diacritics 2')",
+		Table, schema,
+	)).Error
+	if err != nil {
+		return fmt.Errorf("create search index: %w", err)
+	}
+	return Rebuild(db)
+}
+
+// Rebuild refills the whole index from the library tables.
+func Rebuild(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM " + Table).Error; err != nil {
+			return err
+		}
+
+		var entries []Entry
+		if err := tx.Raw("SELECT ? AS kind, id, name AS title, '' AS album, '' AS artist FROM artist_id3", KindArtist).
+			Scan(&entries).Error; err != nil {
+			return err
+		}
+		if err := insert(tx, entries); err != nil {
+			return err
+		}
+
+		entries = entries[:0]
+		if err := tx.Raw("SELECT ? AS kind, id, name AS title, '' AS album, artist FROM album_id3", KindAlbum).
+			Scan(&entries).Error; err != nil {
+			return err
+		}
+		if err := insert(tx, entries); err != nil {
+			return err
+		}
+
+		entries = entries[:0]
+		if err := tx.Raw(`SELECT ? AS kind, id, title, album, artist, lyrics, path,
+				COALESCE(display_composer, '') || ' ' || COALESCE(label, '') || ' ' ||
+				COALESCE(comment, '') || ' ' ||
+				COALESCE((SELECT group_concat(value, ' ') FROM json_each(isrc)), '') AS extra
+			FROM children WHERE is_dir = ?`, KindSong, false).
+			Scan(&entries).Error; err != nil {
+			return err
+		}
+		return insert(tx, entries)
+	})
+}
+
+// Index adds or replaces entries in the index.
+func Index(db *gorm.DB, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, e := range entries {
+			if err := tx.Exec("DELETE FROM "+Table+" WHERE rowid = ?", e.rowid()).Error; err != nil {
+				return err
+			}
+		}
+		return insert(tx, entries)
+	})
+}
+
+// Prune removes entries whose artist, album or song no longer exists.
+func Prune(db *gorm.DB) error {
+	result := db.Exec(`DELETE FROM `+Table+` WHERE
+		(kind = ? AND ref_id NOT IN (SELECT id FROM artist_id3)) OR
+		(kind = ? AND ref_id NOT IN (SELECT id FROM album_id3)) OR
+		(kind = ? AND ref_id NOT IN (SELECT id FROM children))`,
+		KindArtist, KindAlbum, KindSong)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Info("Pruned %d search index entries", result.RowsAffected)
+	}
+	return nil
+}
+
+func insert(tx *gorm.DB, entries []Entry) error {
+	stmt := fmt.Sprintf("INSERT INTO %s (rowid, %s) VALUES (?%s)", Table, strings.Join(columns, ", "), strings.Repeat(", ?", len(columns)))
+	for _, e := range entries {
+		if err := tx.Exec(stmt, e.values()...).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match is a column of an indexed item that matched a query.
+type Match struct {
+	Field   string `json:"field"`
+	Snippet string `json:"snippet"`
+}
+
+// snippet markers, replaced by <mark> once the snippet is HTML escaped
+const (
+	markOpen  = "\x02"
+	markClose = "\x03"
+)
+
+// Snippets returns for each of the given ids the columns that matched query, with the matching
+// words wrapped in <mark> tags. Snippets are HTML escaped.
+func Snippets(db *gorm.DB, kind, query string, ids []string) (map[string][]Match, error) {
+	res := make(map[string][]Match)
+	if query == "" || len(ids) == 0 {
+		return res, nil
+	}
+
+	fields := columns[2:]
+	selects := []string{"ref_id"}
+	for i, field := range fields {
+		selects = append(selects, fmt.Sprintf("snippet(%s, %d, '%s', '%s', '…', 12) AS %s", Table, i+2, markOpen, markClose, field))
+	}
+	var rows []map[string]any
+	err := db.Table(Table).
+		Select(strings.Join(selects, ", ")).
+		Where(Table+" MATCH ? AND kind = ? AND ref_id IN ?", query, kind, ids).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		id, _ := row["ref_id"].(string)
+		for _, field := range fields {
+			snippet, _ := row[field].(string)
+			if !strings.Contains(snippet, markOpen) {
+				continue
+			}
+			res[id] = append(res[id], Match{Field: field, Snippet: formatSnippet(snippet)})
+		}
+	}
+	return res, nil
+}
+
+// Hits returns a subquery listing the ids of kind matching query together with their BM25
+// score, lower scores are better matches. Join it on ref_id and order by score.
+func Hits(db *gorm.DB, kind, query string) *gorm.DB {
+	return db.Table(Table).
+		Select(fmt.Sprintf("ref_id, bm25(%s, %s) AS score", Table, strings.Join(weights, ", "))).
+		Where(Table+" MATCH ? AND kind = ?", query, kind)
+}
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// isCJK reports whether r belongs to a script written without spaces between words.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// Normalize prepares text for indexing. The FTS tokenizer treats a run of CJK characters as a
+// single token, so every CJK character is made a token of its own and matched as a phrase.
+func Normalize(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if isCJK(r) {
+			b.WriteRune(' ')
+			b.WriteRune(r)
+			b.WriteRune(' ')
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// MatchQuery turns user input into an FTS5 query. Every word must match, words match as
+// prefixes and runs of CJK characters must appear in sequence. An empty string is returned
+// when the input holds nothing searchable.
+func MatchQuery(input string) string {
+	var terms []string
+	var word []rune
+	var phrase []string
+
+	flushWord := func() {
+		if len(word) > 0 {
+			terms = append(terms, `"`+string(word)+`"*`)
+			word = word[:0]
+		}
+	}
+	flushPhrase := func() {
+		if len(phrase) > 0 {
+			terms = append(terms, `"`+strings.Join(phrase, " ")+`"`)
+			phrase = phrase[:0]
+		}
+	}
+
+	for _, r := range input {
+		switch {
+		case isCJK(r):
+			flushWord()
+			phrase = append(phrase, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			flushPhrase()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushPhrase()
+		}
+	}
+	flushWord()
+	flushPhrase()
+
+	return strings.Join(terms, " ")
+}
+
+// formatSnippet undoes the CJK spacing added by Normalize, escapes the snippet and turns the
+// match markers into <mark> tags.
+func formatSnippet(s string) string {
+	runes := []rune(s)
+	skip := func(r rune) bool {
+		return r == ' ' || string(r) == markOpen || string(r) == markClose
+	}
+	neighbour := func(i, step int) rune {
+		for i += step; i >= 0 && i < len(runes); i += step {
+			if !skip(runes[i]) {
+				return runes[i]
+			}
+		}
+		return 0
+	}
+
+	var b strings.Builder
+	for i, r := range runes {
+		if r == ' ' && (isCJK(neighbour(i, -1)) || isCJK(neighbour(i, 1))) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	escaped := html.EscapeString(strings.TrimSpace(b.String()))
+	return strings.NewReplacer(markOpen, "<mark>", markClose, "</mark>").Replace(escaped)
+}
@@ -0,0 +1,37 @@
+package search
+
+import "testing"
+
+func TestMatchQuery(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"beatles help", `"beatles"* "help"*`},
+		{"  Beyoncé!", `"Beyoncé"*`},
+		{"周杰伦 晴天", `"周 杰 伦" "晴 天"`},
+		{"jay周杰伦", `"jay"* "周 杰 伦"`},
+		{`" OR NEAR(`, `"OR"* "NEAR"*`},
+		{"***", ""},
+	}
+	for _, tt := range tests {
+		if got := MatchQuery(tt.in); got != tt.want {
+			t.Errorf("MatchQuery(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSnippet(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{" 故 \x02 事 \x03  的 ", "故<mark>事</mark>的"},
+		{"rock & \x02roll\x03", "rock &amp; <mark>roll</mark>"},
+	}
+	for _, tt := range tests {
+		if got := formatSnippet(tt.in); got != tt.want {
+			t.Errorf("formatSnippet(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -9,7 +9,7 @@ import (
 
 func IsAudioFile(path string) bool {
 	ext := strings.ToLower(filepath.Ext(path))
-	return ext == ".mp3" || ext == ".flac" || ext == ".m4a" || ext == ".wav"
+	return ext == ".mp3" || ext == ".flac" || ext == ".m4a" || ext == ".m4b" || ext == ".wav"
 }
 
 func GenerateHash(data string) string {
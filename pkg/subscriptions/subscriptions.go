@@ -0,0 +1,291 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stkevintan/miko/config"
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/downloads"
+	"github.com/stkevintan/miko/pkg/log"
+	"github.com/stkevintan/miko/pkg/playlists"
+	"github.com/stkevintan/miko/pkg/types"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultInterval is the number of minutes between two syncs of a subscription, a week
+	DefaultInterval = 7 * 24 * 60
+	// minInterval keeps subscriptions from hammering the provider
+	minInterval  = 60
+	pollInterval = time.Minute
+)
+
+// ErrInvalid is returned for a subscription without URI or syncing too often.
+var ErrInvalid = errors.New("invalid subscription")
+
+// Manager syncs the subscriptions of users on their schedule: the songs of a subscription are
+// resolved again and the new ones queued for download, then its playlist is mirrored once the
+// download job is done.
+type Manager struct {
+	db        *gorm.DB
+	cfg       *config.Config
+	providers downloads.Providers
+	queue     *downloads.Queue
+	playlists *playlists.Manager
+	mu        sync.Mutex
+}
+
+func New(db *gorm.DB, cfg *config.Config, providers downloads.Providers, queue *downloads.Queue) *Manager {
+	return &Manager{
+		db:        db,
+		cfg:       cfg,
+		providers: providers,
+		queue:     queue,
+		playlists: playlists.New(db),
+	}
+}
+
+// Save creates or updates a subscription, a new one is synced on the next poll.
+func (m *Manager) Save(sub *models.Subscription) error {
+	if sub.URI == "" {
+		return fmt.Errorf("%w: uri is required", ErrInvalid)
+	}
+	if sub.Interval == 0 {
+		sub.Interval = DefaultInterval
+	}
+	if sub.Interval < minInterval {
+		return fmt.Errorf("%w: interval must be at least %d minutes", ErrInvalid, minInterval)
+	}
+	if sub.Name == "" {
+		sub.Name = sub.URI
+	}
+	if sub.ID == 0 {
+		sub.NextSyncAt = time.Now()
+	} else if sub.LastSyncAt != nil {
+		sub.NextSyncAt = sub.LastSyncAt.Add(time.Duration(sub.Interval) * time.Minute)
+	}
+	return m.db.Save(sub).Error
+}
+
+// List returns the subscriptions of the user.
+func (m *Manager) List(username string) ([]models.Subscription, error) {
+	var subs []models.Subscription
+	err := m.db.Where("username = ?", username).Order("id").Find(&subs).Error
+	return subs, err
+}
+
+// Get returns a subscription of the user.
+func (m *Manager) Get(username string, id uint) (*models.Subscription, error) {
+	var sub models.Subscription
+	if err := m.db.Where("id = ? AND username = ?", id, username).First(&sub).Error; err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// Delete removes a subscription of the user with its history, its playlist is kept.
+func (m *Manager) Delete(username string, id uint) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND username = ?", id, username).Delete(&models.Subscription{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("subscription_id = ?", id).Delete(&models.SubscriptionEvent{}).Error
+	})
+}
+
+// History returns the latest events of a subscription, the most recent first.
+func (m *Manager) History(id uint, limit, offset int) ([]models.SubscriptionEvent, error) {
+	var events []models.SubscriptionEvent
+	err := m.db.Where("subscription_id = ?", id).Order("id DESC").Limit(limit).Offset(offset).Find(&events).Error
+	return events, err
+}
+
+// Run syncs the due subscriptions and mirrors their playlists until ctx is done.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		m.mirrorDone()
+		m.syncDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) syncDue(ctx context.Context) {
+	var subs []models.Subscription
+	if err := m.db.Where("enabled = ? AND next_sync_at <= ?", true, time.Now()).Order("next_sync_at").Find(&subs).Error; err != nil {
+		log.Error("Failed to load due subscriptions: %v", err)
+		return
+	}
+	for i := range subs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := m.Sync(ctx, &subs[i]); err != nil {
+			log.Warn("Failed to sync subscription %d: %v", subs[i].ID, err)
+		}
+	}
+}
+
+// Sync resolves the songs of the subscription and queues the ones that aren't in the library
+// for download. A failure is logged in its history and retried on schedule.
+func (m *Manager) Sync(ctx context.Context, sub *models.Subscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	sub.LastSyncAt = &now
+	sub.NextSyncAt = now.Add(time.Duration(sub.Interval) * time.Minute)
+
+	job, err := m.enqueue(ctx, sub)
+	event := models.SubscriptionEvent{SubscriptionID: sub.ID, Action: models.SubscriptionSynced}
+	if err != nil {
+		event.Action = models.SubscriptionFailed
+		event.Error = err.Error()
+	} else {
+		sub.JobID = job.ID
+		event.JobID = job.ID
+		event.Queued = job.Total
+		event.Skipped = job.Skipped + len(sub.Library)
+	}
+
+	if err := m.db.Model(sub).Select("tracks", "library", "job_id", "last_sync_at", "next_sync_at").Updates(sub).Error; err != nil {
+		log.Error("Failed to save subscription %d: %v", sub.ID, err)
+	}
+	if err := m.db.Create(&event).Error; err != nil {
+		log.Error("Failed to log the sync of subscription %d: %v", sub.ID, err)
+	}
+	return err
+}
+
+func (m *Manager) enqueue(ctx context.Context, sub *models.Subscription) (*models.DownloadJob, error) {
+	p, err := m.providers(ctx, sub.Username, sub.Platform)
+	if err != nil {
+		return nil, fmt.Errorf("create provider: %w", err)
+	}
+	defer p.Close(ctx)
+
+	music, err := p.GetMusic(ctx, []string{sub.URI})
+	if err != nil {
+		return nil, fmt.Errorf("GetMusic: %w", err)
+	}
+	sub.Tracks = make([]int64, 0, len(music))
+	for _, v := range music {
+		sub.Tracks = append(sub.Tracks, v.Id)
+	}
+	if sub.Library, err = m.inLibrary(sub.Platform, music); err != nil {
+		return nil, fmt.Errorf("look up the library: %w", err)
+	}
+	missing := make([]*types.Music, 0, len(music))
+	for _, v := range music {
+		if _, ok := sub.Library[v.Id]; !ok {
+			missing = append(missing, v)
+		}
+	}
+
+	return m.queue.EnqueueMusic(sub.Username, sub.Platform, []string{sub.URI}, missing, types.DownloadConfig{
+		Level:          sub.Level,
+		Output:         sub.Output,
+		ConflictPolicy: types.ConflictPolicySkip.String(),
+		PathTemplate:   m.cfg.Download.PathTemplate(),
+	})
+}
+
+// inLibrary returns the library songs of the songs of music that are in the library already: by
+// their ID on platform, or else by artist, title and duration, for songs that were added to the
+// library another way than downloading them.
+func (m *Manager) inLibrary(platform string, music []*types.Music) (map[int64]string, error) {
+	ids := make([]int64, 0, len(music))
+	for _, v := range music {
+		ids = append(ids, v.Id)
+	}
+	songs, err := downloads.LibrarySongs(m.db, platform, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range music {
+		if _, ok := songs[v.Id]; ok {
+			continue
+		}
+		var artist string
+		if len(v.Artist) > 0 {
+			artist = v.Artist[0].Name
+		}
+		id, err := m.playlists.Match(playlists.Entry{Artist: artist, Title: v.Name, Duration: int(v.Time / 1000)}, "")
+		if err != nil {
+			return nil, err
+		}
+		if id != "" {
+			songs[v.Id] = id
+		}
+	}
+	return songs, nil
+}
+
+// mirrorDone mirrors the playlists of the subscriptions whose last download job is done.
+func (m *Manager) mirrorDone() {
+	var subs []models.Subscription
+	err := m.db.Joins("JOIN download_jobs ON download_jobs.id = subscriptions.job_id").
+		Where("subscriptions.mirror_playlist = ? AND subscriptions.job_id <> subscriptions.mirrored_job_id", true).
+		Where("download_jobs.status IN ?", []string{models.DownloadCompleted, models.DownloadFailed, models.DownloadCancelled}).
+		Find(&subs).Error
+	if err != nil {
+		log.Error("Failed to load the subscriptions to mirror: %v", err)
+		return
+	}
+	for i := range subs {
+		if err := m.Mirror(&subs[i]); err != nil {
+			log.Warn("Failed to mirror the playlist of subscription %d: %v", subs[i].ID, err)
+		}
+	}
+}
+
+// Mirror sets the songs of the playlist of the subscription to the songs of its last sync that
+// are in the library, in their remote order: the ones downloaded since, or else the ones found in
+// the library by the sync.
+func (m *Manager) Mirror(sub *models.Subscription) error {
+	songs, err := downloads.LibrarySongs(m.db, sub.Platform, sub.Tracks)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(sub.Tracks))
+	for _, track := range sub.Tracks {
+		if id, ok := songs[track]; ok {
+			ids = append(ids, id)
+		} else if id, ok := sub.Library[track]; ok {
+			ids = append(ids, id)
+		}
+	}
+
+	event := models.SubscriptionEvent{SubscriptionID: sub.ID, Action: models.SubscriptionMirrored, JobID: sub.JobID}
+	playlistID, added, removed, err := m.playlists.Mirror(sub.PlaylistID, sub.Username, sub.Name, ids)
+	if err != nil {
+		event.Action = models.SubscriptionFailed
+		event.Error = fmt.Sprintf("mirror playlist: %v", err)
+	} else {
+		sub.PlaylistID = playlistID
+		event.Added = added
+		event.Removed = removed
+	}
+	// a failed mirror waits for the next sync rather than being retried every poll
+	sub.MirroredJobID = sub.JobID
+	if err := m.db.Model(sub).Select("playlist_id", "mirrored_job_id").Updates(sub).Error; err != nil {
+		log.Error("Failed to save subscription %d: %v", sub.ID, err)
+	}
+	if err := m.db.Create(&event).Error; err != nil {
+		log.Error("Failed to log the mirror of subscription %d: %v", sub.ID, err)
+	}
+	return err
+}
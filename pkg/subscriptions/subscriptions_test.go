@@ -0,0 +1,160 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stkevintan/miko/config"
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/cookiecloud"
+	"github.com/stkevintan/miko/pkg/downloads"
+	"github.com/stkevintan/miko/pkg/events"
+	"github.com/stkevintan/miko/pkg/provider"
+	"github.com/stkevintan/miko/pkg/types"
+	"gorm.io/gorm"
+)
+
+// fakeProvider resolves every URI to music, or fails with err.
+type fakeProvider struct {
+	music []*types.Music
+	err   error
+}
+
+func (p *fakeProvider) GetCookieJar() cookiecloud.CookieJar       { return nil }
+func (p *fakeProvider) User(context.Context) (*types.User, error) { return nil, nil }
+func (p *fakeProvider) Close(context.Context) error               { return nil }
+func (p *fakeProvider) GetMusic(context.Context, []string) ([]*types.Music, error) {
+	return p.music, p.err
+}
+func (p *fakeProvider) Download(context.Context, []*types.Music, *types.DownloadConfig) (*types.MusicDownloadResults, error) {
+	return nil, errors.New("not implemented")
+}
+
+func newTestManager(t *testing.T, p *fakeProvider) *Manager {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AutoMigrate(&models.Subscription{}, &models.SubscriptionEvent{}, &models.DownloadJob{}, &models.DownloadItem{},
+		&models.Child{}, &models.PlaylistRecord{}, &models.PlaylistSong{}, &models.PlaylistActivity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	providers := func(context.Context, string, string) (provider.Provider, error) { return p, nil }
+	cfg := &config.Config{Download: &config.DownloadConfig{}}
+	return New(db, cfg, providers, downloads.New(db, providers, events.New(), nil))
+}
+
+func TestSave(t *testing.T) {
+	m := newTestManager(t, &fakeProvider{})
+
+	if err := m.Save(&models.Subscription{Username: "alice"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Save() without URI = %v, want ErrInvalid", err)
+	}
+	if err := m.Save(&models.Subscription{Username: "alice", URI: "uri", Interval: minInterval - 1}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Save() syncing every %d minutes = %v, want ErrInvalid", minInterval-1, err)
+	}
+
+	sub := &models.Subscription{Username: "alice", URI: "uri"}
+	if err := m.Save(sub); err != nil {
+		t.Fatal(err)
+	}
+	if sub.Interval != DefaultInterval || sub.Name != "uri" || sub.NextSyncAt.After(time.Now()) {
+		t.Errorf("Save() = interval %d, name %q, next sync %v, want the defaults synced now", sub.Interval, sub.Name, sub.NextSyncAt)
+	}
+
+	// the schedule follows a new interval
+	last := time.Now().Add(-time.Hour)
+	sub.LastSyncAt, sub.Interval = &last, 2*minInterval
+	if err := m.Save(sub); err != nil {
+		t.Fatal(err)
+	}
+	if want := last.Add(2 * minInterval * time.Minute); !sub.NextSyncAt.Equal(want) {
+		t.Errorf("Save() = next sync %v, want %v", sub.NextSyncAt, want)
+	}
+}
+
+func TestSync(t *testing.T) {
+	p := &fakeProvider{music: []*types.Music{
+		{Id: 3, Name: "New"},
+		{Id: 1, Name: "Downloaded"},
+		{Id: 2, Name: "Ripped", Artist: []types.Artist{{Name: "Band"}}, Time: 200_000},
+	}}
+	m := newTestManager(t, p)
+	m.db.Create(&models.Child{ID: "downloaded", Path: "/downloaded.mp3", Title: "Downloaded", NeteaseID: 1})
+	// added to the library before the subscription
+	m.db.Create(&models.Child{ID: "ripped", Path: "/ripped.mp3", Title: "Ripped", Artist: "Band", Duration: 201})
+
+	sub := &models.Subscription{Username: "alice", Platform: "netease", URI: "uri", Enabled: true, MirrorPlaylist: true}
+	if err := m.Save(sub); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Sync(context.Background(), sub); err != nil {
+		t.Fatal(err)
+	}
+	var event models.SubscriptionEvent
+	m.db.Where("subscription_id = ?", sub.ID).Last(&event)
+	if event.Action != models.SubscriptionSynced || event.Queued != 1 || event.Skipped != 2 {
+		t.Errorf("sync event = %s, %d queued, %d skipped, want the new song queued", event.Action, event.Queued, event.Skipped)
+	}
+
+	// the playlist waits for the download job
+	m.mirrorDone()
+	var saved models.Subscription
+	m.db.First(&saved, sub.ID)
+	if saved.PlaylistID != 0 {
+		t.Fatal("mirrorDone() mirrored before the download job was done")
+	}
+
+	m.db.Create(&models.Child{ID: "new", Path: "/new.mp3", Title: "New"})
+	m.db.Model(&models.DownloadItem{}).Where("job_id = ?", saved.JobID).Updates(map[string]any{"status": models.DownloadCompleted, "song_id": "new"})
+	m.db.Model(&models.DownloadJob{}).Where("id = ?", saved.JobID).Update("status", models.DownloadCompleted)
+	m.mirrorDone()
+
+	m.db.First(&saved, sub.ID)
+	var songs []string
+	m.db.Model(&models.PlaylistSong{}).Where("playlist_id = ?", saved.PlaylistID).Order("position").Pluck("song_id", &songs)
+	if saved.PlaylistID == 0 || !slices.Equal(songs, []string{"new", "downloaded", "ripped"}) {
+		t.Errorf("playlist %d = %v, want the songs in their remote order", saved.PlaylistID, songs)
+	}
+	var mirrored models.SubscriptionEvent
+	m.db.Where("subscription_id = ?", sub.ID).Last(&mirrored)
+	if mirrored.Action != models.SubscriptionMirrored || mirrored.Added != 3 {
+		t.Errorf("mirror event = %s, %d added, want 3 songs added", mirrored.Action, mirrored.Added)
+	}
+
+	// a mirrored job isn't mirrored again
+	m.mirrorDone()
+	var mirrors int64
+	m.db.Model(&models.SubscriptionEvent{}).Where("subscription_id = ? AND action = ?", sub.ID, models.SubscriptionMirrored).Count(&mirrors)
+	if mirrors != 1 {
+		t.Errorf("%d mirror events, want 1", mirrors)
+	}
+}
+
+func TestSync_Failed(t *testing.T) {
+	m := newTestManager(t, &fakeProvider{err: errors.New("boom")})
+	sub := &models.Subscription{Username: "alice", Platform: "netease", URI: "uri", Enabled: true}
+	if err := m.Save(sub); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Sync(context.Background(), sub); err == nil {
+		t.Fatal("Sync() succeeded, want the provider error")
+	}
+	var saved models.Subscription
+	m.db.First(&saved, sub.ID)
+	if saved.LastSyncAt == nil || !saved.NextSyncAt.After(time.Now()) {
+		t.Errorf("subscription synced at %v, next at %v, want it retried on schedule", saved.LastSyncAt, saved.NextSyncAt)
+	}
+	var event models.SubscriptionEvent
+	m.db.Where("subscription_id = ?", sub.ID).Last(&event)
+	if event.Action != models.SubscriptionFailed || event.Error == "" {
+		t.Errorf("sync event = %s %q, want the failure", event.Action, event.Error)
+	}
+}
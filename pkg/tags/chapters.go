@@ -0,0 +1,502 @@
+package tags
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Chapter is a chapter marker of an audio file. End is zero when the file does not record it,
+// in which case the chapter lasts until the next one or the end of the file.
+type Chapter struct {
+	Title string
+	Start time.Duration
+	End   time.Duration
+}
+
+// ReadChapters reads chapter markers from ID3v2 CHAP frames (MP3), or from the Nero chpl atom or
+// the QuickTime chapter text track (M4A/M4B). Files without chapters return no error and no
+// chapters.
+func ReadChapters(path string) ([]Chapter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".m4a", ".m4b", ".mp4":
+		return readMP4Chapters(f)
+	default:
+		return readID3Chapters(f)
+	}
+}
+
+func readID3Chapters(r io.Reader) ([]Chapter, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil
+	}
+	if string(header[:3]) != "ID3" {
+		return nil, nil
+	}
+	version := header[3]
+	if version < 3 {
+		// ID3v2.2 has no CHAP frame
+		return nil, nil
+	}
+	body := make([]byte, syncsafe(header[6:10]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	if header[5]&0x40 != 0 && len(body) >= 4 {
+		// skip extended header, v2.3 doesn't count the size field itself
+		size := int(syncsafe(body[:4]))
+		if version == 3 {
+			size = int(binary.BigEndian.Uint32(body[:4])) + 4
+		}
+		if size > len(body) {
+			return nil, errors.New("invalid ID3 extended header")
+		}
+		body = body[size:]
+	}
+
+	var chapters []Chapter
+	for id, data := range id3Frames(body, version) {
+		if id != "CHAP" {
+			continue
+		}
+		if chapter, ok := parseCHAP(data, version); ok {
+			chapters = append(chapters, chapter)
+		}
+	}
+	sortChapters(chapters)
+	return chapters, nil
+}
+
+// id3Frames iterates the frames of an ID3v2.3/2.4 tag body.
+func id3Frames(body []byte, version byte) func(yield func(string, []byte) bool) {
+	return func(yield func(string, []byte) bool) {
+		for len(body) >= 10 && body[0] != 0 {
+			id := string(body[:4])
+			size := binary.BigEndian.Uint32(body[4:8])
+			if version >= 4 {
+				size = syncsafe(body[4:8])
+			}
+			if int(size) > len(body)-10 {
+				return
+			}
+			if !yield(id, body[10:10+size]) {
+				return
+			}
+			body = body[10+size:]
+		}
+	}
+}
+
+func parseCHAP(data []byte, version byte) (Chapter, bool) {
+	end := bytes.IndexByte(data, 0)
+	if end < 0 || len(data) < end+17 {
+		return Chapter{}, false
+	}
+	elementID := string(data[:end])
+	data = data[end+1:]
+	chapter := Chapter{
+		Title: elementID,
+		Start: time.Duration(binary.BigEndian.Uint32(data[0:4])) * time.Millisecond,
+		End:   time.Duration(binary.BigEndian.Uint32(data[4:8])) * time.Millisecond,
+	}
+	for id, sub := range id3Frames(data[16:], version) {
+		if id == "TIT2" {
+			if title := decodeID3Text(sub); title != "" {
+				chapter.Title = title
+			}
+			break
+		}
+	}
+	return chapter, true
+}
+
+func decodeID3Text(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	encoding, data := data[0], data[1:]
+	switch encoding {
+	case 1, 2:
+		order := binary.ByteOrder(binary.BigEndian)
+		if encoding == 1 && len(data) >= 2 {
+			if data[0] == 0xFF && data[1] == 0xFE {
+				order = binary.LittleEndian
+			}
+			data = data[2:]
+		}
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			u := order.Uint16(data[i:])
+			if u == 0 {
+				break
+			}
+			units = append(units, u)
+		}
+		return string(utf16.Decode(units))
+	case 3:
+		return strings.TrimRight(string(data), "\x00")
+	default:
+		// ISO-8859-1 maps one to one onto the first unicode code points
+		runes := make([]rune, 0, len(data))
+		for _, b := range data {
+			if b == 0 {
+				break
+			}
+			runes = append(runes, rune(b))
+		}
+		return string(runes)
+	}
+}
+
+func syncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7F)<<21 | uint32(b[1]&0x7F)<<14 | uint32(b[2]&0x7F)<<7 | uint32(b[3]&0x7F)
+}
+
+// readMP4Chapters reads the Nero chapter list of the moov atom, or else the QuickTime chapter
+// track most audiobook tools write.
+func readMP4Chapters(r io.ReadSeeker) ([]Chapter, error) {
+	moov, err := findTopLevelAtom(r, "moov")
+	if err != nil || moov == nil {
+		return nil, err
+	}
+	if chapters := parseCHPL(findAtom(findAtom(moov, "udta"), "chpl")); len(chapters) > 0 {
+		return chapters, nil
+	}
+	return readChapterTrack(r, moov)
+}
+
+const (
+	// maxChapterTitle caps the size of a sample of a chapter track, which holds a chapter title
+	maxChapterTitle = 1 << 12
+	// maxChapters caps the number of samples of a chapter track read
+	maxChapters = 1 << 14
+)
+
+// readChapterTrack reads the chapters of the text track a track of moov refers to with a
+// tref/chap atom. Every sample of the text track is a chapter lasting the sample duration.
+func readChapterTrack(r io.ReadSeeker, moov []byte) ([]Chapter, error) {
+	tracks := make(map[uint32][]byte)
+	var refs []uint32
+	for name, trak := range mp4Atoms(moov) {
+		if name != "trak" {
+			continue
+		}
+		if tkhd := findAtom(trak, "tkhd"); len(tkhd) >= 24 {
+			// the track ID follows the creation and modification times, 64-bit in version 1
+			at := 12
+			if tkhd[0] == 1 {
+				at = 20
+			}
+			tracks[binary.BigEndian.Uint32(tkhd[at:])] = trak
+		}
+		chap := findAtom(findAtom(trak, "tref"), "chap")
+		for i := 0; i+4 <= len(chap); i += 4 {
+			refs = append(refs, binary.BigEndian.Uint32(chap[i:]))
+		}
+	}
+	for _, id := range refs {
+		if trak, ok := tracks[id]; ok {
+			return readTextTrack(r, trak)
+		}
+	}
+	return nil, nil
+}
+
+func readTextTrack(r io.ReadSeeker, trak []byte) ([]Chapter, error) {
+	mdia := findAtom(trak, "mdia")
+	mdhd := findAtom(mdia, "mdhd")
+	if len(mdhd) < 24 {
+		return nil, nil
+	}
+	at := 12
+	if mdhd[0] == 1 {
+		at = 20
+	}
+	timescale := binary.BigEndian.Uint32(mdhd[at:])
+	if timescale == 0 {
+		return nil, nil
+	}
+
+	stbl := findAtom(findAtom(mdia, "minf"), "stbl")
+	offsets := sampleOffsets(stbl)
+	durations := sampleDurations(findAtom(stbl, "stts"), len(offsets))
+	chapters := make([]Chapter, 0, len(offsets))
+	var start uint64
+	for i, sample := range offsets {
+		if i >= len(durations) {
+			break
+		}
+		title, err := readTextSample(r, sample)
+		if err != nil {
+			return nil, err
+		}
+		end := start + uint64(durations[i])
+		chapters = append(chapters, Chapter{
+			Title: title,
+			Start: mp4Duration(start, timescale),
+			End:   mp4Duration(end, timescale),
+		})
+		start = end
+	}
+	return chapters, nil
+}
+
+func mp4Duration(units uint64, timescale uint32) time.Duration {
+	ts := uint64(timescale)
+	return time.Duration(units/ts)*time.Second + time.Duration(units%ts*uint64(time.Second)/ts)
+}
+
+// sampleOffsets returns the file offsets and sizes of the samples of a sample table, from
+// its sample sizes, sample-to-chunk and chunk offset tables.
+func sampleOffsets(stbl []byte) [][2]int64 {
+	stsz := findAtom(stbl, "stsz")
+	if len(stsz) < 12 {
+		return nil
+	}
+	fixed := binary.BigEndian.Uint32(stsz[4:])
+	count := min(int(binary.BigEndian.Uint32(stsz[8:])), maxChapters)
+	sizes := make([]int64, 0, count)
+	for i := range count {
+		if fixed != 0 {
+			sizes = append(sizes, int64(fixed))
+			continue
+		}
+		if 12+4*i+4 > len(stsz) {
+			return nil
+		}
+		sizes = append(sizes, int64(binary.BigEndian.Uint32(stsz[12+4*i:])))
+	}
+
+	var chunks []int64
+	if stco := mp4Table(findAtom(stbl, "stco"), 4); stco != nil {
+		for i := 0; i < len(stco); i += 4 {
+			chunks = append(chunks, int64(binary.BigEndian.Uint32(stco[i:])))
+		}
+	} else if co64 := mp4Table(findAtom(stbl, "co64"), 8); co64 != nil {
+		for i := 0; i < len(co64); i += 8 {
+			chunks = append(chunks, int64(binary.BigEndian.Uint64(co64[i:])))
+		}
+	}
+	stsc := mp4Table(findAtom(stbl, "stsc"), 12)
+
+	samples := make([][2]int64, 0, len(sizes))
+	for chunk, offset := range chunks {
+		// the last entry starting at or before the chunk, chunks are numbered from 1
+		perChunk := 0
+		for i := 0; i < len(stsc); i += 12 {
+			if int(binary.BigEndian.Uint32(stsc[i:])) > chunk+1 {
+				break
+			}
+			perChunk = int(binary.BigEndian.Uint32(stsc[i+4:]))
+		}
+		for range perChunk {
+			if len(samples) == len(sizes) {
+				return samples
+			}
+			size := sizes[len(samples)]
+			samples = append(samples, [2]int64{offset, size})
+			offset += size
+		}
+	}
+	return samples
+}
+
+// sampleDurations expands a time-to-sample table into the durations of its first n samples.
+func sampleDurations(stts []byte, n int) []uint32 {
+	table := mp4Table(stts, 8)
+	durations := make([]uint32, 0, n)
+	for i := 0; i < len(table); i += 8 {
+		count := binary.BigEndian.Uint32(table[i:])
+		delta := binary.BigEndian.Uint32(table[i+4:])
+		for range count {
+			if len(durations) == n {
+				return durations
+			}
+			durations = append(durations, delta)
+		}
+	}
+	return durations
+}
+
+// mp4Table returns the entries of a full atom made of an entry count and entries of size bytes,
+// or nil when it is truncated.
+func mp4Table(data []byte, size int) []byte {
+	if len(data) < 8 {
+		return nil
+	}
+	n := int(binary.BigEndian.Uint32(data[4:]))
+	if n > (len(data)-8)/size {
+		return nil
+	}
+	return data[8 : 8+n*size]
+}
+
+// readTextSample reads a text sample, a title prefixed with its length in UTF-8 or UTF-16 with
+// a byte order mark.
+func readTextSample(r io.ReadSeeker, sample [2]int64) (string, error) {
+	offset, size := sample[0], sample[1]
+	if size < 2 || size > maxChapterTitle {
+		return "", nil
+	}
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return "", err
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", err
+	}
+	text := data[2:][:min(int(binary.BigEndian.Uint16(data)), len(data)-2)]
+	if len(text) >= 2 && (text[0] == 0xFE && text[1] == 0xFF || text[0] == 0xFF && text[1] == 0xFE) {
+		// decodeID3Text reads UTF-16 with a byte order mark as ID3 text encoding 1
+		return decodeID3Text(append([]byte{1}, text...)), nil
+	}
+	return string(text), nil
+}
+
+// mp4Atoms yields the type and payload of the child atoms of an in-memory atom payload.
+func mp4Atoms(data []byte) iter.Seq2[string, []byte] {
+	return func(yield func(string, []byte) bool) {
+		for len(data) >= 8 {
+			size := int(binary.BigEndian.Uint32(data[:4]))
+			if size < 8 || size > len(data) {
+				return
+			}
+			if !yield(string(data[4:8]), data[8:size]) {
+				return
+			}
+			data = data[size:]
+		}
+	}
+}
+
+// maxAtomSize caps the size of an atom read into memory, the moov atom of an audio file is far
+// smaller.
+const maxAtomSize = 16 << 20
+
+// findTopLevelAtom returns the payload of the first top-level atom of the given type without
+// reading the (potentially huge) media data of the atoms before it.
+func findTopLevelAtom(r io.ReadSeeker, name string) ([]byte, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 16)
+	for pos := int64(0); ; {
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			// atom extends to the end of the file
+			size = end - pos
+		case 1:
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return nil, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if size < headerSize {
+			return nil, errors.New("invalid MP4 atom size")
+		}
+		if string(header[4:8]) == name {
+			if size-headerSize > maxAtomSize || size > end-pos {
+				return nil, fmt.Errorf("MP4 atom %s of %d bytes is too large", name, size)
+			}
+			payload := make([]byte, size-headerSize)
+			_, err := io.ReadFull(r, payload)
+			return payload, err
+		}
+		if size > end-pos {
+			return nil, nil
+		}
+		pos += size
+	}
+}
+
+// findAtom returns the payload of the first child atom of the given type in an in-memory atom payload.
+func findAtom(data []byte, name string) []byte {
+	for len(data) >= 8 {
+		size := int(binary.BigEndian.Uint32(data[:4]))
+		if size < 8 || size > len(data) {
+			return nil
+		}
+		if string(data[4:8]) == name {
+			return data[8:size]
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+// parseCHPL parses a Nero chapter list, chapter starts are stored in 100ns units.
+func parseCHPL(data []byte) []Chapter {
+	if len(data) < 5 {
+		return nil
+	}
+	version := data[0]
+	data = data[4:]
+	if version > 0 {
+		if len(data) < 4 {
+			return nil
+		}
+		data = data[4:]
+	}
+	if len(data) < 1 {
+		return nil
+	}
+	count := int(data[0])
+	data = data[1:]
+
+	chapters := make([]Chapter, 0, count)
+	for range count {
+		if len(data) < 9 {
+			break
+		}
+		start := binary.BigEndian.Uint64(data[:8])
+		titleLen := int(data[8])
+		data = data[9:]
+		if titleLen > len(data) {
+			break
+		}
+		chapters = append(chapters, Chapter{
+			Title: string(data[:titleLen]),
+			Start: time.Duration(start) * 100,
+		})
+		data = data[titleLen:]
+	}
+	sortChapters(chapters)
+	return chapters
+}
+
+func sortChapters(chapters []Chapter) {
+	slices.SortStableFunc(chapters, func(a, b Chapter) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+}
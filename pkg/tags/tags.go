@@ -1,9 +1,11 @@
 package tags
 
 import (
+	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/stkevintan/miko/models"
 	"github.com/stkevintan/miko/pkg/log"
 	"go.senan.xyz/taglib"
 )
@@ -112,21 +114,77 @@ const (
 	Work                      = "WORK"
 )
 
+// NeteaseID is the ID on NetEase Cloud Music of a song downloaded from it.
+const NeteaseID = "NETEASE_ID"
+
+// PartialDate is a possibly partial date, Month and Day are zero when the tag only carries a year.
+type PartialDate struct {
+	Year  int
+	Month int
+	Day   int
+}
+
+// IsZero reports whether no date was found.
+func (d PartialDate) IsZero() bool {
+	return d.Year == 0
+}
+
+// Contributor is a person credited on a track in a role other than the main artist.
+type Contributor struct {
+	Role    string
+	SubRole string
+	Name    string
+}
+
+// contributorTags maps tags to the contributor role they credit.
+var contributorTags = []struct {
+	tag  string
+	role string
+}{
+	{Composer, models.RoleComposer},
+	{Lyricist, models.RoleLyricist},
+	{Conductor, models.RoleConductor},
+	{Arranger, models.RoleArranger},
+	{Producer, models.RoleProducer},
+	{Remixer, models.RoleRemixer},
+	{DJMixer, models.RoleDJMixer},
+	{Mixer, models.RoleMixer},
+	{Engineer, models.RoleEngineer},
+	{Performer, models.RolePerformer},
+}
+
 type Tags struct {
-	Title        string
-	Artist       string
-	Artists      []string
-	Album        string
-	AlbumArtist  string
-	AlbumArtists []string
-	Track        int
-	Disc         int
-	Year         int
-	Genre        string
-	Genres       []string
-	Lyrics       string
-	Duration     int
-	Bitrate      int
+	Title            string
+	TitleSort        string
+	Artist           string
+	Artists          []string
+	ArtistSorts      []string
+	Album            string
+	AlbumSort        string
+	AlbumArtist      string
+	AlbumArtists     []string
+	AlbumArtistSorts []string
+	Track            int
+	Disc             int
+	DiscTotal        int
+	DiscSubtitle     string
+	Compilation      bool
+	ReleaseTypes     []string
+	MBAlbumID        string
+	Year             int
+	ReleaseDate      PartialDate
+	OriginalDate     PartialDate
+	Genre            string
+	Genres           []string
+	Composer         string
+	Contributors     []Contributor
+	Label            string
+	ISRC             []string
+	NeteaseID        int64
+	Comment          string
+	Lyrics           string
+	Duration         int
+	Bitrate          int
 }
 
 func Read(path string) (*Tags, error) {
@@ -162,20 +220,89 @@ func Read(path string) (*Tags, error) {
 		res.AlbumArtist = strings.Join(v, "; ")
 	}
 
+	// Sort names, multi-valued artist sorts line up with the artist values
+	if v, ok := t[taglib.TitleSort]; ok && len(v) > 0 {
+		res.TitleSort = v[0]
+	}
+	if v, ok := t[taglib.ArtistSort]; ok && len(v) > 0 {
+		res.ArtistSorts = v
+	}
+	if v, ok := t[taglib.AlbumSort]; ok && len(v) > 0 {
+		res.AlbumSort = v[0]
+	}
+	if v, ok := t[taglib.AlbumArtistSort]; ok && len(v) > 0 {
+		res.AlbumArtistSorts = v
+	}
+
 	if v, ok := t[taglib.TrackNumber]; ok && len(v) > 0 {
 		res.Track = parseTagInt("Track", v[0])
 	}
 	if v, ok := t[taglib.DiscNumber]; ok && len(v) > 0 {
 		res.Disc = parseTagInt("Disc", v[0])
+		// "1/2" style values carry the total as well
+		if _, total, found := strings.Cut(v[0], "/"); found {
+			res.DiscTotal = parseTagInt("DiscTotal", total)
+		}
+	}
+	if v, ok := t["DISCTOTAL"]; ok && len(v) > 0 {
+		res.DiscTotal = parseTagInt("DiscTotal", v[0])
+	} else if v, ok := t["TOTALDISCS"]; ok && len(v) > 0 {
+		res.DiscTotal = parseTagInt("DiscTotal", v[0])
+	}
+	if v, ok := t[taglib.DiscSubtitle]; ok && len(v) > 0 {
+		res.DiscSubtitle = v[0]
+	}
+	if v, ok := t[taglib.Compilation]; ok && len(v) > 0 {
+		res.Compilation = parseTagBool(v[0])
 	}
+	if v, ok := t[taglib.ReleaseType]; ok && len(v) > 0 {
+		res.ReleaseTypes = v
+	}
+	if v, ok := t[taglib.MusicBrainzAlbumID]; ok && len(v) > 0 {
+		res.MBAlbumID = strings.TrimSpace(v[0])
+	}
+	// DATE is the release date, RELEASEDATE is more specific when present
 	if v, ok := t[taglib.Date]; ok && len(v) > 0 {
-		res.Year = parseTagInt("Year", v[0])
+		res.ReleaseDate = parseTagDate(v[0])
+	} else if v, ok := t["YEAR"]; ok && len(v) > 0 {
+		res.ReleaseDate = parseTagDate(v[0])
+	}
+	if v, ok := t[taglib.ReleaseDate]; ok && len(v) > 0 {
+		if d := parseTagDate(v[0]); !d.IsZero() {
+			res.ReleaseDate = d
+		}
 	}
+	if v, ok := t[taglib.OriginalDate]; ok && len(v) > 0 {
+		res.OriginalDate = parseTagDate(v[0])
+	} else if v, ok := t["ORIGINALYEAR"]; ok && len(v) > 0 {
+		res.OriginalDate = parseTagDate(v[0])
+	}
+	res.Year = res.ReleaseDate.Year
 	if v, ok := t[taglib.Genre]; ok && len(v) > 0 {
 		res.Genres = v
 		res.Genre = strings.Join(v, "; ")
 	}
 
+	res.Contributors = parseContributors(t)
+	if v, ok := t[taglib.Composer]; ok && len(v) > 0 {
+		res.Composer = strings.Join(v, "; ")
+	}
+
+	if v, ok := t[taglib.Label]; ok && len(v) > 0 {
+		res.Label = strings.Join(v, "; ")
+	} else if v, ok := t["ORGANIZATION"]; ok && len(v) > 0 {
+		res.Label = strings.Join(v, "; ")
+	}
+	if v, ok := t[taglib.ISRC]; ok && len(v) > 0 {
+		res.ISRC = v
+	}
+	if v, ok := t[NeteaseID]; ok && len(v) > 0 {
+		res.NeteaseID, _ = strconv.ParseInt(v[0], 10, 64)
+	}
+	if v, ok := t[taglib.Comment]; ok && len(v) > 0 {
+		res.Comment = strings.Join(v, "\n")
+	}
+
 	// Lyrics
 	if v, ok := t[taglib.Lyrics]; ok && len(v) > 0 {
 		res.Lyrics = v[0]
@@ -192,6 +319,51 @@ func Read(path string) (*Tags, error) {
 	return res, nil
 }
 
+// parseContributors collects contributor credits. Performer credits come either as
+// "PERFORMER:INSTRUMENT" keys (ID3 TMCL) or as "Name (instrument)" values (Vorbis comments).
+func parseContributors(t map[string][]string) []Contributor {
+	var res []Contributor
+	for _, ct := range contributorTags {
+		for _, name := range t[ct.tag] {
+			var subRole string
+			if ct.role == models.RolePerformer {
+				name, subRole = splitPerformer(name)
+			}
+			if name = strings.TrimSpace(name); name != "" {
+				res = append(res, Contributor{Role: ct.role, SubRole: subRole, Name: name})
+			}
+		}
+	}
+
+	var instruments []string
+	for key := range t {
+		if instrument, found := strings.CutPrefix(key, Performer+":"); found {
+			instruments = append(instruments, instrument)
+		}
+	}
+	slices.Sort(instruments)
+	for _, instrument := range instruments {
+		for _, name := range t[Performer+":"+instrument] {
+			if name = strings.TrimSpace(name); name != "" {
+				res = append(res, Contributor{Role: models.RolePerformer, SubRole: strings.ToLower(instrument), Name: name})
+			}
+		}
+	}
+	return res
+}
+
+func splitPerformer(v string) (string, string) {
+	v = strings.TrimSpace(v)
+	if !strings.HasSuffix(v, ")") {
+		return v, ""
+	}
+	open := strings.LastIndex(v, "(")
+	if open <= 0 {
+		return v, ""
+	}
+	return strings.TrimSpace(v[:open]), strings.TrimSpace(v[open+1 : len(v)-1])
+}
+
 func ReadAll(path string) (map[string][]string, error) {
 	return taglib.ReadTags(path)
 }
@@ -216,6 +388,48 @@ func parseTagInt(name string, v string) int {
 	return i
 }
 
+// parseTagDate parses "YYYY", "YYYY-MM", "YYYY-MM-DD" and timestamps such as "YYYY-MM-DDThh:mm:ss".
+// Slashes and dots are accepted as separators, invalid components are dropped.
+func parseTagDate(v string) PartialDate {
+	v = strings.TrimSpace(v)
+	if before, _, found := strings.Cut(v, "T"); found {
+		v = before
+	}
+	parts := strings.FieldsFunc(v, func(r rune) bool {
+		return r == '-' || r == '/' || r == '.' || r == ' '
+	})
+
+	var d PartialDate
+	if len(parts) == 0 {
+		return d
+	}
+	year, err := strconv.Atoi(parts[0])
+	if err != nil || year <= 0 || year > 9999 {
+		log.Warn("Failed to parse date tag value %q", v)
+		return d
+	}
+	d.Year = year
+	if len(parts) > 1 {
+		if month, err := strconv.Atoi(parts[1]); err == nil && month >= 1 && month <= 12 {
+			d.Month = month
+		}
+	}
+	if d.Month > 0 && len(parts) > 2 {
+		if day, err := strconv.Atoi(parts[2]); err == nil && day >= 1 && day <= 31 {
+			d.Day = day
+		}
+	}
+	return d
+}
+
+func parseTagBool(v string) bool {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
 func ReadImage(path string) ([]byte, error) {
 	return taglib.ReadImage(path)
 }
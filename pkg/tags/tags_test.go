@@ -0,0 +1,157 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseTagDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want PartialDate
+	}{
+		{"2019", PartialDate{Year: 2019}},
+		{"2019-05", PartialDate{Year: 2019, Month: 5}},
+		{"2019-05-03", PartialDate{Year: 2019, Month: 5, Day: 3}},
+		{"2019-05-03T10:00:00Z", PartialDate{Year: 2019, Month: 5, Day: 3}},
+		{"2019/5/3", PartialDate{Year: 2019, Month: 5, Day: 3}},
+		{"2019-13-03", PartialDate{Year: 2019}},
+		{"", PartialDate{}},
+		{"unknown", PartialDate{}},
+	}
+	for _, tt := range tests {
+		if got := parseTagDate(tt.in); got != tt.want {
+			t.Errorf("parseTagDate(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseContributors(t *testing.T) {
+	got := parseContributors(map[string][]string{
+		Composer:              {"Johann Sebastian Bach"},
+		Performer:             {"Glenn Gould (piano)", "Wiener Philharmoniker"},
+		Performer + ":VIOLIN": {"Hilary Hahn"},
+	})
+	want := []Contributor{
+		{Role: "composer", Name: "Johann Sebastian Bach"},
+		{Role: "performer", SubRole: "piano", Name: "Glenn Gould"},
+		{Role: "performer", Name: "Wiener Philharmoniker"},
+		{Role: "performer", SubRole: "violin", Name: "Hilary Hahn"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("parseContributors() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseCHPL(t *testing.T) {
+	data := []byte{1, 0, 0, 0, 0, 0, 0, 0, 2}
+	data = append(data, 0, 0, 0, 0, 0, 0, 0, 0, 5)
+	data = append(data, "Intro"...)
+	data = append(data, 0, 0, 0, 0, 0x05, 0xF5, 0xE1, 0x00, 3) // 10s in 100ns units
+	data = append(data, "One"...)
+
+	got := parseCHPL(data)
+	want := []Chapter{
+		{Title: "Intro", Start: 0},
+		{Title: "One", Start: 10 * time.Second},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("parseCHPL() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFindTopLevelAtom(t *testing.T) {
+	file := []byte{0, 0, 0, 12, 'f', 't', 'y', 'p', 'M', '4', 'B', ' '}
+	file = append(file, 0, 0, 0, 11, 'm', 'o', 'o', 'v', 'a', 'b', 'c')
+	if got, err := findTopLevelAtom(bytes.NewReader(file), "moov"); err != nil || string(got) != "abc" {
+		t.Errorf("findTopLevelAtom() = %q, %v, want the moov payload", got, err)
+	}
+	if got, err := findTopLevelAtom(bytes.NewReader(file), "udta"); err != nil || got != nil {
+		t.Errorf("findTopLevelAtom() of a missing atom = %q, %v", got, err)
+	}
+
+	// a 64-bit size far beyond the end of the file
+	huge := append(file[:12:12], 0, 0, 0, 1, 'm', 'o', 'o', 'v', 0x7F, 0, 0, 0, 0, 0, 0, 0)
+	if _, err := findTopLevelAtom(bytes.NewReader(huge), "moov"); err == nil {
+		t.Error("findTopLevelAtom() of an atom larger than the file succeeded")
+	}
+	large := append(file[:12:12], 0x01, 0x00, 0x00, 0x10, 'm', 'o', 'o', 'v')
+	large = append(large, make([]byte, 16<<20+8)...)
+	if _, err := findTopLevelAtom(bytes.NewReader(large), "moov"); err == nil {
+		t.Error("findTopLevelAtom() of an atom over the size cap succeeded")
+	}
+}
+
+func mp4Atom(name string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	return append(binary.BigEndian.AppendUint32(nil, uint32(8+len(body))), append([]byte(name), body...)...)
+}
+
+func u32s(values ...uint32) []byte {
+	var b []byte
+	for _, v := range values {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	return b
+}
+
+// chapterTrackM4B builds an audiobook whose chapters are in a QuickTime chapter track, the
+// text samples of track 2 which the audio track 1 refers to.
+func chapterTrackM4B(titles []string, durations []uint32) []byte {
+	var samples []byte
+	var sizes []uint32
+	for _, title := range titles {
+		sample := append(binary.BigEndian.AppendUint16(nil, uint16(len(title))), title...)
+		samples = append(samples, sample...)
+		sizes = append(sizes, uint32(len(sample)))
+	}
+	ftyp := mp4Atom("ftyp", []byte("M4B "))
+	moov := func(mdat uint32) []byte {
+		var stts []byte
+		for _, d := range durations {
+			stts = append(stts, u32s(1, d)...)
+		}
+		audio := mp4Atom("trak",
+			mp4Atom("tkhd", u32s(0, 0, 0, 1, 0, 0)),
+			mp4Atom("tref", mp4Atom("chap", u32s(2))))
+		text := mp4Atom("trak",
+			mp4Atom("tkhd", u32s(0, 0, 0, 2, 0, 0)),
+			mp4Atom("mdia",
+				mp4Atom("mdhd", u32s(0, 0, 0, 1000, 0, 0)),
+				mp4Atom("minf", mp4Atom("stbl",
+					mp4Atom("stts", u32s(0, uint32(len(durations))), stts),
+					mp4Atom("stsz", u32s(0, 0, uint32(len(sizes))), u32s(sizes...)),
+					// two samples in the first chunk, the others one per chunk
+					mp4Atom("stsc", u32s(0, 2, 1, 2, 1, 2, 1, 1)),
+					mp4Atom("stco", u32s(0, 2, mdat, mdat+sizes[0]+sizes[1]))))))
+		return mp4Atom("moov", audio, text)
+	}
+	mdat := uint32(len(ftyp) + len(moov(0)) + 8)
+	return bytes.Join([][]byte{ftyp, moov(mdat), mp4Atom("mdat", samples)}, nil)
+}
+
+func TestReadChapters_ChapterTrack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.m4b")
+	file := chapterTrackM4B([]string{"Opening", "Chapter 1", "Chapter 2"}, []uint32{1500, 60000, 30000})
+	if err := os.WriteFile(path, file, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadChapters(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Chapter{
+		{Title: "Opening", Start: 0, End: 1500 * time.Millisecond},
+		{Title: "Chapter 1", Start: 1500 * time.Millisecond, End: 61500 * time.Millisecond},
+		{Title: "Chapter 2", Start: 61500 * time.Millisecond, End: 91500 * time.Millisecond},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ReadChapters() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,10 @@
+package types
+
+// ArtistInfo is what a music platform knows about an artist.
+type ArtistInfo struct {
+	Artist
+	Description string   `json:"description"`
+	PicUrl      string   `json:"picUrl"`
+	URL         string   `json:"url"`
+	Similar     []Artist `json:"similar"`
+}
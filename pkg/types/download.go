@@ -1,9 +1,85 @@
 package types
 
+import (
+	"sync"
+	"time"
+)
+
 type DownloadConfig struct {
 	Level          string
 	Output         string
 	ConflictPolicy string
+	// PathTemplate names the downloaded files inside Output, they are named after the artists
+	// and title of the song directly in Output when it is nil
+	PathTemplate *PathTemplate
+	// OnProgress, when set, is called as the songs go through the phases of their download
+	OnProgress func(DownloadProgress)
+}
+
+// Report calls OnProgress, if any.
+func (c *DownloadConfig) Report(p DownloadProgress) {
+	if c != nil && c.OnProgress != nil {
+		c.OnProgress(p)
+	}
+}
+
+// Phases of the download of a song
+const (
+	PhaseResolving   = "resolving"
+	PhaseDownloading = "downloading"
+	PhaseVerifying   = "verifying"
+	PhaseTagging     = "tagging"
+	PhaseDone        = "done"
+	PhaseFailed      = "failed"
+)
+
+// DownloadProgress reports the phase of the download of a song, the bytes written so far while
+// downloading, and the result or error once it is done or failed.
+type DownloadProgress struct {
+	MusicId    int64            `json:"musicId"`
+	Name       string           `json:"name"`
+	Phase      string           `json:"phase"`
+	BytesDone  int64            `json:"bytesDone"`
+	BytesTotal int64            `json:"bytesTotal"`
+	Result     *DownloadedMusic `json:"result,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// progressInterval is the minimum time between two reports of the bytes written
+const progressInterval = 250 * time.Millisecond
+
+// ProgressWriter counts the bytes written through it and reports them, at most every
+// progressInterval and once more on Flush.
+type ProgressWriter struct {
+	progress DownloadProgress
+	report   func(DownloadProgress)
+	mu       sync.Mutex
+	last     time.Time
+}
+
+// NewProgressWriter reports the downloading phase of progress with report.
+func NewProgressWriter(progress DownloadProgress, report func(DownloadProgress)) *ProgressWriter {
+	progress.Phase = PhaseDownloading
+	progress.BytesDone = 0
+	return &ProgressWriter{progress: progress, report: report}
+}
+
+func (w *ProgressWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.progress.BytesDone += int64(len(p))
+	if now := time.Now(); now.Sub(w.last) >= progressInterval {
+		w.last = now
+		w.report(w.progress)
+	}
+	return len(p), nil
+}
+
+// Flush reports the bytes written so far.
+func (w *ProgressWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.report(w.progress)
 }
 
 type DownloadResult struct {
@@ -35,3 +35,21 @@ func TestMusicDownloadResults_SuccessCount_NilEntriesAreSafe(t *testing.T) {
 type assertErr struct{}
 
 func (assertErr) Error() string { return "err" }
+
+func TestProgressWriter(t *testing.T) {
+	var reports []DownloadProgress
+	w := NewProgressWriter(DownloadProgress{MusicId: 1, BytesTotal: 10}, func(p DownloadProgress) {
+		reports = append(reports, p)
+	})
+	w.Write(make([]byte, 4))
+	w.Write(make([]byte, 6))
+	w.Flush()
+
+	if len(reports) != 2 {
+		t.Fatalf("expected the first write and the flush to be reported, got %d reports", len(reports))
+	}
+	last := reports[len(reports)-1]
+	if last.Phase != PhaseDownloading || last.BytesDone != 10 || last.BytesTotal != 10 || last.MusicId != 1 {
+		t.Fatalf("unexpected last report %+v", last)
+	}
+}
@@ -15,6 +15,8 @@ type Album struct {
 	Id     int64  `json:"id"`
 	Name   string `json:"name"`
 	PicUrl string `json:"picUrl"`
+	Artist string `json:"artist,omitempty"`
+	Year   int    `json:"year,omitempty"`
 }
 
 // Music represents a music track
@@ -26,6 +28,7 @@ type Music struct {
 	Time        int64    `json:"time"`
 	Lyrics      string   `json:"lyrics"`
 	TrackNumber string   `json:"trackNumber"`
+	Disc        string   `json:"disc,omitempty"`
 }
 
 type DownloadInfo struct {
@@ -36,9 +39,11 @@ type DownloadInfo struct {
 	Quality  string `json:"quality"`
 }
 
+// DownloadedMusic is a downloaded song, SongID is its ID in the library once it is indexed.
 type DownloadedMusic struct {
 	Music
 	DownloadInfo
+	SongID string `json:"songId,omitempty"`
 }
 
 func (m Music) ArtistString() string {
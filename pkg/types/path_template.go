@@ -0,0 +1,187 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSegmentBytes is the length limit of a file or directory name, the limit of most file systems
+const maxSegmentBytes = 255
+
+// unsafeRegexp matches the characters that are illegal in file names on some platform
+var unsafeRegexp = regexp.MustCompile(`[\\/:*?"<>|\x00-\x1f\x7f]`)
+
+// PathTemplate names the files of downloaded songs relative to the output directory, for
+// example "{albumartist}/{year} - {album}/{disc:02}-{track:02} {title}.{ext}". Fields are
+// artist, albumartist, album, title, year, disc, track, id and ext, numbers can be zero padded
+// with a width such as {track:02}. "/" separates directories.
+type PathTemplate struct {
+	raw   string
+	parts []templatePart
+}
+
+type templatePart struct {
+	literal string
+	field   string
+	width   int
+}
+
+var templateFields = map[string]bool{
+	"artist": true, "albumartist": true, "album": true, "title": true,
+	"year": true, "disc": true, "track": true, "id": true, "ext": true,
+}
+
+// ParsePathTemplate parses a path template.
+func ParsePathTemplate(s string) (*PathTemplate, error) {
+	t := &PathTemplate{raw: s}
+	for rest := s; rest != ""; {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			t.parts = append(t.parts, templatePart{literal: rest})
+			break
+		}
+		if open > 0 {
+			t.parts = append(t.parts, templatePart{literal: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed { in path template %q", s)
+		}
+		name, format, _ := strings.Cut(rest[open+1:open+end], ":")
+		if !templateFields[name] {
+			return nil, fmt.Errorf("unknown field {%s} in path template %q", name, s)
+		}
+		part := templatePart{field: name}
+		if format != "" {
+			width, err := strconv.Atoi(format)
+			if err != nil || width < 0 || width > 9 {
+				return nil, fmt.Errorf("invalid width {%s:%s} in path template %q", name, format, s)
+			}
+			part.width = width
+		}
+		t.parts = append(t.parts, part)
+		rest = rest[open+end+1:]
+	}
+	if !strings.Contains(s, "{ext}") {
+		return nil, fmt.Errorf("path template %q must contain {ext}", s)
+	}
+	return t, nil
+}
+
+func (t *PathTemplate) String() string {
+	return t.raw
+}
+
+// Path renders the relative path of the file of music, a positive index is added to the file
+// name to avoid a conflict with an existing file. Values can't add directories, illegal
+// characters are replaced and every directory and file name is cut to the length limit.
+func (t *PathTemplate) Path(m *Music, extType string, index int) string {
+	ext := strings.ToLower(extType)
+	var b strings.Builder
+	for _, part := range t.parts {
+		if part.field == "" {
+			b.WriteString(part.literal)
+			continue
+		}
+		b.WriteString(sanitize(t.value(m, part, ext)))
+	}
+
+	segments := strings.Split(b.String(), "/")
+	clean := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		if last {
+			segment = strings.TrimSuffix(segment, "."+ext)
+		}
+		segment = cleanSegment(segment)
+		if segment == "" && !last {
+			continue
+		}
+		if last {
+			if segment == "" {
+				segment = strconv.FormatInt(m.Id, 10)
+			}
+			if index > 0 {
+				segment = fmt.Sprintf("%s (%d)", segment, index)
+			}
+			suffix := "." + ext
+			segment = truncate(segment, maxSegmentBytes-len(suffix)) + suffix
+		} else {
+			segment = truncate(segment, maxSegmentBytes)
+		}
+		clean = append(clean, segment)
+	}
+	return strings.Join(clean, "/")
+}
+
+func (t *PathTemplate) value(m *Music, part templatePart, ext string) string {
+	number := func(s string) string {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n <= 0 {
+			return ""
+		}
+		return fmt.Sprintf("%0*d", part.width, n)
+	}
+	switch part.field {
+	case "artist":
+		return m.artistNames()
+	case "albumartist":
+		if m.Album.Artist != "" {
+			return m.Album.Artist
+		}
+		if len(m.Artist) > 0 {
+			return m.Artist[0].Name
+		}
+		return ""
+	case "album":
+		return m.Album.Name
+	case "title":
+		return m.Name
+	case "year":
+		return number(strconv.Itoa(m.Album.Year))
+	case "disc":
+		return number(m.Disc)
+	case "track":
+		return number(m.TrackNumber)
+	case "id":
+		return strconv.FormatInt(m.Id, 10)
+	case "ext":
+		return ext
+	}
+	return ""
+}
+
+// artistNames joins the names of the artists for display.
+func (m *Music) artistNames() string {
+	names := make([]string, 0, len(m.Artist))
+	for _, ar := range m.Artist {
+		names = append(names, ar.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// sanitize makes a value safe to use in a file name.
+func sanitize(value string) string {
+	return unsafeRegexp.ReplaceAllString(strings.TrimSpace(value), "_")
+}
+
+// cleanSegment trims the separators left by empty values, such as the " - " of
+// "{year} - {album}" without a year, and the dots that would hide the file, refer to a parent
+// directory or be dropped by Windows.
+func cleanSegment(segment string) string {
+	return strings.Trim(segment, " -_.")
+}
+
+// truncate cuts s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return strings.TrimRight(s[:n], ". ")
+}
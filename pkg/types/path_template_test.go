@@ -0,0 +1,61 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePathTemplate_Errors(t *testing.T) {
+	for _, s := range []string{
+		"{title}",
+		"{title.{ext}",
+		"{genre}/{title}.{ext}",
+		"{track:x} {title}.{ext}",
+	} {
+		if _, err := ParsePathTemplate(s); err == nil {
+			t.Errorf("ParsePathTemplate(%q): expected an error", s)
+		}
+	}
+}
+
+func TestPathTemplate_Path(t *testing.T) {
+	tpl, err := ParsePathTemplate("{albumartist}/{year} - {album}/{disc:02}-{track:02} {title}.{ext}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Music{
+		Id:          42,
+		Name:        "What? Now/Then",
+		Artist:      []Artist{{Name: "A"}, {Name: "B"}},
+		Album:       Album{Name: "..", Year: 2020},
+		TrackNumber: "3",
+		Disc:        "1",
+	}
+	if got, want := tpl.Path(m, "FLAC", 0), "A/2020/01-03 What_ Now_Then.flac"; got != want {
+		t.Errorf("Path() = %q, want %q", got, want)
+	}
+	if got, want := tpl.Path(m, "flac", 2), "A/2020/01-03 What_ Now_Then (2).flac"; got != want {
+		t.Errorf("Path() with index = %q, want %q", got, want)
+	}
+
+	m = &Music{Id: 42, Album: Album{Artist: "Various"}}
+	if got, want := tpl.Path(m, "mp3", 0), "Various/42.mp3"; got != want {
+		t.Errorf("Path() without tags = %q, want %q", got, want)
+	}
+}
+
+func TestPathTemplate_PathTruncates(t *testing.T) {
+	tpl, err := ParsePathTemplate("{album}/{title}.{ext}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	long := strings.Repeat("音", 200)
+	path := tpl.Path(&Music{Name: long, Album: Album{Name: long}}, "flac", 0)
+	dir, file, _ := strings.Cut(path, "/")
+	if len(dir) > maxSegmentBytes || len(file) > maxSegmentBytes {
+		t.Fatalf("segments exceed %d bytes: %d, %d", maxSegmentBytes, len(dir), len(file))
+	}
+	if !strings.HasSuffix(file, "音.flac") {
+		t.Errorf("expected the extension to be kept, got %q", file)
+	}
+}
@@ -2,17 +2,21 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"path/filepath"
 	"strconv"
 	"time"
 
 	"github.com/stkevintan/miko/config"
 	"github.com/stkevintan/miko/models"
 	"github.com/stkevintan/miko/pkg/di"
+	"github.com/stkevintan/miko/pkg/downloads"
+	"github.com/stkevintan/miko/pkg/events"
 	"github.com/stkevintan/miko/pkg/provider"
+	"github.com/stkevintan/miko/pkg/scanner"
 	"github.com/stkevintan/miko/pkg/types"
+	"gorm.io/gorm"
 )
 
 // handleDownload handles music download requests
@@ -54,15 +58,29 @@ func (h *Handler) handleDownload(w http.ResponseWriter, r *http.Request) {
 	// Convert timeout from milliseconds to duration
 	timeout := time.Duration(timeoutMs) * time.Millisecond
 
-	req := &DownloadRequest{
-		URIs:     uris,
-		Timeout:  timeout,
-		Platform: platform,
-		DownloadConfig: types.DownloadConfig{
-			Level:          level,
-			Output:         output,
-			ConflictPolicy: conflictPolicy,
+	username := string(di.MustInvoke[models.Username](r.Context()))
+	bus := di.MustInvoke[*events.Bus](r.Context())
+	dc, err := libraryDownloadConfig(r.Context(), types.DownloadConfig{
+		Level:          level,
+		Output:         output,
+		ConflictPolicy: conflictPolicy,
+		OnProgress: func(progress types.DownloadProgress) {
+			bus.Publish(events.Event{
+				Username: username,
+				Type:     downloads.EventProgress,
+				Data:     downloads.Progress{DownloadProgress: progress},
+			})
 		},
+	})
+	if err != nil {
+		downloadConfigError(w, err)
+		return
+	}
+	req := &DownloadRequest{
+		URIs:           uris,
+		Timeout:        timeout,
+		Platform:       platform,
+		DownloadConfig: dc,
 	}
 
 	ctx, err := h.getApiRequestContext(r)
@@ -102,6 +120,40 @@ func (h *Handler) handleDownload(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// libraryDownloadConfig resolves the output of dc among the directories the user may download
+// to, the configured music folder by default, and names the files with the configured path
+// template. It fails with downloads.ErrOutputNotAllowed outside of them.
+func libraryDownloadConfig(ctx context.Context, dc types.DownloadConfig) (types.DownloadConfig, error) {
+	cfg := di.MustInvoke[*config.Config](ctx).Download
+	db := di.MustInvoke[*gorm.DB](ctx)
+	username := string(di.MustInvoke[models.Username](ctx))
+
+	var user models.User
+	if err := db.Preload("MusicFolders").Where("username = ?", username).First(&user).Error; err != nil {
+		return dc, fmt.Errorf("load user: %w", err)
+	}
+	var folders []models.MusicFolder
+	if err := db.Find(&folders).Error; err != nil {
+		return dc, fmt.Errorf("load music folders: %w", err)
+	}
+	output, err := downloads.ResolveOutput(dc.Output, downloads.Roots(cfg, &user, folders))
+	if err != nil {
+		return dc, err
+	}
+	dc.Output = output
+	dc.PathTemplate = cfg.PathTemplate()
+	return dc, nil
+}
+
+// downloadConfigError writes the error of libraryDownloadConfig.
+func downloadConfigError(w http.ResponseWriter, err error) {
+	if errors.Is(err, downloads.ErrOutputNotAllowed) {
+		JSON(w, http.StatusForbidden, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+}
+
 // DownloadRequest represents download arguments for any resource type
 type DownloadRequest struct {
 	types.DownloadConfig
@@ -116,14 +168,6 @@ func (r *DownloadRequest) Download(ctx context.Context) (*types.MusicDownloadRes
 		cancel context.CancelFunc
 	)
 
-	if r.Output != "" && !filepath.IsAbs(r.Output) {
-		abs, err := filepath.Abs(r.Output)
-		if err != nil {
-			return nil, fmt.Errorf("resolve output path: %w", err)
-		}
-		r.Output = abs
-	}
-
 	if r.Timeout == 0 {
 		nctx, cancel = context.WithCancel(ctx)
 	} else {
@@ -142,5 +186,10 @@ func (r *DownloadRequest) Download(ctx context.Context) (*types.MusicDownloadRes
 		return nil, fmt.Errorf("GetMusic: %w", err)
 	}
 
-	return provider.Download(nctx, music, &r.DownloadConfig)
+	results, err := provider.Download(nctx, music, &r.DownloadConfig)
+	if err != nil {
+		return nil, err
+	}
+	di.MustInvoke[*scanner.Scanner](ctx).IndexDownloads(ctx, results.Results())
+	return results, nil
 }
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stkevintan/miko/config"
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/di"
+	"github.com/stkevintan/miko/pkg/downloads"
+	"github.com/stkevintan/miko/pkg/types"
+	"gorm.io/gorm"
+)
+
+// CreateDownloadJobRequest queues the download of the songs, albums or playlists of URIs.
+type CreateDownloadJobRequest struct {
+	URIs           []string `json:"uris"`
+	Platform       string   `json:"platform,omitempty"`
+	Level          string   `json:"level,omitempty"`
+	Output         string   `json:"output,omitempty"`
+	ConflictPolicy string   `json:"conflictPolicy,omitempty"`
+}
+
+func (h *Handler) handleCreateDownloadJob(w http.ResponseWriter, r *http.Request) {
+	var req CreateDownloadJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if len(req.URIs) == 0 {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "uris is required"})
+		return
+	}
+	if req.Level == "" {
+		req.Level = "lossless"
+	}
+	if req.ConflictPolicy == "" {
+		req.ConflictPolicy = "skip"
+	}
+	if _, err := types.ParseConflictPolicy(req.ConflictPolicy); err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if req.Platform == "" {
+		req.Platform = di.MustInvoke[*config.Config](r.Context()).Provider.Platform
+	}
+
+	dc, err := libraryDownloadConfig(r.Context(), types.DownloadConfig{
+		Level:          req.Level,
+		Output:         req.Output,
+		ConflictPolicy: req.ConflictPolicy,
+	})
+	if err != nil {
+		downloadConfigError(w, err)
+		return
+	}
+
+	username := string(di.MustInvoke[models.Username](r.Context()))
+	job, err := di.MustInvoke[*downloads.Queue](r.Context()).Enqueue(username, req.Platform, req.URIs, dc)
+	if err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to queue download: " + err.Error()})
+		return
+	}
+	JSON(w, http.StatusAccepted, job)
+}
+
+func (h *Handler) handleGetDownloadJobs(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	username := string(di.MustInvoke[models.Username](r.Context()))
+	jobs, err := di.MustInvoke[*downloads.Queue](r.Context()).Jobs(username, limit, max(offset, 0))
+	if err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to load download jobs: " + err.Error()})
+		return
+	}
+	JSON(w, http.StatusOK, jobs)
+}
+
+func (h *Handler) handleGetDownloadJob(w http.ResponseWriter, r *http.Request) {
+	h.downloadJobAction(w, r, (*downloads.Queue).Job)
+}
+
+func (h *Handler) handleRetryDownloadJob(w http.ResponseWriter, r *http.Request) {
+	h.downloadJobAction(w, r, (*downloads.Queue).Retry)
+}
+
+func (h *Handler) handlePauseDownloadJob(w http.ResponseWriter, r *http.Request) {
+	h.downloadJobAction(w, r, (*downloads.Queue).Pause)
+}
+
+func (h *Handler) handleResumeDownloadJob(w http.ResponseWriter, r *http.Request) {
+	h.downloadJobAction(w, r, (*downloads.Queue).Resume)
+}
+
+func (h *Handler) handleCancelDownloadJob(w http.ResponseWriter, r *http.Request) {
+	h.downloadJobAction(w, r, (*downloads.Queue).Cancel)
+}
+
+// downloadJobAction runs action on the download job of the id URL parameter and responds with
+// the job.
+func (h *Handler) downloadJobAction(w http.ResponseWriter, r *http.Request, action func(*downloads.Queue, string, uint) (*models.DownloadJob, error)) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid job ID"})
+		return
+	}
+
+	username := string(di.MustInvoke[models.Username](r.Context()))
+	job, err := action(di.MustInvoke[*downloads.Queue](r.Context()), username, uint(id))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		JSON(w, http.StatusNotFound, models.ErrorResponse{Error: "Download job not found"})
+	case errors.Is(err, downloads.ErrInvalidState):
+		JSON(w, http.StatusConflict, models.ErrorResponse{Error: err.Error()})
+	case err != nil:
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to update download job: " + err.Error()})
+	default:
+		JSON(w, http.StatusOK, job)
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/di"
+	"github.com/stkevintan/miko/pkg/events"
+)
+
+// eventsKeepAlive is how often a comment is sent to keep idle event streams open through proxies
+const eventsKeepAlive = 15 * time.Second
+
+// handleEvents streams the events of the user, such as download progress, as Server-Sent
+// Events. Browsers pass the token as a query parameter since EventSource can't set headers.
+func (h *Handler) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Streaming is not supported"})
+		return
+	}
+
+	username := string(di.MustInvoke[models.Username](r.Context()))
+	ch, unsubscribe := di.MustInvoke[*events.Bus](r.Context()).Subscribe(username)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	ticker := time.NewTicker(eventsKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.ctx.Done():
+			// the server is shutting down
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case e := <-ch:
+			data, err := json.Marshal(e.Data)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+		}
+		flusher.Flush()
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/stkevintan/miko/pkg/cookiecloud"
 	"github.com/stkevintan/miko/pkg/di"
 	"github.com/stkevintan/miko/pkg/netease"
+	"github.com/stkevintan/miko/pkg/provider"
 	"gorm.io/gorm"
 )
 
@@ -26,20 +27,13 @@ func New(ctx context.Context) *Handler {
 func (h *Handler) getApiRequestContext(r *http.Request) (context.Context, error) {
 	ctx := r.Context()
 	username := string(di.MustInvoke[models.Username](ctx))
-	db := di.MustInvoke[*gorm.DB](ctx)
-	cfg := di.MustInvoke[*config.Config](ctx)
 
 	// create a sub context to provide scoped dependencies
 	ctx = di.Inherit(ctx)
 
-	var identity cookiecloud.Identity
-	if err := db.Model(&cookiecloud.Identity{}).Select("username, uuid, password").Where("username = ?", username).First(&identity).Error; err != nil {
-		return nil, fmt.Errorf("failed to find identity for user %s: %w", username, err)
-	}
-
-	jar, err := cookiecloud.NewCookieCloudJar(ctx, cfg.CookieCloud, &identity)
+	jar, err := userCookieJar(ctx, username)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+		return nil, err
 	}
 
 	di.Provide(ctx, jar)
@@ -55,6 +49,38 @@ func (h *Handler) getApiRequestContext(r *http.Request) (context.Context, error)
 	return ctx, nil
 }
 
+// NewUserProvider creates the provider of platform signed in with the cookies of the user,
+// for work done outside of a request such as queued downloads.
+func NewUserProvider(ctx context.Context, username, platform string) (provider.Provider, error) {
+	jar, err := userCookieJar(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	switch platform {
+	case "netease":
+		return netease.NewProvider(jar)
+	default:
+		return nil, fmt.Errorf("unknown platform %s", platform)
+	}
+}
+
+// userCookieJar creates the CookieCloud cookie jar of the user.
+func userCookieJar(ctx context.Context, username string) (cookiecloud.CookieJar, error) {
+	db := di.MustInvoke[*gorm.DB](ctx)
+	cfg := di.MustInvoke[*config.Config](ctx)
+
+	var identity cookiecloud.Identity
+	if err := db.Model(&cookiecloud.Identity{}).Select("username, uuid, password").Where("username = ?", username).First(&identity).Error; err != nil {
+		return nil, fmt.Errorf("failed to find identity for user %s: %w", username, err)
+	}
+
+	jar, err := cookiecloud.NewCookieCloudJar(ctx, cfg.CookieCloud, &identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+	}
+	return jar, nil
+}
+
 func (h *Handler) RegisterRoutes(r chi.Router) {
 	// for docker health check
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -72,13 +98,32 @@ func (h *Handler) RegisterRoutes(r chi.Router) {
 			r.Post("/cookiecloud/identity", h.handleCookiecloudIdentity)
 			r.Post("/cookiecloud/pull", h.handleCookiecloudPull)
 			r.Get("/download", h.handleDownload)
+			r.Post("/downloads", h.handleCreateDownloadJob)
+			r.Get("/downloads", h.handleGetDownloadJobs)
+			r.Get("/downloads/{id}", h.handleGetDownloadJob)
+			r.Post("/downloads/{id}/retry", h.handleRetryDownloadJob)
+			r.Post("/downloads/{id}/pause", h.handlePauseDownloadJob)
+			r.Post("/downloads/{id}/resume", h.handleResumeDownloadJob)
+			r.Post("/downloads/{id}/cancel", h.handleCancelDownloadJob)
+			r.Post("/subscriptions", h.handleCreateSubscription)
+			r.Get("/subscriptions", h.handleGetSubscriptions)
+			r.Get("/subscriptions/{id}", h.handleGetSubscription)
+			r.Put("/subscriptions/{id}", h.handleUpdateSubscription)
+			r.Delete("/subscriptions/{id}", h.handleDeleteSubscription)
+			r.Post("/subscriptions/{id}/sync", h.handleSyncSubscription)
+			r.Get("/subscriptions/{id}/history", h.handleGetSubscriptionHistory)
+			r.Get("/events", h.handleEvents)
 			r.Get("/platform/{platform}/user", h.handlePlatformUser)
 
 			// Library
 			r.Get("/library/folders", h.handleGetLibraryFolders)
 			r.Get("/library/directory", h.handleGetLibraryDirectory)
 			r.Get("/library/song", h.handleGetLibrarySong)
+			r.Get("/library/search", h.handleSearchLibrary)
 			r.Get("/library/coverArt", h.handleGetLibraryCoverArt)
+			r.Get("/library/contributors", h.handleGetLibraryContributors)
+			r.Get("/library/contributors/songs", h.handleGetLibraryContributorSongs)
+			r.Get("/library/topSongs", h.handleGetLibraryTopSongs)
 			r.Post("/library/scan", h.handleScanLibrary)
 			r.Post("/library/scan/all", h.handleScanAllLibrary)
 			r.Get("/library/status", h.handleGetStatus)
@@ -87,6 +132,29 @@ func (h *Handler) RegisterRoutes(r chi.Router) {
 			r.Get("/library/song/tags", h.handleGetLibrarySongTags)
 			r.Post("/library/song/update", h.handleUpdateLibrarySong)
 			r.Post("/library/song/cover", h.handleUpdateLibrarySongCover)
+
+			// Playlists
+			r.Post("/playlists/smart", h.handleCreateSmartPlaylist)
+			r.Put("/playlists/smart/{id}", h.handleUpdateSmartPlaylist)
+			r.Post("/playlists/import", h.handleImportPlaylist)
+			r.Get("/playlists/{id}/export", h.handleExportPlaylist)
+			r.Get("/playlists/{id}/collaborators", h.handleGetPlaylistCollaborators)
+			r.Put("/playlists/{id}/collaborators", h.handleSetPlaylistCollaborator)
+			r.Delete("/playlists/{id}/collaborators/{username}", h.handleRemovePlaylistCollaborator)
+			r.Get("/playlists/{id}/activity", h.handleGetPlaylistActivity)
+			r.Post("/playlists/{id}/cover", h.handleUploadPlaylistCover)
+			r.Delete("/playlists/{id}/cover", h.handleDeletePlaylistCover)
+
+			// Play history
+			r.Get("/history", h.handleGetHistory)
+			r.Get("/stats/top/{kind}", h.handleGetTopStats)
+			r.Get("/stats/heatmap", h.handleGetHeatMap)
+			r.Get("/stats/streaks", h.handleGetStreaks)
+
+			// Scrobbling accounts
+			r.Get("/scrobble/accounts", h.handleGetScrobbleAccounts)
+			r.Put("/scrobble/accounts/{service}", h.handleLinkScrobbleAccount)
+			r.Delete("/scrobble/accounts/{service}", h.handleUnlinkScrobbleAccount)
 		})
 	})
 }
@@ -2,7 +2,10 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -14,9 +17,13 @@ import (
 	"github.com/stkevintan/miko/pkg/browser"
 	"github.com/stkevintan/miko/pkg/di"
 	"github.com/stkevintan/miko/pkg/log"
+	"github.com/stkevintan/miko/pkg/playlists"
+	"github.com/stkevintan/miko/pkg/provider"
 	"github.com/stkevintan/miko/pkg/scanner"
 	"github.com/stkevintan/miko/pkg/scraper"
+	"github.com/stkevintan/miko/pkg/search"
 	"github.com/stkevintan/miko/pkg/tags"
+	"github.com/stkevintan/miko/pkg/types"
 	"gorm.io/gorm"
 )
 
@@ -85,7 +92,7 @@ func (h *Handler) handleUpdateLibrarySong(w http.ResponseWriter, r *http.Request
 			JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch updated song: " + err.Error()})
 			return
 		}
-		JSON(w, http.StatusOK, updatedSong)
+		JSON(w, http.StatusOK, newLibrarySong(updatedSong))
 	}
 
 }
@@ -202,6 +209,181 @@ func (h *Handler) handleGetLibraryDirectory(w http.ResponseWriter, r *http.Reque
 	JSON(w, http.StatusOK, dir)
 }
 
+func (h *Handler) handleGetLibraryContributors(w http.ResponseWriter, r *http.Request) {
+	role := r.URL.Query().Get("role")
+	if role == "" {
+		role = models.RoleComposer
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	br := di.MustInvoke[*browser.Browser](r.Context())
+	artists, err := br.GetContributors(role, r.URL.Query().Get("query"), limit, offset)
+	if err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to query contributors: " + err.Error()})
+		return
+	}
+
+	JSON(w, http.StatusOK, artists)
+}
+
+func (h *Handler) handleGetLibraryContributorSongs(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "ID is required"})
+		return
+	}
+	role := r.URL.Query().Get("role")
+	if role == "" {
+		role = models.RoleComposer
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	br := di.MustInvoke[*browser.Browser](r.Context())
+	songs, err := br.GetContributorSongs(id, role, limit, offset)
+	if err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to query songs: " + err.Error()})
+		return
+	}
+
+	JSON(w, http.StatusOK, songs)
+}
+
+type LibrarySearchResult struct {
+	Artists []models.ArtistID3  `json:"artists"`
+	Albums  []models.AlbumID3   `json:"albums"`
+	Songs   []LibrarySearchSong `json:"songs"`
+}
+
+// LibrarySong is a song with the extended tags Subsonic responses leave out.
+type LibrarySong struct {
+	models.Child
+	Label     string `json:"label,omitempty"`
+	Comment   string `json:"comment,omitempty"`
+	NeteaseID int64  `json:"neteaseId,omitempty"`
+}
+
+func newLibrarySong(song models.Child) LibrarySong {
+	return LibrarySong{Child: song, Label: song.Label, Comment: song.Comment, NeteaseID: song.NeteaseID}
+}
+
+// LibrarySearchSong is a song search hit together with the highlighted fields that matched.
+type LibrarySearchSong struct {
+	LibrarySong
+	Matches []search.Match `json:"matches,omitempty"`
+}
+
+func (h *Handler) handleSearchLibrary(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+
+	br := di.MustInvoke[*browser.Browser](r.Context())
+	artists, albums, songs, err := br.Search(browser.SearchOptions{
+		Query:        query.Get("query"),
+		ArtistCount:  limit,
+		ArtistOffset: offset,
+		AlbumCount:   limit,
+		AlbumOffset:  offset,
+		SongCount:    limit,
+		SongOffset:   offset,
+	})
+	if err != nil {
+		var queryErr *browser.QueryError
+		if errors.As(err, &queryErr) {
+			JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to search library: " + err.Error()})
+		return
+	}
+
+	matches, err := br.SearchMatches(query.Get("query"), songs)
+	if err != nil {
+		log.Warn("Failed to highlight search matches: %v", err)
+	}
+	hits := make([]LibrarySearchSong, len(songs))
+	for i, song := range songs {
+		hits[i] = LibrarySearchSong{LibrarySong: newLibrarySong(song), Matches: matches[song.ID]}
+	}
+
+	JSON(w, http.StatusOK, LibrarySearchResult{
+		Artists: artists,
+		Albums:  albums,
+		Songs:   hits,
+	})
+}
+
+// handleGetLibraryTopSongs ranks the songs of an artist by their plays. With provider=true and
+// fewer played songs than requested, the list is completed with the top songs of the provider,
+// including the ones missing from the library so they can be downloaded.
+func (h *Handler) handleGetLibraryTopSongs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	artist := query.Get("artist")
+	if artist == "" {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Artist is required"})
+		return
+	}
+	count, err := strconv.Atoi(query.Get("count"))
+	if err != nil || count <= 0 {
+		count = 50
+	}
+
+	br := di.MustInvoke[*browser.Browser](r.Context())
+	// rank every song of the artist, provider songs are matched against all of them
+	local, err := br.TopSongs(artist, math.MaxInt)
+	if err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to fetch top songs: " + err.Error()})
+		return
+	}
+
+	var remote []*types.Music
+	played := 0
+	for _, song := range local {
+		if song.PlayCount > 0 || song.UserRating > 0 {
+			played++
+		}
+	}
+	if query.Get("provider") == "true" && played < count {
+		remote, err = h.providerTopSongs(r, artist, count)
+		if err != nil {
+			log.Warn("Failed to fetch top songs of %s from provider: %v", artist, err)
+		}
+	}
+	JSON(w, http.StatusOK, browser.MergeTopSongs(local, remote, count))
+}
+
+func (h *Handler) providerTopSongs(r *http.Request, artist string, count int) ([]*types.Music, error) {
+	ctx, err := h.getApiRequestContext(r)
+	if err != nil {
+		return nil, err
+	}
+	platform := r.URL.Query().Get("platform")
+	if platform == "" {
+		platform = di.MustInvoke[*config.Config](ctx).Provider.Platform
+	}
+	p, err := di.InvokeNamed[provider.Provider](ctx, platform)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Close(ctx)
+	ap, ok := p.(provider.ArtistProvider)
+	if !ok {
+		return nil, fmt.Errorf("%s doesn't provide top songs of artists", platform)
+	}
+	return ap.ArtistTopSongs(ctx, artist, count)
+}
+
 func (h *Handler) handleGetLibraryCoverArt(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	if id == "" {
@@ -209,6 +391,24 @@ func (h *Handler) handleGetLibraryCoverArt(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	cfg := di.MustInvoke[*config.Config](r.Context())
+	cacheDir := scanner.GetCoverCacheDir(cfg)
+
+	if playlistID, ok := playlists.ParseCoverArtID(id); ok {
+		br := di.MustInvoke[*browser.Browser](r.Context())
+		username := string(di.MustInvoke[models.Username](r.Context()))
+		path, err := br.GetPlaylistCoverArt(cacheDir, playlistID, username)
+		if err != nil {
+			log.Warn("Failed to get cover art of playlist %d: %v", playlistID, err)
+		}
+		if path == "" {
+			JSON(w, http.StatusNotFound, models.ErrorResponse{Error: "Cover art not found"})
+			return
+		}
+		http.ServeFile(w, r, path)
+		return
+	}
+
 	coverArt := ""
 	if strings.HasPrefix(id, "al-") || strings.HasPrefix(id, "ar-") {
 		coverArt = id
@@ -227,8 +427,6 @@ func (h *Handler) handleGetLibraryCoverArt(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	cfg := di.MustInvoke[*config.Config](r.Context())
-	cacheDir := scanner.GetCoverCacheDir(cfg)
 	cachePath := filepath.Join(cacheDir, coverArt)
 
 	if _, err := os.Stat(cachePath); err != nil {
@@ -0,0 +1,371 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stkevintan/miko/config"
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/browser"
+	"github.com/stkevintan/miko/pkg/di"
+	"github.com/stkevintan/miko/pkg/log"
+	"github.com/stkevintan/miko/pkg/playlists"
+	"github.com/stkevintan/miko/pkg/scanner"
+	"gorm.io/gorm"
+)
+
+// SmartPlaylistRequest creates or replaces a smart playlist. The body of a Navidrome .nsp file
+// is a valid request.
+type SmartPlaylistRequest struct {
+	models.SmartPlaylistFile
+	Public bool `json:"public"`
+}
+
+func decodeSmartPlaylist(w http.ResponseWriter, r *http.Request) (*SmartPlaylistRequest, bool) {
+	var req SmartPlaylistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid smart playlist: " + err.Error()})
+		return nil, false
+	}
+	if req.Name == "" {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Name is required"})
+		return nil, false
+	}
+	if err := browser.ValidateSmartRules(&req.SmartRules); err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid smart playlist: " + err.Error()})
+		return nil, false
+	}
+	return &req, true
+}
+
+func (h *Handler) handleCreateSmartPlaylist(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeSmartPlaylist(w, r)
+	if !ok {
+		return
+	}
+
+	db := di.MustInvoke[*gorm.DB](r.Context())
+	p := models.PlaylistRecord{
+		Name:    req.Name,
+		Comment: req.Comment,
+		Owner:   string(di.MustInvoke[models.Username](r.Context())),
+		Public:  req.Public,
+		Rules:   &req.SmartRules,
+	}
+	if err := db.Create(&p).Error; err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create playlist: " + err.Error()})
+		return
+	}
+	JSON(w, http.StatusOK, p)
+}
+
+func (h *Handler) handleUpdateSmartPlaylist(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid playlist ID"})
+		return
+	}
+	req, ok := decodeSmartPlaylist(w, r)
+	if !ok {
+		return
+	}
+
+	db := di.MustInvoke[*gorm.DB](r.Context())
+	var p models.PlaylistRecord
+	if err := db.First(&p, id).Error; err != nil {
+		JSON(w, http.StatusNotFound, models.ErrorResponse{Error: "Playlist not found"})
+		return
+	}
+	if p.Owner != string(di.MustInvoke[models.Username](r.Context())) {
+		JSON(w, http.StatusForbidden, models.ErrorResponse{Error: "Permission denied"})
+		return
+	}
+	if !p.IsSmart() {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Playlist is not a smart playlist"})
+		return
+	}
+
+	p.Name = req.Name
+	p.Comment = req.Comment
+	p.Public = req.Public
+	p.Rules = &req.SmartRules
+	if err := db.Save(&p).Error; err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to update playlist: " + err.Error()})
+		return
+	}
+	JSON(w, http.StatusOK, p)
+}
+
+// handleImportPlaylist creates a playlist from an uploaded M3U, M3U8 or XSPF file. Relative
+// entries are resolved against the optional "base" directory, the response lists the entries
+// that match no song.
+func (h *Handler) handleImportPlaylist(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(10 << 20); err != nil { // 10MB
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Failed to parse form"})
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "File is required"})
+		return
+	}
+	defer file.Close()
+
+	format := playlists.FormatOf(header.Filename)
+	if format == "" {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Unsupported playlist file, expected .m3u, .m3u8 or .xspf"})
+		return
+	}
+	entries, err := playlists.Parse(format, file)
+	if err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Failed to read playlist: " + err.Error()})
+		return
+	}
+
+	name := r.FormValue("name")
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(header.Filename), filepath.Ext(header.Filename))
+	}
+
+	username := string(di.MustInvoke[models.Username](r.Context()))
+	result, err := playlists.New(di.MustInvoke[*gorm.DB](r.Context())).Import(username, name, entries, r.FormValue("base"))
+	if err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to import playlist: " + err.Error()})
+		return
+	}
+	JSON(w, http.StatusOK, result)
+}
+
+// handleExportPlaylist writes a playlist as an M3U8 or XSPF file. Paths are absolute unless
+// "relative" is set, relative paths start from "base" or from the music folder of each song.
+func (h *Handler) handleExportPlaylist(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid playlist ID"})
+		return
+	}
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = playlists.FormatM3U8
+	}
+	if format != playlists.FormatM3U8 && format != playlists.FormatXSPF {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Format must be m3u8 or xspf"})
+		return
+	}
+
+	br := di.MustInvoke[*browser.Browser](r.Context())
+	playlist, err := br.GetPlaylist(uint(id), string(di.MustInvoke[models.Username](r.Context())))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			JSON(w, http.StatusNotFound, models.ErrorResponse{Error: "Playlist not found"})
+		} else {
+			JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to load playlist: " + err.Error()})
+		}
+		return
+	}
+
+	var folders []models.MusicFolder
+	if err := di.MustInvoke[*gorm.DB](r.Context()).Find(&folders).Error; err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to load music folders: " + err.Error()})
+		return
+	}
+	location := playlists.Location(folders, query.Get("relative") == "true", query.Get("base"))
+
+	contentType := "audio/x-mpegurl"
+	if format == playlists.FormatXSPF {
+		contentType = "application/xspf+xml"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": fmt.Sprintf("%s.%s", playlist.Name, format),
+	}))
+	if err := playlists.Write(w, format, playlist.Name, playlist.Entry, location); err != nil {
+		log.Warn("Failed to export playlist %d: %v", id, err)
+	}
+}
+
+// loadPlaylist loads the playlist of the "id" URL parameter with its collaborators, playlists
+// the user can't see are reported as not found.
+func loadPlaylist(w http.ResponseWriter, r *http.Request) (*models.PlaylistRecord, string, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid playlist ID"})
+		return nil, "", false
+	}
+	username := string(di.MustInvoke[models.Username](r.Context()))
+	var p models.PlaylistRecord
+	if err := di.MustInvoke[*gorm.DB](r.Context()).Preload("Collaborators").First(&p, id).Error; err != nil || p.Permission(username) == "" {
+		JSON(w, http.StatusNotFound, models.ErrorResponse{Error: "Playlist not found"})
+		return nil, "", false
+	}
+	return &p, username, true
+}
+
+func (h *Handler) handleGetPlaylistCollaborators(w http.ResponseWriter, r *http.Request) {
+	p, _, ok := loadPlaylist(w, r)
+	if !ok {
+		return
+	}
+	collaborators := p.Collaborators
+	if collaborators == nil {
+		collaborators = []models.PlaylistCollaborator{}
+	}
+	JSON(w, http.StatusOK, collaborators)
+}
+
+// handleSetPlaylistCollaborator adds a collaborator or changes their permission, only the
+// owner can share a playlist.
+func (h *Handler) handleSetPlaylistCollaborator(w http.ResponseWriter, r *http.Request) {
+	p, username, ok := loadPlaylist(w, r)
+	if !ok {
+		return
+	}
+	if p.Owner != username {
+		JSON(w, http.StatusForbidden, models.ErrorResponse{Error: "Permission denied"})
+		return
+	}
+
+	var req models.PlaylistCollaborator
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	if req.Permission != models.PlaylistRead && req.Permission != models.PlaylistEdit {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Permission must be read or edit"})
+		return
+	}
+	if req.Username == p.Owner {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "The owner can't be a collaborator"})
+		return
+	}
+
+	db := di.MustInvoke[*gorm.DB](r.Context())
+	var count int64
+	if err := db.Model(&models.User{}).Where("username = ?", req.Username).Count(&count).Error; err != nil || count == 0 {
+		JSON(w, http.StatusNotFound, models.ErrorResponse{Error: "User not found"})
+		return
+	}
+
+	req.PlaylistID = p.ID
+	if err := db.Save(&req).Error; err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to save collaborator: " + err.Error()})
+		return
+	}
+	JSON(w, http.StatusOK, req)
+}
+
+// handleRemovePlaylistCollaborator stops sharing a playlist with a user, collaborators can
+// also leave a playlist themselves.
+func (h *Handler) handleRemovePlaylistCollaborator(w http.ResponseWriter, r *http.Request) {
+	p, username, ok := loadPlaylist(w, r)
+	if !ok {
+		return
+	}
+	collaborator := chi.URLParam(r, "username")
+	if p.Owner != username && collaborator != username {
+		JSON(w, http.StatusForbidden, models.ErrorResponse{Error: "Permission denied"})
+		return
+	}
+
+	db := di.MustInvoke[*gorm.DB](r.Context())
+	if err := db.Where("playlist_id = ? AND username = ?", p.ID, collaborator).Delete(&models.PlaylistCollaborator{}).Error; err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to remove collaborator: " + err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetPlaylistActivity lists who added or removed which song, most recent first.
+func (h *Handler) handleGetPlaylistActivity(w http.ResponseWriter, r *http.Request) {
+	p, _, ok := loadPlaylist(w, r)
+	if !ok {
+		return
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	activities := []models.PlaylistActivity{}
+	err = di.MustInvoke[*gorm.DB](r.Context()).Model(&models.PlaylistActivity{}).
+		Select("playlist_activities.*, COALESCE(children.title, '') AS title").
+		Joins("LEFT JOIN children ON children.id = playlist_activities.song_id").
+		Where("playlist_activities.playlist_id = ?", p.ID).
+		Order("playlist_activities.id DESC").
+		Limit(limit).Offset(offset).
+		Scan(&activities).Error
+	if err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to load playlist activity: " + err.Error()})
+		return
+	}
+	JSON(w, http.StatusOK, activities)
+}
+
+// handleUploadPlaylistCover replaces the generated collage of a playlist with an uploaded image.
+func (h *Handler) handleUploadPlaylistCover(w http.ResponseWriter, r *http.Request) {
+	p, username, ok := loadPlaylist(w, r)
+	if !ok {
+		return
+	}
+	if p.Owner != username {
+		JSON(w, http.StatusForbidden, models.ErrorResponse{Error: "Permission denied"})
+		return
+	}
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil { // 10MB
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Failed to parse form"})
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "File is required"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to read file"})
+		return
+	}
+
+	cacheDir := scanner.GetCoverCacheDir(di.MustInvoke[*config.Config](r.Context()))
+	if err := playlists.SaveCustomCover(cacheDir, p.ID, data); err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Failed to save cover: " + err.Error()})
+		return
+	}
+	if err := playlists.RemoveCoverArt(cacheDir, p.ID, false); err != nil {
+		log.Warn("Failed to remove collage of playlist %d: %v", p.ID, err)
+	}
+	JSON(w, http.StatusOK, map[string]string{"coverArt": playlists.CoverArtID(p.ID)})
+}
+
+// handleDeletePlaylistCover removes an uploaded playlist image, the playlist gets a collage again.
+func (h *Handler) handleDeletePlaylistCover(w http.ResponseWriter, r *http.Request) {
+	p, username, ok := loadPlaylist(w, r)
+	if !ok {
+		return
+	}
+	if p.Owner != username {
+		JSON(w, http.StatusForbidden, models.ErrorResponse{Error: "Permission denied"})
+		return
+	}
+
+	cacheDir := scanner.GetCoverCacheDir(di.MustInvoke[*config.Config](r.Context()))
+	if err := playlists.RemoveCoverArt(cacheDir, p.ID, true); err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to remove cover: " + err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
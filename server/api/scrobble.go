@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/di"
+	"github.com/stkevintan/miko/pkg/scrobbler"
+)
+
+// LinkScrobbleAccountRequest links a scrobbling account: Token is a ListenBrainz user token,
+// the Last.fm fields are the credentials of an account of a Last.fm compatible service.
+type LinkScrobbleAccountRequest struct {
+	Token string `json:"token,omitempty"`
+	scrobbler.LastFMLogin
+}
+
+func (h *Handler) handleGetScrobbleAccounts(w http.ResponseWriter, r *http.Request) {
+	username := string(di.MustInvoke[models.Username](r.Context()))
+	accounts, err := di.MustInvoke[*scrobbler.Scrobbler](r.Context()).Accounts(username)
+	if err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to load scrobbling accounts: " + err.Error()})
+		return
+	}
+	JSON(w, http.StatusOK, accounts)
+}
+
+func (h *Handler) handleLinkScrobbleAccount(w http.ResponseWriter, r *http.Request) {
+	var req LinkScrobbleAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	username := string(di.MustInvoke[models.Username](r.Context()))
+	sc := di.MustInvoke[*scrobbler.Scrobbler](r.Context())
+	var account *models.ScrobbleAccount
+	var err error
+	switch service := chi.URLParam(r, "service"); service {
+	case models.ScrobbleListenBrainz:
+		if req.Token == "" {
+			JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Token is required"})
+			return
+		}
+		account, err = sc.LinkListenBrainz(r.Context(), username, req.Token)
+	case models.ScrobbleLastFM:
+		if req.Username == "" || req.Password == "" {
+			JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Username and password are required"})
+			return
+		}
+		account, err = sc.LinkLastFM(r.Context(), username, req.LastFMLogin)
+	default:
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Unknown scrobbling service: " + service})
+		return
+	}
+	if errors.Is(err, scrobbler.ErrServiceNotAllowed) {
+		JSON(w, http.StatusForbidden, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Failed to link account: " + err.Error()})
+		return
+	}
+	JSON(w, http.StatusOK, account)
+}
+
+func (h *Handler) handleUnlinkScrobbleAccount(w http.ResponseWriter, r *http.Request) {
+	username := string(di.MustInvoke[models.Username](r.Context()))
+	if err := di.MustInvoke[*scrobbler.Scrobbler](r.Context()).Unlink(username, chi.URLParam(r, "service")); err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to unlink account: " + err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
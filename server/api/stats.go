@@ -0,0 +1,133 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/di"
+	"github.com/stkevintan/miko/pkg/history"
+)
+
+// parseWindow reads the time window of a history request. from and to are RFC 3339 times or
+// YYYY-MM-DD dates in the time zone tz, a date in to includes that whole day.
+func parseWindow(r *http.Request) (history.Window, *time.Location, error) {
+	query := r.URL.Query()
+	w := history.Window{Username: string(di.MustInvoke[models.Username](r.Context()))}
+
+	loc := time.Local
+	if tz := query.Get("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return w, nil, fmt.Errorf("invalid time zone: %s", tz)
+		}
+	}
+
+	parse := func(key string, endOfDay bool) (time.Time, error) {
+		value := query.Get(key)
+		if value == "" {
+			return time.Time{}, nil
+		}
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, nil
+		}
+		t, err := time.ParseInLocation(time.DateOnly, value, loc)
+		if err != nil {
+			return t, fmt.Errorf("invalid %s: %s", key, value)
+		}
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+
+	var err error
+	if w.From, err = parse("from", false); err != nil {
+		return w, nil, err
+	}
+	if w.To, err = parse("to", true); err != nil {
+		return w, nil, err
+	}
+	return w, loc, nil
+}
+
+func (h *Handler) handleGetHistory(w http.ResponseWriter, r *http.Request) {
+	window, _, err := parseWindow(r)
+	if err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	entries, err := di.MustInvoke[*history.Manager](r.Context()).History(window, limit, offset)
+	if err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to load play history: " + err.Error()})
+		return
+	}
+	JSON(w, http.StatusOK, entries)
+}
+
+// handleGetTopStats ranks the songs, artists, albums or genres the user played the most.
+func (h *Handler) handleGetTopStats(w http.ResponseWriter, r *http.Request) {
+	window, _, err := parseWindow(r)
+	if err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+
+	hm := di.MustInvoke[*history.Manager](r.Context())
+	var result any
+	switch kind := chi.URLParam(r, "kind"); kind {
+	case history.KindSongs:
+		result, err = hm.TopSongs(window, limit)
+	case history.KindArtists, history.KindAlbums, history.KindGenres:
+		result, err = hm.Top(kind, window, limit)
+	default:
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Unknown top list: " + kind})
+		return
+	}
+	if err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to compute statistics: " + err.Error()})
+		return
+	}
+	JSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) handleGetHeatMap(w http.ResponseWriter, r *http.Request) {
+	window, loc, err := parseWindow(r)
+	if err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	heatMap, err := di.MustInvoke[*history.Manager](r.Context()).HeatMap(window, loc)
+	if err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to compute statistics: " + err.Error()})
+		return
+	}
+	JSON(w, http.StatusOK, heatMap)
+}
+
+func (h *Handler) handleGetStreaks(w http.ResponseWriter, r *http.Request) {
+	window, loc, err := parseWindow(r)
+	if err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	streaks, err := di.MustInvoke[*history.Manager](r.Context()).Streaks(window, loc, time.Now())
+	if err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to compute statistics: " + err.Error()})
+		return
+	}
+	JSON(w, http.StatusOK, streaks)
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stkevintan/miko/config"
+	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/di"
+	"github.com/stkevintan/miko/pkg/subscriptions"
+	"github.com/stkevintan/miko/pkg/types"
+	"gorm.io/gorm"
+)
+
+// SubscriptionRequest creates or updates a subscription. Interval is in minutes, omitted fields
+// of an update are left unchanged.
+type SubscriptionRequest struct {
+	URI            string `json:"uri"`
+	Platform       string `json:"platform,omitempty"`
+	Name           string `json:"name,omitempty"`
+	Interval       *int   `json:"interval,omitempty"`
+	Level          string `json:"level,omitempty"`
+	Output         string `json:"output,omitempty"`
+	Enabled        *bool  `json:"enabled,omitempty"`
+	MirrorPlaylist *bool  `json:"mirrorPlaylist,omitempty"`
+}
+
+func (h *Handler) handleCreateSubscription(w http.ResponseWriter, r *http.Request) {
+	var req SubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	sub := &models.Subscription{
+		Username: string(di.MustInvoke[models.Username](r.Context())),
+		Platform: di.MustInvoke[*config.Config](r.Context()).Provider.Platform,
+		Level:    "lossless",
+		Enabled:  true,
+	}
+	h.saveSubscription(w, r, sub, req, http.StatusCreated)
+}
+
+func (h *Handler) handleUpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.subscription(w, r)
+	if !ok {
+		return
+	}
+	var req SubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+	h.saveSubscription(w, r, sub, req, http.StatusOK)
+}
+
+// saveSubscription applies req to sub and saves it, the output is resolved among the
+// directories the user may download to.
+func (h *Handler) saveSubscription(w http.ResponseWriter, r *http.Request, sub *models.Subscription, req SubscriptionRequest, status int) {
+	if req.URI != "" {
+		sub.URI = req.URI
+	}
+	if req.Platform != "" {
+		sub.Platform = req.Platform
+	}
+	if req.Name != "" {
+		sub.Name = req.Name
+	}
+	if req.Interval != nil {
+		sub.Interval = *req.Interval
+	}
+	if req.Level != "" {
+		sub.Level = req.Level
+	}
+	if req.Enabled != nil {
+		sub.Enabled = *req.Enabled
+	}
+	if req.MirrorPlaylist != nil {
+		sub.MirrorPlaylist = *req.MirrorPlaylist
+	}
+	if req.Output != "" || sub.Output == "" {
+		dc, err := libraryDownloadConfig(r.Context(), types.DownloadConfig{Output: req.Output})
+		if err != nil {
+			downloadConfigError(w, err)
+			return
+		}
+		sub.Output = dc.Output
+	}
+
+	err := di.MustInvoke[*subscriptions.Manager](r.Context()).Save(sub)
+	switch {
+	case errors.Is(err, subscriptions.ErrInvalid):
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+	case err != nil:
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to save subscription: " + err.Error()})
+	default:
+		JSON(w, status, sub)
+	}
+}
+
+func (h *Handler) handleGetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	username := string(di.MustInvoke[models.Username](r.Context()))
+	subs, err := di.MustInvoke[*subscriptions.Manager](r.Context()).List(username)
+	if err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to load subscriptions: " + err.Error()})
+		return
+	}
+	JSON(w, http.StatusOK, subs)
+}
+
+func (h *Handler) handleGetSubscription(w http.ResponseWriter, r *http.Request) {
+	if sub, ok := h.subscription(w, r); ok {
+		JSON(w, http.StatusOK, sub)
+	}
+}
+
+func (h *Handler) handleDeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.subscription(w, r)
+	if !ok {
+		return
+	}
+	if err := di.MustInvoke[*subscriptions.Manager](r.Context()).Delete(sub.Username, sub.ID); err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to delete subscription: " + err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSyncSubscription syncs a subscription now, whatever its schedule.
+func (h *Handler) handleSyncSubscription(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.subscription(w, r)
+	if !ok {
+		return
+	}
+	if err := di.MustInvoke[*subscriptions.Manager](r.Context()).Sync(r.Context(), sub); err != nil {
+		JSON(w, http.StatusBadGateway, models.ErrorResponse{Error: "Failed to sync subscription: " + err.Error()})
+		return
+	}
+	JSON(w, http.StatusOK, sub)
+}
+
+func (h *Handler) handleGetSubscriptionHistory(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.subscription(w, r)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	events, err := di.MustInvoke[*subscriptions.Manager](r.Context()).History(sub.ID, limit, max(offset, 0))
+	if err != nil {
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to load subscription history: " + err.Error()})
+		return
+	}
+	JSON(w, http.StatusOK, events)
+}
+
+// subscription loads the subscription of the id URL parameter, responding with an error when
+// the user has no such subscription.
+func (h *Handler) subscription(w http.ResponseWriter, r *http.Request) (*models.Subscription, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		JSON(w, http.StatusBadRequest, models.ErrorResponse{Error: "Invalid subscription ID"})
+		return nil, false
+	}
+	username := string(di.MustInvoke[models.Username](r.Context()))
+	sub, err := di.MustInvoke[*subscriptions.Manager](r.Context()).Get(username, uint(id))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		JSON(w, http.StatusNotFound, models.ErrorResponse{Error: "Subscription not found"})
+		return nil, false
+	case err != nil:
+		JSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to load subscription: " + err.Error()})
+		return nil, false
+	}
+	return sub, true
+}
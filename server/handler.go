@@ -9,12 +9,22 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/stkevintan/miko/config"
+	"github.com/stkevintan/miko/pkg/albuminfo"
+	"github.com/stkevintan/miko/pkg/artistinfo"
 	"github.com/stkevintan/miko/pkg/bookmarks"
 	"github.com/stkevintan/miko/pkg/browser"
+	"github.com/stkevintan/miko/pkg/crypto"
 	"github.com/stkevintan/miko/pkg/di"
+	"github.com/stkevintan/miko/pkg/downloads"
+	"github.com/stkevintan/miko/pkg/events"
+	"github.com/stkevintan/miko/pkg/history"
 	"github.com/stkevintan/miko/pkg/log"
+	"github.com/stkevintan/miko/pkg/netease"
+	"github.com/stkevintan/miko/pkg/provider"
 	"github.com/stkevintan/miko/pkg/scanner"
 	"github.com/stkevintan/miko/pkg/scraper"
+	"github.com/stkevintan/miko/pkg/scrobbler"
+	"github.com/stkevintan/miko/pkg/subscriptions"
 	"github.com/stkevintan/miko/server/api"
 	"github.com/stkevintan/miko/server/subsonic"
 	"gorm.io/gorm"
@@ -34,12 +44,38 @@ func New(ctx context.Context) *Handler {
 	s := scanner.New(db, cfg)
 	di.Provide(ctx, s)
 	di.Provide(ctx, scraper.New(db, cfg, s))
+	sc := scrobbler.New(db, cfg, crypto.ResolvePasswordSecret(ctx))
+	di.Provide(ctx, sc)
+	go sc.Run(ctx)
+	di.Provide(ctx, newArtistInfo(db))
+	di.Provide(ctx, albuminfo.New(db))
+	bus := events.New()
+	di.Provide(ctx, bus)
+	dq := downloads.New(db, api.NewUserProvider, bus, s)
+	di.Provide(ctx, dq)
+	go dq.Run(ctx)
+	subs := subscriptions.New(db, cfg, api.NewUserProvider, dq)
+	di.Provide(ctx, subs)
+	go subs.Run(ctx)
 
 	return &Handler{
 		ctx: ctx,
 	}
 }
 
+// newArtistInfo creates the artist info manager, NetEase is asked anonymously as artist info
+// isn't specific to a user.
+func newArtistInfo(db *gorm.DB) *artistinfo.Manager {
+	sources := []artistinfo.Source{artistinfo.NewMusicBrainz()}
+	p, err := netease.NewProvider(nil)
+	if err != nil {
+		log.Warn("Failed to create netease provider for artist info: %v", err)
+	} else if ap, ok := p.(provider.ArtistProvider); ok {
+		sources = append(sources, artistinfo.NewProvider("netease", ap))
+	}
+	return artistinfo.New(db, sources...)
+}
+
 // Routes sets up the HTTP routes using Chi
 func (h *Handler) Routes() http.Handler {
 	r := chi.NewRouter()
@@ -105,6 +141,9 @@ func bridgeDI(parent context.Context) func(next http.Handler) http.Handler {
 			di.ProvideFactory(reqCtx, func(ctx context.Context) *bookmarks.Manager {
 				return bookmarks.New(di.MustInvoke[*gorm.DB](ctx))
 			})
+			di.ProvideFactory(reqCtx, func(ctx context.Context) *history.Manager {
+				return history.New(di.MustInvoke[*gorm.DB](ctx))
+			})
 
 			next.ServeHTTP(w, r.WithContext(reqCtx))
 		})
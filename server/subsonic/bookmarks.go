@@ -6,6 +6,7 @@ import (
 	"github.com/stkevintan/miko/models"
 	"github.com/stkevintan/miko/pkg/bookmarks"
 	"github.com/stkevintan/miko/pkg/di"
+	"github.com/stkevintan/miko/pkg/log"
 )
 
 func (s *Subsonic) handleGetBookmarks(w http.ResponseWriter, r *http.Request) {
@@ -89,6 +90,9 @@ func (s *Subsonic) handleSavePlayQueue(w http.ResponseWriter, r *http.Request) {
 		s.sendResponse(w, r, models.NewErrorResponse(0, err.Error()))
 		return
 	}
+	if err := bm.SaveProgress(username, current, int64(position)); err != nil {
+		log.Warn("Failed to save audiobook progress for %s: %v", username, err)
+	}
 
 	s.sendResponse(w, r, models.NewResponse(models.ResponseStatusOK))
 }
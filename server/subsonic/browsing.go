@@ -5,6 +5,8 @@ import (
 
 	"github.com/stkevintan/miko/config"
 	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/albuminfo"
+	"github.com/stkevintan/miko/pkg/artistinfo"
 	"github.com/stkevintan/miko/pkg/browser"
 	"github.com/stkevintan/miko/pkg/di"
 	"github.com/stkevintan/miko/pkg/scanner"
@@ -151,21 +153,58 @@ func (s *Subsonic) handleGetSong(w http.ResponseWriter, r *http.Request) {
 	s.sendResponse(w, r, resp)
 }
 
-// TODO: Use music provider to get real data
 func (s *Subsonic) handleGetArtistInfo2(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Query().Get("id")
-	if id == "" {
-		s.sendResponse(w, r, models.NewErrorResponse(10, "ID is required"))
+	base, similar, ok := s.artistInfo(w, r)
+	if !ok {
 		return
 	}
 
-	// For now, we don't have external metadata provider, so return empty info
 	resp := models.NewResponse(models.ResponseStatusOK)
-	resp.ArtistInfo2 = &models.ArtistInfo2{}
+	resp.ArtistInfo2 = &models.ArtistInfo2{
+		ArtistInfoBase: base,
+		SimilarArtist:  similar,
+	}
 	s.sendResponse(w, r, resp)
 }
 
-// TODO: Use music provider to get real data
+// artistInfo looks up the info of the artist of the id parameter and its similar artists in
+// the library, sending the error response when it fails.
+func (s *Subsonic) artistInfo(w http.ResponseWriter, r *http.Request) (models.ArtistInfoBase, []models.ArtistID3, bool) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		s.sendResponse(w, r, models.NewErrorResponse(10, "ID is required"))
+		return models.ArtistInfoBase{}, nil, false
+	}
+
+	br := di.MustInvoke[*browser.Browser](r.Context())
+	name, err := br.ArtistName(id)
+	if err != nil {
+		s.sendResponse(w, r, models.NewErrorResponse(70, "Artist not found"))
+		return models.ArtistInfoBase{}, nil, false
+	}
+
+	info, err := di.MustInvoke[*artistinfo.Manager](r.Context()).Get(r.Context(), name)
+	if err != nil {
+		s.sendResponse(w, r, models.NewErrorResponse(0, "Failed to get artist info"))
+		return models.ArtistInfoBase{}, nil, false
+	}
+
+	similar, err := br.LibraryArtists(info.Similar, getQueryIntOrDefault(r, "count", 20))
+	if err != nil {
+		s.sendResponse(w, r, models.NewErrorResponse(0, "Failed to find similar artists"))
+		return models.ArtistInfoBase{}, nil, false
+	}
+
+	return models.ArtistInfoBase{
+		Biography:      info.Biography,
+		MusicBrainzID:  info.MusicBrainzID,
+		LastFmURL:      info.LastFmURL,
+		SmallImageUrl:  info.SmallImageURL,
+		MediumImageUrl: info.MediumImageURL,
+		LargeImageUrl:  info.LargeImageURL,
+	}, similar, true
+}
+
 func (s *Subsonic) handleGetAlbumInfo2(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	if id == "" {
@@ -173,9 +212,26 @@ func (s *Subsonic) handleGetAlbumInfo2(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// For now, we don't have external metadata provider, so return empty info
+	album, err := di.MustInvoke[*browser.Browser](r.Context()).InfoAlbum(id)
+	if err != nil {
+		s.sendResponse(w, r, models.NewErrorResponse(70, "Album not found"))
+		return
+	}
+
+	info, err := di.MustInvoke[*albuminfo.Manager](r.Context()).Get(r.Context(), album)
+	if err != nil {
+		s.sendResponse(w, r, models.NewErrorResponse(0, "Failed to get album info"))
+		return
+	}
+
 	resp := models.NewResponse(models.ResponseStatusOK)
-	resp.AlbumInfo = &models.AlbumInfo{}
+	resp.AlbumInfo = &models.AlbumInfo{
+		Notes:          info.Notes,
+		MusicBrainzID:  info.MusicBrainzID,
+		SmallImageUrl:  info.SmallImageURL,
+		MediumImageUrl: info.MediumImageURL,
+		LargeImageUrl:  info.LargeImageURL,
+	}
 	s.sendResponse(w, r, resp)
 }
 
@@ -187,10 +243,19 @@ func (s *Subsonic) handleGetSimilarSongs2(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// For now, we don't have external metadata provider, so return empty list
+	songs, err := di.MustInvoke[*browser.Browser](r.Context()).SimilarSongs(id, getQueryIntOrDefault(r, "count", 50))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			s.sendResponse(w, r, models.NewErrorResponse(70, "Item not found"))
+		} else {
+			s.sendResponse(w, r, models.NewErrorResponse(0, "Failed to find similar songs"))
+		}
+		return
+	}
+
 	resp := models.NewResponse(models.ResponseStatusOK)
 	resp.SimilarSongs2 = &models.SimilarSongs2{
-		Song: []models.Child{},
+		Song: songs,
 	}
 	s.sendResponse(w, r, resp)
 }
@@ -200,15 +265,20 @@ func (s *Subsonic) handleGetAlbumInfo(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Subsonic) handleGetArtistInfo(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Query().Get("id")
-	if id == "" {
-		s.sendResponse(w, r, models.NewErrorResponse(10, "ID is required"))
+	base, similar, ok := s.artistInfo(w, r)
+	if !ok {
 		return
 	}
 
-	// For now, we don't have external metadata provider, so return empty info
+	artists := make([]models.Artist, len(similar))
+	for i, artist := range similar {
+		artists[i] = models.Artist{ID: artist.ID, Name: artist.Name}
+	}
 	resp := models.NewResponse(models.ResponseStatusOK)
-	resp.ArtistInfo = &models.ArtistInfo{}
+	resp.ArtistInfo = &models.ArtistInfo{
+		ArtistInfoBase: base,
+		SimilarArtist:  artists,
+	}
 	s.sendResponse(w, r, resp)
 }
 
@@ -219,10 +289,19 @@ func (s *Subsonic) handleGetSimilarSongs(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// For now, we don't have external metadata provider, so return empty list
+	songs, err := di.MustInvoke[*browser.Browser](r.Context()).SimilarSongs(id, getQueryIntOrDefault(r, "count", 50))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			s.sendResponse(w, r, models.NewErrorResponse(70, "Item not found"))
+		} else {
+			s.sendResponse(w, r, models.NewErrorResponse(0, "Failed to find similar songs"))
+		}
+		return
+	}
+
 	resp := models.NewResponse(models.ResponseStatusOK)
 	resp.SimilarSongs = &models.SimilarSongs{
-		Song: []models.Child{},
+		Song: songs,
 	}
 	s.sendResponse(w, r, resp)
 }
@@ -234,10 +313,15 @@ func (s *Subsonic) handleGetTopSongs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// For now, we don't have external metadata provider, so return empty list
+	songs, err := di.MustInvoke[*browser.Browser](r.Context()).TopSongs(artist, getQueryIntOrDefault(r, "count", 50))
+	if err != nil {
+		s.sendResponse(w, r, models.NewErrorResponse(0, "Failed to fetch top songs"))
+		return
+	}
+
 	resp := models.NewResponse(models.ResponseStatusOK)
 	resp.TopSongs = &models.TopSongs{
-		Song: []models.Child{},
+		Song: songs,
 	}
 	s.sendResponse(w, r, resp)
 }
@@ -20,14 +20,15 @@ func (s *Subsonic) handleGetAlbumList2(w http.ResponseWriter, r *http.Request) {
 
 	musicFolderId, err := getQueryInt[uint](r, "musicFolderId")
 	hasFolderId := err == nil
+	fromYear, toYear := getYearRange(r, listType)
 
 	albums, err := br.GetAlbums(browser.AlbumListOptions{
 		Type:          listType,
 		Size:          getQueryIntOrDefault(r, "size", 10),
 		Offset:        getQueryIntOrDefault(r, "offset", 0),
 		Genre:         query.Get("genre"),
-		FromYear:      getQueryIntOrDefault(r, "fromYear", 0),
-		ToYear:        getQueryIntOrDefault(r, "toYear", 3000),
+		FromYear:      fromYear,
+		ToYear:        toYear,
 		MusicFolderID: musicFolderId,
 		HasFolderID:   hasFolderId,
 	})
@@ -54,14 +55,15 @@ func (s *Subsonic) handleGetAlbumList(w http.ResponseWriter, r *http.Request) {
 
 	musicFolderId, err := getQueryInt[uint](r, "musicFolderId")
 	hasFolderId := err == nil
+	fromYear, toYear := getYearRange(r, listType)
 
 	albums, err := br.GetAlbums(browser.AlbumListOptions{
 		Type:          listType,
 		Size:          getQueryIntOrDefault(r, "size", 10),
 		Offset:        getQueryIntOrDefault(r, "offset", 0),
 		Genre:         query.Get("genre"),
-		FromYear:      getQueryIntOrDefault(r, "fromYear", 0),
-		ToYear:        getQueryIntOrDefault(r, "toYear", 3000),
+		FromYear:      fromYear,
+		ToYear:        toYear,
 		MusicFolderID: musicFolderId,
 		HasFolderID:   hasFolderId,
 	})
@@ -97,6 +99,17 @@ func (s *Subsonic) handleGetAlbumList(w http.ResponseWriter, r *http.Request) {
 	s.sendResponse(w, r, resp)
 }
 
+// getYearRange returns the year range of an album list. Besides the standard fromYear/toYear
+// parameters, the byDecade type takes a decade parameter such as 1990.
+func getYearRange(r *http.Request, listType string) (int, int) {
+	if listType == "byDecade" {
+		decade := getQueryIntOrDefault(r, "decade", 0)
+		decade -= decade % 10
+		return decade, decade + 9
+	}
+	return getQueryIntOrDefault(r, "fromYear", 0), getQueryIntOrDefault(r, "toYear", 3000)
+}
+
 func (s *Subsonic) handleGetNowPlaying(w http.ResponseWriter, r *http.Request) {
 	db := di.MustInvoke[*gorm.DB](r.Context())
 
@@ -14,9 +14,13 @@ import (
 
 	"github.com/stkevintan/miko/config"
 	"github.com/stkevintan/miko/models"
+	"github.com/stkevintan/miko/pkg/browser"
 	"github.com/stkevintan/miko/pkg/di"
+	"github.com/stkevintan/miko/pkg/history"
 	"github.com/stkevintan/miko/pkg/log"
+	"github.com/stkevintan/miko/pkg/playlists"
 	"github.com/stkevintan/miko/pkg/scanner"
+	"github.com/stkevintan/miko/pkg/scrobbler"
 	"github.com/stkevintan/miko/pkg/shared"
 	"gorm.io/gorm"
 )
@@ -80,6 +84,24 @@ func (s *Subsonic) handleGetCoverArt(w http.ResponseWriter, r *http.Request) {
 		s.sendResponse(w, r, models.NewErrorResponse(10, "ID is required"))
 		return
 	}
+	cfg := di.MustInvoke[*config.Config](r.Context())
+	cacheDir := scanner.GetCoverCacheDir(cfg)
+
+	if playlistID, ok := playlists.ParseCoverArtID(id); ok {
+		br := di.MustInvoke[*browser.Browser](r.Context())
+		username := string(di.MustInvoke[models.Username](r.Context()))
+		path, err := br.GetPlaylistCoverArt(cacheDir, playlistID, username)
+		if err != nil {
+			log.Warn("Failed to get cover art of playlist %d: %v", playlistID, err)
+		}
+		if path == "" {
+			s.sendResponse(w, r, models.NewErrorResponse(70, "Cover art not found"))
+			return
+		}
+		safeServeFile(w, r, path)
+		return
+	}
+
 	// get album id
 	coverArt := ""
 	if strings.HasPrefix(id, "al-") || strings.HasPrefix(id, "ar-") {
@@ -100,9 +122,6 @@ func (s *Subsonic) handleGetCoverArt(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cfg := di.MustInvoke[*config.Config](r.Context())
-	cacheDir := scanner.GetCoverCacheDir(cfg)
-
 	// Try to serve from cache first
 	cachePath := filepath.Join(cacheDir, coverArt)
 	if _, err := os.Stat(cachePath); err == nil {
@@ -255,14 +274,17 @@ func updateNowPlaying(w http.ResponseWriter, r *http.Request, s *Subsonic, id st
 		PlayerName: clientName,
 		UpdatedAt:  time.Now(),
 	})
+	if err := di.MustInvoke[*scrobbler.Scrobbler](r.Context()).NowPlaying(username, id); err != nil {
+		log.Warn("Failed to queue now playing event of %s: %v", username, err)
+	}
 
 	s.sendResponse(w, r, models.NewResponse(models.ResponseStatusOK))
 }
 
 func (s *Subsonic) handleScrobble(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
-	id := query.Get("id")
-	if id == "" {
+	ids := query["id"]
+	if len(ids) == 0 || ids[0] == "" {
 		s.sendResponse(w, r, models.NewErrorResponse(10, "ID is required"))
 		return
 	}
@@ -270,26 +292,46 @@ func (s *Subsonic) handleScrobble(w http.ResponseWriter, r *http.Request) {
 	submission := query.Get("submission")
 	if submission == "false" {
 		// If submission is false, it's just an update now playing call
-		updateNowPlaying(w, r, s, id)
-		return
-	}
-
-	db := di.MustInvoke[*gorm.DB](r.Context())
-	if err := db.Model(&models.Child{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"play_count":  gorm.Expr("play_count + 1"),
-		"last_played": time.Now(),
-	}).Error; err != nil {
-		s.sendResponse(w, r, models.NewErrorResponse(0, "Failed to update play count"))
+		updateNowPlaying(w, r, s, ids[0])
 		return
 	}
 
-	// Remove now playing record since it's now scrobbled (finished)
 	username := string(di.MustInvoke[models.Username](r.Context()))
 	clientName := query.Get("c")
 	if clientName == "" {
 		clientName = "Unknown"
 	}
 	key := fmt.Sprintf("%s:%s", username, clientName)
+
+	now := time.Now()
+	times := query["time"]
+	plays := make([]history.Play, len(ids))
+	for i, id := range ids {
+		plays[i] = history.Play{SongID: id, PlayedAt: now}
+		if i < len(times) {
+			if ms, err := strconv.ParseInt(times[i], 10, 64); err == nil {
+				plays[i].PlayedAt = time.UnixMilli(ms)
+				continue
+			}
+		}
+		// without a time the scrobble is live, so the song has been listened to since the
+		// client reported it as now playing
+		if v, ok := s.nowPlaying.Load(key); ok && len(ids) == 1 {
+			if record := v.(models.NowPlayingRecord); record.ChildID == id {
+				plays[i].Listened = now.Sub(record.UpdatedAt)
+			}
+		}
+	}
+
+	if err := di.MustInvoke[*history.Manager](r.Context()).Record(username, clientName, plays); err != nil {
+		s.sendResponse(w, r, models.NewErrorResponse(0, "Failed to update play count"))
+		return
+	}
+	if err := di.MustInvoke[*scrobbler.Scrobbler](r.Context()).Submit(username, plays); err != nil {
+		log.Warn("Failed to queue scrobbles of %s: %v", username, err)
+	}
+
+	// Remove now playing record since it's now scrobbled (finished)
 	s.nowPlaying.Delete(key)
 
 	s.sendResponse(w, r, models.NewResponse(models.ResponseStatusOK))
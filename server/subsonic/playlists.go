@@ -4,10 +4,13 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/stkevintan/miko/config"
 	"github.com/stkevintan/miko/models"
 	"github.com/stkevintan/miko/pkg/browser"
 	"github.com/stkevintan/miko/pkg/di"
 	"github.com/stkevintan/miko/pkg/log"
+	"github.com/stkevintan/miko/pkg/playlists"
+	"github.com/stkevintan/miko/pkg/scanner"
 	"gorm.io/gorm"
 )
 
@@ -42,7 +45,8 @@ func (s *Subsonic) handleGetPlaylist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	playlist, err := br.GetPlaylist(id)
+	username := string(di.MustInvoke[models.Username](r.Context()))
+	playlist, err := br.GetPlaylist(id, username)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			s.sendResponse(w, r, models.NewErrorResponse(70, "Playlist not found"))
@@ -52,12 +56,6 @@ func (s *Subsonic) handleGetPlaylist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	username := string(di.MustInvoke[models.Username](r.Context()))
-	if !playlist.Public && playlist.Owner != username {
-		s.sendResponse(w, r, models.NewErrorResponse(70, "Playlist not found"))
-		return
-	}
-
 	resp := models.NewResponse(models.ResponseStatusOK)
 	resp.Playlist = playlist
 	s.sendResponse(w, r, resp)
@@ -98,7 +96,7 @@ func (s *Subsonic) handleCreatePlaylist(w http.ResponseWriter, r *http.Request)
 				return err
 			}
 		}
-		return nil
+		return logPlaylistActivity(tx, p.ID, username, models.PlaylistActionAdd, songIDs)
 	})
 
 	if err != nil {
@@ -119,31 +117,44 @@ func (s *Subsonic) handleUpdatePlaylist(w http.ResponseWriter, r *http.Request)
 	}
 
 	var p models.PlaylistRecord
-	if err := db.First(&p, id).Error; err != nil {
+	if err := db.Preload("Collaborators").First(&p, id).Error; err != nil {
 		s.sendResponse(w, r, models.NewErrorResponse(70, "Playlist not found"))
 		return
 	}
 
 	username := string(di.MustInvoke[models.Username](r.Context()))
+	query := r.URL.Query()
 
-	if p.Owner != username {
-		s.sendResponse(w, r, models.NewErrorResponse(0, "Permission denied"))
+	// collaborators with edit permission may change the songs, everything else is up to the owner
+	editsSongs := len(query["songIdToAdd"]) > 0 || len(query["songIndexToRemove"]) > 0
+	// clients resend the details they didn't change, only new values count as edits
+	name, comment, public := query.Get("name"), query.Get("comment"), query.Get("public")
+	editsDetails := (name != "" && name != p.Name) || (comment != "" && comment != p.Comment) ||
+		(public != "" && (public == "true") != p.Public)
+	switch permission := p.Permission(username); {
+	case permission == "":
+		s.sendResponse(w, r, models.NewErrorResponse(70, "Playlist not found"))
+		return
+	case permission != models.PlaylistEdit || (editsDetails && p.Owner != username):
+		s.sendResponse(w, r, models.NewErrorResponse(50, "Permission denied"))
+		return
+	case editsSongs && p.IsReadonly():
+		s.sendResponse(w, r, models.NewErrorResponse(50, "The songs of this playlist are read-only"))
 		return
 	}
 
-	query := r.URL.Query()
 	err = db.Transaction(func(tx *gorm.DB) error {
-		if name := query.Get("name"); name != "" {
+		if name != "" {
 			p.Name = name
 		}
-		if comment := query.Get("comment"); comment != "" {
+		if comment != "" {
 			p.Comment = comment
 		}
-		if public := query.Get("public"); public != "" {
+		if public != "" {
 			p.Public = public == "true"
 		}
 
-		if err := tx.Save(&p).Error; err != nil {
+		if err := tx.Omit("Collaborators").Save(&p).Error; err != nil {
 			return err
 		}
 
@@ -165,6 +176,9 @@ func (s *Subsonic) handleUpdatePlaylist(w http.ResponseWriter, r *http.Request)
 			if err := tx.Create(&songsToAdd).Error; err != nil {
 				return err
 			}
+			if err := logPlaylistActivity(tx, p.ID, username, models.PlaylistActionAdd, songIDsToAdd); err != nil {
+				return err
+			}
 		}
 
 		// Handle song removals
@@ -180,9 +194,16 @@ func (s *Subsonic) handleUpdatePlaylist(w http.ResponseWriter, r *http.Request)
 			}
 
 			if len(posList) > 0 {
+				var removed []string
+				if err := tx.Model(&models.PlaylistSong{}).Where("playlist_id = ? AND position IN ?", p.ID, posList).Order("position").Pluck("song_id", &removed).Error; err != nil {
+					return err
+				}
 				if err := tx.Where("playlist_id = ? AND position IN ?", p.ID, posList).Delete(&models.PlaylistSong{}).Error; err != nil {
 					return err
 				}
+				if err := logPlaylistActivity(tx, p.ID, username, models.PlaylistActionRemove, removed); err != nil {
+					return err
+				}
 				// Re-index using a single UPDATE with a CTE
 				if err := tx.Exec(`
 					WITH new_positions AS (
@@ -231,7 +252,8 @@ func (s *Subsonic) handleDeletePlaylist(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := db.Delete(&p).Error; err != nil {
+	cacheDir := scanner.GetCoverCacheDir(di.MustInvoke[*config.Config](r.Context()))
+	if err := playlists.Delete(db, cacheDir, &p); err != nil {
 		s.sendResponse(w, r, models.NewErrorResponse(0, "Failed to delete playlist"))
 		return
 	}
@@ -239,3 +261,20 @@ func (s *Subsonic) handleDeletePlaylist(w http.ResponseWriter, r *http.Request)
 	resp := models.NewResponse(models.ResponseStatusOK)
 	s.sendResponse(w, r, resp)
 }
+
+// logPlaylistActivity records username adding or removing songs of a playlist.
+func logPlaylistActivity(tx *gorm.DB, playlistID uint, username, action string, songIDs []string) error {
+	if len(songIDs) == 0 {
+		return nil
+	}
+	activities := make([]models.PlaylistActivity, len(songIDs))
+	for i, songID := range songIDs {
+		activities[i] = models.PlaylistActivity{
+			PlaylistID: playlistID,
+			Username:   username,
+			Action:     action,
+			SongID:     songID,
+		}
+	}
+	return tx.Create(&activities).Error
+}
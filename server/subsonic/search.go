@@ -96,8 +96,11 @@ func (s *Subsonic) handleSearch3(w http.ResponseWriter, r *http.Request) {
 
 func (s *Subsonic) search(r *http.Request) ([]models.ArtistID3, []models.AlbumID3, []models.Child, error) {
 	br := di.MustInvoke[*browser.Browser](r.Context())
-	query := r.URL.Query().Get("query")
-	query = strings.Trim(query, " \"'")
+	query := strings.TrimSpace(r.URL.Query().Get("query"))
+	// Some clients wrap the whole query in quotes, quotes inside are query syntax
+	if unquoted, ok := strings.CutPrefix(query, `"`); ok && strings.Count(query, `"`) == 2 && strings.HasSuffix(query, `"`) {
+		query = strings.TrimSuffix(unquoted, `"`)
+	}
 
 	musicFolderId, err := getQueryInt[uint](r, "musicFolderId")
 	hasFolderId := err == nil
@@ -112,5 +115,6 @@ func (s *Subsonic) search(r *http.Request) ([]models.ArtistID3, []models.AlbumID
 		SongOffset:    getQueryIntOrDefault(r, "songOffset", 0),
 		MusicFolderID: musicFolderId,
 		HasFolderID:   hasFolderId,
+		Lenient:       true,
 	})
 }